@@ -2,6 +2,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -10,6 +11,10 @@ import (
 
 func main() {
 	if err := cli.Execute(); err != nil {
+		if errors.Is(err, cli.ErrInterrupted) {
+			fmt.Fprintln(os.Stderr, "Interrupted")
+			os.Exit(cli.ExitInterrupted)
+		}
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,569 @@
+// Package anthropic provides a native Anthropic Messages API backend
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// APIVersion is the Messages API version sent with every request
+const APIVersion = "2023-06-01"
+
+// Backend implements the Anthropic Messages API backend
+type Backend struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// Config for Anthropic backend
+type Config struct {
+	BaseURL string // Default: https://api.anthropic.com
+	APIKey  string // API key (or from ANTHROPIC_API_KEY)
+	Model   string // Default: claude-3-5-haiku-latest
+	Timeout time.Duration
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() *Config {
+	return &Config{
+		BaseURL: "https://api.anthropic.com",
+		Model:   "claude-3-5-haiku-latest",
+		Timeout: 5 * time.Minute,
+	}
+}
+
+// New creates a new Anthropic backend
+func New(cfg *Config) *Backend {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.anthropic.com"
+	}
+	if cfg.Model == "" {
+		cfg.Model = "claude-3-5-haiku-latest"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+
+	return &Backend{
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:  apiKey,
+		model:   cfg.Model,
+		httpClient: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: backend.NewRetryTransport(backend.DefaultRetryPolicy(), nil),
+		},
+	}
+}
+
+// Name returns the backend name
+func (b *Backend) Name() string {
+	return "claude"
+}
+
+// Type returns the backend type
+func (b *Backend) Type() backend.Type {
+	return backend.TypeClaude
+}
+
+// Initialize initializes the backend
+func (b *Backend) Initialize(_ context.Context) error {
+	if b.apiKey == "" {
+		return fmt.Errorf("API key required (set ANTHROPIC_API_KEY)")
+	}
+	return nil
+}
+
+// IsAvailable checks if the backend is configured
+func (b *Backend) IsAvailable(_ context.Context) (bool, error) {
+	return b.apiKey != "", nil
+}
+
+// Shutdown shuts down the backend
+func (b *Backend) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// contentBlock is a Messages API content block (text, tool_use or tool_result)
+type contentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// message is a single Messages API conversation turn
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// tool is a Messages API tool definition
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// messagesRequest is the Messages API request
+type messagesRequest struct {
+	Model         string      `json:"model"`
+	Messages      []message   `json:"messages"`
+	System        string      `json:"system,omitempty"`
+	MaxTokens     int         `json:"max_tokens"`
+	Temperature   *float64    `json:"temperature,omitempty"`
+	TopP          float64     `json:"top_p,omitempty"`
+	TopK          int         `json:"top_k,omitempty"`
+	StopSequences []string    `json:"stop_sequences,omitempty"`
+	Stream        bool        `json:"stream,omitempty"`
+	Tools         []tool      `json:"tools,omitempty"`
+	ToolChoice    *toolChoice `json:"tool_choice,omitempty"`
+}
+
+// toolChoice controls whether and which tools the model may use
+type toolChoice struct {
+	Type string `json:"type"` // auto, any, tool or none
+	Name string `json:"name,omitempty"`
+}
+
+// usage is the Messages API token usage block
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// messagesResponse is the Messages API response
+type messagesResponse struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+// streamEvent is a Messages API server-sent event payload
+type streamEvent struct {
+	Type    string            `json:"type"`
+	Message *messagesResponse `json:"message,omitempty"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text,omitempty"`
+		StopReason string `json:"stop_reason,omitempty"`
+	} `json:"delta"`
+	Usage *usage    `json:"usage,omitempty"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+// apiError is the Messages API error object
+type apiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Complete performs a non-streaming completion
+func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	msgReq, err := b.buildRequest(req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.send(ctx, msgReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.CompletionResponse{
+		Content:          textContent(resp.Content),
+		TokensUsed:       resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		PromptTokens:     resp.Usage.InputTokens,
+		CompletionTokens: resp.Usage.OutputTokens,
+		FinishReason:     finishReason(resp.StopReason),
+	}, nil
+}
+
+// Stream performs a streaming completion
+func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	msgReq, err := b.buildRequest(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(msgReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := b.do(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan backend.StreamChunk)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		send := func(chunk backend.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Input tokens arrive with message_start, output tokens with message_delta
+		var streamUsage backend.Usage
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			// Only data lines carry payloads; event names are repeated in "type"
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+			var event streamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(backend.StreamChunk{Error: err})
+				return
+			}
+
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					streamUsage.PromptTokens = event.Message.Usage.InputTokens
+					streamUsage.CompletionTokens = event.Message.Usage.OutputTokens
+				}
+			case "message_delta":
+				if event.Usage != nil {
+					streamUsage.CompletionTokens = event.Usage.OutputTokens
+				}
+			case "content_block_delta":
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					if !send(backend.StreamChunk{Content: event.Delta.Text}) {
+						return
+					}
+				}
+			case "message_stop":
+				send(backend.StreamChunk{Usage: &streamUsage, Done: true})
+				return
+			case "error":
+				msg := "stream error"
+				if event.Error != nil {
+					msg = event.Error.Message
+				}
+				send(backend.StreamChunk{Error: fmt.Errorf("anthropic: %s", msg)})
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			send(backend.StreamChunk{Error: err})
+		}
+	}()
+
+	return ch, nil
+}
+
+// SupportsToolCalling returns true; all current Claude models support tool use
+func (b *Backend) SupportsToolCalling() bool {
+	return true
+}
+
+// CompleteWithTools performs completion with tool_use blocks
+func (b *Backend) CompleteWithTools(ctx context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	msgReq, err := b.buildRequest(&req.CompletionRequest, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, def := range req.Tools {
+		msgReq.Tools = append(msgReq.Tools, tool{
+			Name:        def.Name,
+			Description: def.Description,
+			InputSchema: def.JSONSchema(),
+		})
+	}
+	switch req.ToolChoice {
+	case "", "auto":
+	case "none":
+		msgReq.ToolChoice = &toolChoice{Type: "none"}
+	case "required":
+		msgReq.ToolChoice = &toolChoice{Type: "any"}
+	default:
+		msgReq.ToolChoice = &toolChoice{Type: "tool", Name: req.ToolChoice}
+	}
+
+	resp, err := b.send(ctx, msgReq)
+	if err != nil {
+		return nil, err
+	}
+
+	toolResp := &backend.ToolResponse{
+		Content: textContent(resp.Content),
+		Usage: &backend.Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+		},
+	}
+	for _, block := range resp.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		params := map[string]any{}
+		if len(block.Input) > 0 {
+			if err := json.Unmarshal(block.Input, &params); err != nil {
+				return nil, fmt.Errorf("decode %s tool input: %w", block.Name, err)
+			}
+		}
+		toolResp.ToolCalls = append(toolResp.ToolCalls, backend.ToolCall{
+			ID:         block.ID,
+			Name:       block.Name,
+			Parameters: params,
+		})
+	}
+
+	return toolResp, nil
+}
+
+// ModelInfo returns model information
+func (b *Backend) ModelInfo() *backend.ModelInfo {
+	return &backend.ModelInfo{
+		Name:          b.model,
+		ContextLength: 200000,
+		Capabilities:  []string{backend.CapabilityText, backend.CapabilityCode, backend.CapabilityChat, backend.CapabilityToolCalling},
+	}
+}
+
+// EstimateTokens estimates token count
+func (b *Backend) EstimateTokens(text string) int {
+	// Rough estimate: ~4 characters per token
+	return len(text) / 4
+}
+
+// SetRetry sets how failed requests are retried and paced
+func (b *Backend) SetRetry(policy backend.RetryPolicy, limiter *backend.Limiter) {
+	b.httpClient.Transport = backend.NewRetryTransport(policy, limiter)
+}
+
+// SetModel changes the active model
+func (b *Backend) SetModel(model string) {
+	b.model = model
+}
+
+// SetAPIKey sets the API key
+func (b *Backend) SetAPIKey(key string) {
+	b.apiKey = key
+}
+
+// buildRequest converts a completion request into a Messages API request
+func (b *Backend) buildRequest(req *backend.CompletionRequest, stream bool) (*messagesRequest, error) {
+	msgReq := &messagesRequest{
+		Model:         b.model,
+		System:        req.SystemPrompt,
+		MaxTokens:     req.MaxTokens,
+		StopSequences: req.StopSequences,
+		Stream:        stream,
+	}
+
+	// The Messages API has no JSON mode; ask for it in the system prompt
+	// and rely on backend.CompleteStructured to validate
+	if req.ResponseFormat != nil {
+		if msgReq.System != "" {
+			msgReq.System += "\n\n"
+		}
+		msgReq.System += req.ResponseFormat.Instructions()
+	}
+
+	for _, msg := range req.ConversationMessages() {
+		// The Messages API has no system role; fold it into the system field
+		if msg.Role == backend.RoleSystem {
+			if msgReq.System != "" {
+				msgReq.System += "\n\n"
+			}
+			msgReq.System += msg.Content
+			continue
+		}
+
+		role, blocks := contentBlocks(msg)
+
+		// The API rejects empty turns and empty text blocks
+		if len(blocks) == 0 {
+			continue
+		}
+
+		// Roles must alternate, so consecutive turns from the same side
+		// (e.g. several tool results) are merged into one message
+		if n := len(msgReq.Messages); n > 0 && msgReq.Messages[n-1].Role == role {
+			msgReq.Messages[n-1].Content = append(msgReq.Messages[n-1].Content, blocks...)
+			continue
+		}
+		msgReq.Messages = append(msgReq.Messages, message{Role: role, Content: blocks})
+	}
+
+	if len(msgReq.Messages) == 0 {
+		return nil, fmt.Errorf("empty request: no prompt or messages")
+	}
+
+	// max_tokens is mandatory for the Messages API
+	if msgReq.MaxTokens == 0 {
+		msgReq.MaxTokens = 2048
+	}
+	if req.Temperature > 0 {
+		temp := req.Temperature
+		msgReq.Temperature = &temp
+	}
+	// The Messages API has no seed, min_p, penalties or logit bias
+	msgReq.TopP = req.TopP
+	msgReq.TopK = req.TopK
+
+	return msgReq, nil
+}
+
+// send performs a non-streaming Messages API call
+func (b *Backend) send(ctx context.Context, msgReq *messagesRequest) (*messagesResponse, error) {
+	body, err := json.Marshal(msgReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := b.do(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var msgResp messagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&msgResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return &msgResp, nil
+}
+
+// do posts a request body to /v1/messages and checks the status code
+func (b *Backend) do(ctx context.Context, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", b.apiKey)
+	httpReq.Header.Set("anthropic-version", APIVersion)
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("API request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+
+		var errResp struct {
+			Error apiError `json:"error"`
+		}
+		if json.Unmarshal(bodyBytes, &errResp) == nil && errResp.Error.Message != "" {
+			return nil, fmt.Errorf("API error (status %d): %s: %s", resp.StatusCode, errResp.Error.Type, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return resp, nil
+}
+
+// contentBlocks converts a conversation message into a role and content blocks.
+// Tool results are sent as tool_result blocks in a user turn.
+func contentBlocks(msg backend.Message) (string, []contentBlock) {
+	switch msg.Role {
+	case backend.RoleTool:
+		return "user", []contentBlock{{
+			Type:      "tool_result",
+			ToolUseID: msg.ToolCallID,
+			Content:   msg.Content,
+		}}
+
+	case backend.RoleAssistant:
+		var blocks []contentBlock
+		if msg.Content != "" {
+			blocks = append(blocks, contentBlock{Type: "text", Text: msg.Content})
+		}
+		for _, call := range msg.ToolCalls {
+			input := json.RawMessage("{}")
+			if len(call.Parameters) > 0 {
+				input, _ = json.Marshal(call.Parameters)
+			}
+			blocks = append(blocks, contentBlock{
+				Type:  "tool_use",
+				ID:    call.ID,
+				Name:  call.Name,
+				Input: input,
+			})
+		}
+		return "assistant", blocks
+
+	default:
+		if msg.Content == "" {
+			return "user", nil
+		}
+		return "user", []contentBlock{{Type: "text", Text: msg.Content}}
+	}
+}
+
+// textContent joins all text blocks of a response
+func textContent(blocks []contentBlock) string {
+	var sb strings.Builder
+	for _, block := range blocks {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String()
+}
+
+// finishReason maps a Messages API stop_reason to a backend.FinishReason
+func finishReason(stopReason string) backend.FinishReason {
+	switch stopReason {
+	case "max_tokens":
+		return backend.FinishLength
+	case "stop_sequence", "tool_use":
+		return backend.FinishStop
+	default:
+		return backend.FinishComplete
+	}
+}
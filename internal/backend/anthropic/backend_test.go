@@ -0,0 +1,355 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, req *messagesRequest)) (*Backend, *httptest.Server) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, APIVersion, r.Header.Get("anthropic-version"))
+
+		var req messagesRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		handler(w, &req)
+	}))
+	t.Cleanup(srv.Close)
+
+	return New(&Config{BaseURL: srv.URL, APIKey: "test-key"}), srv
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	b := New(nil)
+	assert.NotNil(t, b)
+	assert.Equal(t, "claude", b.Name())
+	assert.Equal(t, backend.TypeClaude, b.Type())
+	assert.Equal(t, "https://api.anthropic.com", b.baseURL)
+
+	avail, err := b.IsAvailable(context.Background())
+	require.NoError(t, err)
+	assert.False(t, avail)
+	assert.Error(t, b.Initialize(context.Background()))
+}
+
+func TestNew_APIKeyFromEnv(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "env-key")
+
+	b := New(nil)
+	assert.Equal(t, "env-key", b.apiKey)
+	assert.NoError(t, b.Initialize(context.Background()))
+}
+
+func TestBackend_Complete(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+		assert.Equal(t, "be brief", req.System)
+		require.Len(t, req.Messages, 1)
+		assert.Equal(t, "user", req.Messages[0].Role)
+		assert.Equal(t, "hello", req.Messages[0].Content[0].Text)
+		assert.Equal(t, 2048, req.MaxTokens)
+		assert.False(t, req.Stream)
+
+		fmt.Fprint(w, `{"type":"message","role":"assistant","content":[{"type":"text","text":"Hi "},{"type":"text","text":"there"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":3}}`)
+	})
+
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{
+		Prompt:       "hello",
+		SystemPrompt: "be brief",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi there", resp.Content)
+	assert.Equal(t, 15, resp.TokensUsed)
+	assert.Equal(t, 12, resp.PromptTokens)
+	assert.Equal(t, 3, resp.CompletionTokens)
+	assert.Equal(t, backend.FinishComplete, resp.FinishReason)
+}
+
+func TestBackend_Complete_APIError(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, _ *messagesRequest) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad model"}}`)
+	})
+
+	_, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "hello"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad model")
+}
+
+func TestBackend_Stream(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+		assert.True(t, req.Stream)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":5,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, e := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", e)
+		}
+	})
+
+	ch, err := b.Stream(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	var content string
+	var final backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		content += chunk.Content
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	assert.Equal(t, "Hello world", content)
+	assert.True(t, final.Done)
+	require.NotNil(t, final.Usage)
+	assert.Equal(t, 5, final.Usage.PromptTokens)
+	assert.Equal(t, 2, final.Usage.CompletionTokens)
+}
+
+func TestBackend_Stream_ConsumerGone(t *testing.T) {
+	closed := make(chan struct{})
+	b, _ := newTestServer(t, func(w http.ResponseWriter, _ *messagesRequest) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"x\"}}\n\n")
+		}
+		w.(http.Flusher).Flush()
+		close(closed)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := b.Stream(ctx, &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	// Read one chunk, then stop consuming and cancel
+	<-ch
+	<-closed
+	cancel()
+
+	// A goroutine stuck on a bare send would still hand over a chunk here
+	time.Sleep(100 * time.Millisecond)
+	_, ok := <-ch
+	assert.False(t, ok, "stream goroutine should exit once ctx is cancelled")
+}
+
+func TestBackend_Stream_ErrorEvent(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, _ *messagesRequest) {
+		fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	})
+
+	ch, err := b.Stream(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	chunk := <-ch
+	require.Error(t, chunk.Error)
+	assert.Contains(t, chunk.Error.Error(), "Overloaded")
+}
+
+func TestBackend_CompleteWithTools(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+		require.Len(t, req.Tools, 1)
+		assert.Equal(t, "read_file", req.Tools[0].Name)
+		assert.Equal(t, "object", req.Tools[0].InputSchema["type"])
+		assert.Equal(t, []any{"path"}, req.Tools[0].InputSchema["required"])
+
+		fmt.Fprint(w, `{"type":"message","role":"assistant","content":[
+			{"type":"text","text":"Let me look."},
+			{"type":"tool_use","id":"toolu_01","name":"read_file","input":{"path":"main.go"}}
+		],"stop_reason":"tool_use","usage":{"input_tokens":40,"output_tokens":20}}`)
+	})
+
+	resp, err := b.CompleteWithTools(context.Background(), &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "what is in main.go?"},
+		Tools: []backend.ToolDefinition{{
+			Name:        "read_file",
+			Description: "Read a file",
+			Parameters: map[string]backend.ToolParameter{
+				"path": {Type: "string", Description: "File path", Required: true},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Let me look.", resp.Content)
+	require.Len(t, resp.ToolCalls, 1)
+	assert.Equal(t, "toolu_01", resp.ToolCalls[0].ID)
+	assert.Equal(t, "read_file", resp.ToolCalls[0].Name)
+	assert.Equal(t, "main.go", resp.ToolCalls[0].Parameters["path"])
+}
+
+func TestBackend_SupportsToolCalling(t *testing.T) {
+	b := New(&Config{APIKey: "test"})
+	assert.True(t, b.SupportsToolCalling())
+}
+
+func TestBackend_SetModel(t *testing.T) {
+	b := New(&Config{APIKey: "test"})
+	b.SetModel("claude-sonnet-4-0")
+	assert.Equal(t, "claude-sonnet-4-0", b.ModelInfo().Name)
+}
+
+func TestFinishReason(t *testing.T) {
+	assert.Equal(t, backend.FinishComplete, finishReason("end_turn"))
+	assert.Equal(t, backend.FinishLength, finishReason("max_tokens"))
+	assert.Equal(t, backend.FinishStop, finishReason("stop_sequence"))
+	assert.Equal(t, backend.FinishStop, finishReason("tool_use"))
+}
+
+func TestBackend_Complete_Messages(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+		assert.Equal(t, "be brief\n\nuse tools", req.System)
+		require.Len(t, req.Messages, 3)
+
+		assert.Equal(t, "user", req.Messages[0].Role)
+
+		assistant := req.Messages[1]
+		assert.Equal(t, "assistant", assistant.Role)
+		require.Len(t, assistant.Content, 2)
+		assert.Equal(t, "tool_use", assistant.Content[0].Type)
+		assert.Equal(t, "toolu_01", assistant.Content[0].ID)
+		assert.JSONEq(t, `{"path":"a.go"}`, string(assistant.Content[0].Input))
+		assert.JSONEq(t, `{}`, string(assistant.Content[1].Input))
+
+		// Both tool results are merged into a single user turn
+		results := req.Messages[2]
+		assert.Equal(t, "user", results.Role)
+		require.Len(t, results.Content, 2)
+		assert.Equal(t, "tool_result", results.Content[0].Type)
+		assert.Equal(t, "toolu_01", results.Content[0].ToolUseID)
+		assert.Equal(t, "package a", results.Content[0].Content)
+		assert.Equal(t, "toolu_02", results.Content[1].ToolUseID)
+
+		fmt.Fprint(w, `{"type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+	})
+
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{
+		SystemPrompt: "be brief",
+		Messages: []backend.Message{
+			{Role: backend.RoleSystem, Content: "use tools"},
+			{Role: backend.RoleUser, Content: "read a.go and list files"},
+			{Role: backend.RoleAssistant, ToolCalls: []backend.ToolCall{
+				{ID: "toolu_01", Name: "read_file", Parameters: map[string]any{"path": "a.go"}},
+				{ID: "toolu_02", Name: "list_files"},
+			}},
+			{Role: backend.RoleTool, ToolCallID: "toolu_01", Content: "package a"},
+			{Role: backend.RoleTool, ToolCallID: "toolu_02", Content: "a.go"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Content)
+}
+
+func TestBackend_Complete_SkipsEmptyAssistantTurn(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+		// The empty assistant turn is dropped and the user turns merge
+		require.Len(t, req.Messages, 1)
+		assert.Equal(t, "user", req.Messages[0].Role)
+		require.Len(t, req.Messages[0].Content, 2)
+		assert.Equal(t, "first", req.Messages[0].Content[0].Text)
+		assert.Equal(t, "second", req.Messages[0].Content[1].Text)
+
+		fmt.Fprint(w, `{"type":"message","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	})
+
+	_, err := b.Complete(context.Background(), &backend.CompletionRequest{
+		Messages: []backend.Message{
+			{Role: backend.RoleUser, Content: "first"},
+			{Role: backend.RoleAssistant},
+			{Role: backend.RoleUser, Content: "second"},
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestBackend_Complete_EmptyRequest(t *testing.T) {
+	b := New(&Config{BaseURL: "http://127.0.0.1:0", APIKey: "test-key"})
+
+	// Rejected locally instead of sending an empty text block
+	_, err := b.Complete(context.Background(), &backend.CompletionRequest{SystemPrompt: "be brief"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "empty request")
+}
+
+func TestBackend_CompleteWithTools_ToolChoice(t *testing.T) {
+	tests := []struct {
+		choice   string
+		expected *toolChoice
+	}{
+		{"", nil},
+		{"auto", nil},
+		{"none", &toolChoice{Type: "none"}},
+		{"required", &toolChoice{Type: "any"}},
+		{"read_file", &toolChoice{Type: "tool", Name: "read_file"}},
+	}
+
+	for _, tt := range tests {
+		b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+			assert.Equal(t, tt.expected, req.ToolChoice, "choice %q", tt.choice)
+			fmt.Fprint(w, `{"type":"message","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+		})
+
+		_, err := b.CompleteWithTools(context.Background(), &backend.ToolRequest{
+			CompletionRequest: backend.CompletionRequest{Prompt: "hi"},
+			Tools:             []backend.ToolDefinition{{Name: "read_file"}},
+			ToolChoice:        tt.choice,
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestBackend_Complete_ResponseFormat(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+		assert.True(t, strings.HasPrefix(req.System, "Be terse.\n\n"))
+		assert.Contains(t, req.System, "JSON Schema")
+		assert.Contains(t, req.System, `"required":["summary"]`)
+		fmt.Fprint(w, `{"type":"message","content":[{"type":"text","text":"{\"summary\":\"ok\"}"}],"stop_reason":"end_turn"}`)
+	})
+
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{
+		Prompt:       "review",
+		SystemPrompt: "Be terse.",
+		ResponseFormat: &backend.ResponseFormat{
+			Type:   backend.ResponseJSONSchema,
+			Schema: map[string]interface{}{"type": "object", "required": []string{"summary"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"summary":"ok"}`, resp.Content)
+}
+
+func TestBackend_Complete_Sampling(t *testing.T) {
+	b, _ := newTestServer(t, func(w http.ResponseWriter, req *messagesRequest) {
+		assert.Equal(t, 0.9, req.TopP)
+		assert.Equal(t, 40, req.TopK)
+		fmt.Fprint(w, `{"type":"message","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	})
+
+	seed := int64(7)
+	_, err := b.Complete(context.Background(), &backend.CompletionRequest{
+		Prompt:   "hi",
+		Sampling: backend.Sampling{TopP: 0.9, TopK: 40, Seed: &seed},
+	})
+	require.NoError(t, err)
+}
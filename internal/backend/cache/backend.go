@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// Backend wraps another backend and serves repeated completions from a Store.
+// Tool calling is passed through uncached since tools have side effects.
+type Backend struct {
+	backend.Backend
+	store *Store
+}
+
+// Wrap returns b with completions cached in store
+func Wrap(b backend.Backend, store *Store) *Backend {
+	return &Backend{Backend: b, store: store}
+}
+
+// Unwrap returns the wrapped backend
+func (b *Backend) Unwrap() backend.Backend {
+	return b.Backend
+}
+
+// key derives the cache key, including the model currently in use
+func (b *Backend) key(req *backend.CompletionRequest) (string, string, error) {
+	model := ""
+	if info := b.Backend.ModelInfo(); info != nil {
+		model = info.Name
+	}
+	key, err := Key(b.Backend.Name(), model, req)
+	return key, model, err
+}
+
+// Complete returns a cached response or runs and caches the completion
+func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	key, model, err := b.key(req)
+	if err != nil {
+		return b.Backend.Complete(ctx, req)
+	}
+
+	if resp, ok := b.store.Get(key); ok {
+		return resp, nil
+	}
+
+	resp, err := b.Backend.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	b.put(key, model, resp)
+	return resp, nil
+}
+
+// Stream replays a cached response as chunks, or streams from the wrapped
+// backend and caches the assembled response once it completes
+func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	key, model, err := b.key(req)
+	if err != nil {
+		return b.Backend.Stream(ctx, req)
+	}
+
+	if resp, ok := b.store.Get(key); ok {
+		return replay(ctx, resp), nil
+	}
+
+	upstream, err := b.Backend.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan backend.StreamChunk)
+	go func() {
+		defer close(ch)
+
+		var content strings.Builder
+		for chunk := range upstream {
+			content.WriteString(chunk.Content)
+
+			if chunk.Done && chunk.Error == nil {
+				resp := &backend.CompletionResponse{
+					Content:      content.String(),
+					FinishReason: backend.FinishComplete,
+				}
+				if chunk.Usage != nil {
+					resp.PromptTokens = chunk.Usage.PromptTokens
+					resp.CompletionTokens = chunk.Usage.CompletionTokens
+					resp.TokensUsed = chunk.Usage.Total()
+				}
+				b.put(key, model, resp)
+			}
+
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				// Drain so the upstream goroutine can exit
+				for range upstream {
+				}
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// put stores a finished response; cache failures never fail the request
+func (b *Backend) put(key, model string, resp *backend.CompletionResponse) {
+	if resp.Content == "" || resp.FinishReason == backend.FinishError {
+		return
+	}
+	if err := b.store.Put(key, b.Backend.Name(), model, resp); err != nil && os.Getenv("SCMD_DEBUG") != "" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] cache: %v\n", err)
+	}
+}
+
+// replay streams a cached response line by line
+func replay(ctx context.Context, resp *backend.CompletionResponse) <-chan backend.StreamChunk {
+	ch := make(chan backend.StreamChunk)
+	go func() {
+		defer close(ch)
+
+		send := func(chunk backend.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, line := range strings.SplitAfter(resp.Content, "\n") {
+			if line != "" && !send(backend.StreamChunk{Content: line}) {
+				return
+			}
+		}
+		send(backend.StreamChunk{
+			Usage: &backend.Usage{
+				PromptTokens:     resp.PromptTokens,
+				CompletionTokens: resp.CompletionTokens,
+			},
+			Done: true,
+		})
+	}()
+	return ch
+}
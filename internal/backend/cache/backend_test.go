@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+)
+
+// countingBackend counts requests that reach the wrapped backend
+type countingBackend struct {
+	*mock.Backend
+	completes int
+	streams   int
+}
+
+func (b *countingBackend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	b.completes++
+	return b.Backend.Complete(ctx, req)
+}
+
+func (b *countingBackend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	b.streams++
+	return b.Backend.Stream(ctx, req)
+}
+
+func collect(t *testing.T, ch <-chan backend.StreamChunk) (string, backend.StreamChunk) {
+	t.Helper()
+	var sb strings.Builder
+	var last backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		sb.WriteString(chunk.Content)
+		last = chunk
+	}
+	return sb.String(), last
+}
+
+func TestBackend_Complete(t *testing.T) {
+	inner := &countingBackend{Backend: mock.New()}
+	inner.SetResponse("hello world")
+	b := Wrap(inner, openTestStore(t, Options{}))
+
+	req := &backend.CompletionRequest{Prompt: "hi"}
+	for i := 0; i < 2; i++ {
+		resp, err := b.Complete(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", resp.Content)
+	}
+	assert.Equal(t, 1, inner.completes)
+
+	// A different prompt misses
+	_, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "other"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.completes)
+
+	assert.Equal(t, "mock", b.Name())
+	assert.Same(t, backend.Backend(inner), b.Unwrap())
+}
+
+func TestBackend_Stream_Replay(t *testing.T) {
+	inner := &countingBackend{Backend: mock.New()}
+	inner.SetResponse("line one\nline two\n")
+	b := Wrap(inner, openTestStore(t, Options{}))
+
+	req := &backend.CompletionRequest{Prompt: "hi"}
+
+	ch, err := b.Stream(context.Background(), req)
+	require.NoError(t, err)
+	content, last := collect(t, ch)
+	assert.Equal(t, "line one\nline two\n", content)
+	assert.True(t, last.Done)
+
+	ch, err = b.Stream(context.Background(), req)
+	require.NoError(t, err)
+
+	var chunks []backend.StreamChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 3, "replayed line by line plus a final chunk")
+	assert.Equal(t, "line one\n", chunks[0].Content)
+	assert.Equal(t, "line two\n", chunks[1].Content)
+	assert.True(t, chunks[2].Done)
+	assert.Equal(t, 1, inner.streams)
+
+	// Streamed results also serve Complete
+	resp, err := b.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "line one\nline two\n", resp.Content)
+	assert.Equal(t, 0, inner.completes)
+}
+
+func TestBackend_Complete_ErrorNotCached(t *testing.T) {
+	inner := &countingBackend{Backend: mock.New()}
+	inner.SetError(assert.AnError)
+	b := Wrap(inner, openTestStore(t, Options{}))
+
+	req := &backend.CompletionRequest{Prompt: "hi"}
+	_, err := b.Complete(context.Background(), req)
+	assert.Error(t, err)
+
+	inner.SetError(nil)
+	inner.SetResponse("recovered")
+	resp, err := b.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "recovered", resp.Content)
+	assert.Equal(t, 2, inner.completes)
+}
@@ -0,0 +1,241 @@
+// Package cache provides a persistent completion cache that wraps any backend
+package cache
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// Defaults used when Options leaves a field zero
+const (
+	DefaultTTL      = 7 * 24 * time.Hour
+	DefaultMaxBytes = 100 * 1024 * 1024
+)
+
+// Options controls expiry and size limits
+type Options struct {
+	TTL      time.Duration // Entries older than this are ignored and pruned
+	MaxBytes int64         // Least recently used entries are evicted above this
+}
+
+// Store keeps completions in the scmd SQLite database
+type Store struct {
+	db   *sql.DB
+	opts Options
+	now  func() time.Time
+}
+
+// Stats describes the cache contents
+type Stats struct {
+	Entries int
+	Bytes   int64
+	Hits    int64
+	Oldest  time.Time
+}
+
+// entry is a cached completion
+type entry struct {
+	Content          string               `json:"content"`
+	PromptTokens     int                  `json:"prompt_tokens"`
+	CompletionTokens int                  `json:"completion_tokens"`
+	FinishReason     backend.FinishReason `json:"finish_reason"`
+}
+
+// Open opens the cache table in dataDir/conversations.db, the database
+// chat history already lives in
+func Open(dataDir string, opts Options) (*Store, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(dataDir, "conversations.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS completion_cache (
+		key TEXT PRIMARY KEY,
+		backend TEXT NOT NULL,
+		model TEXT,
+		response TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		hits INTEGER DEFAULT 0,
+		created_at INTEGER NOT NULL,
+		accessed_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_completion_cache_accessed ON completion_cache(accessed_at);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize cache schema: %w", err)
+	}
+
+	return &Store{db: db, opts: opts, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Key derives the cache key for a request. The whole request is hashed,
+// so new sampling fields are covered without changing this function.
+func Key(backendName, model string, req *backend.CompletionRequest) (string, error) {
+	data, err := json.Marshal(struct {
+		Backend string
+		Model   string
+		Request *backend.CompletionRequest
+	}{backendName, model, req})
+	if err != nil {
+		return "", fmt.Errorf("marshal cache key: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get returns the cached response for key, if present and not expired
+func (s *Store) Get(key string) (*backend.CompletionResponse, bool) {
+	now := s.now()
+
+	var data string
+	var createdAt int64
+	err := s.db.QueryRow(`SELECT response, created_at FROM completion_cache WHERE key = ?`, key).
+		Scan(&data, &createdAt)
+	if err != nil {
+		return nil, false
+	}
+
+	if now.Sub(time.Unix(createdAt, 0)) > s.opts.TTL {
+		_, _ = s.db.Exec(`DELETE FROM completion_cache WHERE key = ?`, key)
+		return nil, false
+	}
+
+	var e entry
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return nil, false
+	}
+
+	_, _ = s.db.Exec(`UPDATE completion_cache SET hits = hits + 1, accessed_at = ? WHERE key = ?`,
+		now.Unix(), key)
+
+	return &backend.CompletionResponse{
+		Content:          e.Content,
+		TokensUsed:       e.PromptTokens + e.CompletionTokens,
+		PromptTokens:     e.PromptTokens,
+		CompletionTokens: e.CompletionTokens,
+		FinishReason:     e.FinishReason,
+	}, true
+}
+
+// Put stores a response and enforces the TTL and size limit
+func (s *Store) Put(key, backendName, model string, resp *backend.CompletionResponse) error {
+	data, err := json.Marshal(entry{
+		Content:          resp.Content,
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		FinishReason:     resp.FinishReason,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal cache entry: %w", err)
+	}
+	if int64(len(data)) > s.opts.MaxBytes {
+		return nil // Would evict everything else; not worth keeping
+	}
+
+	now := s.now().Unix()
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO completion_cache (key, backend, model, response, size, hits, created_at, accessed_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?)
+	`, key, backendName, model, string(data), len(data), now, now)
+	if err != nil {
+		return fmt.Errorf("save cache entry: %w", err)
+	}
+
+	return s.prune()
+}
+
+// prune drops expired entries, then evicts least recently used entries
+// until the cache fits in MaxBytes
+func (s *Store) prune() error {
+	cutoff := s.now().Add(-s.opts.TTL).Unix()
+	if _, err := s.db.Exec(`DELETE FROM completion_cache WHERE created_at < ?`, cutoff); err != nil {
+		return fmt.Errorf("prune cache: %w", err)
+	}
+
+	var total int64
+	if err := s.db.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM completion_cache`).Scan(&total); err != nil {
+		return fmt.Errorf("prune cache: %w", err)
+	}
+	if total <= s.opts.MaxBytes {
+		return nil
+	}
+
+	rows, err := s.db.Query(`SELECT key, size FROM completion_cache ORDER BY accessed_at ASC, created_at ASC`)
+	if err != nil {
+		return fmt.Errorf("prune cache: %w", err)
+	}
+	var evict []string
+	for rows.Next() && total > s.opts.MaxBytes {
+		var key string
+		var size int64
+		if err := rows.Scan(&key, &size); err != nil {
+			rows.Close()
+			return fmt.Errorf("prune cache: %w", err)
+		}
+		evict = append(evict, key)
+		total -= size
+	}
+	rows.Close()
+
+	for _, key := range evict {
+		if _, err := s.db.Exec(`DELETE FROM completion_cache WHERE key = ?`, key); err != nil {
+			return fmt.Errorf("prune cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// Stats returns entry count, total size and hit count
+func (s *Store) Stats() (Stats, error) {
+	var stats Stats
+	var oldest sql.NullInt64
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(hits), 0), MIN(created_at)
+		FROM completion_cache
+	`).Scan(&stats.Entries, &stats.Bytes, &stats.Hits, &oldest)
+	if err != nil {
+		return Stats{}, fmt.Errorf("query cache stats: %w", err)
+	}
+	if oldest.Valid {
+		stats.Oldest = time.Unix(oldest.Int64, 0)
+	}
+	return stats, nil
+}
+
+// Clear removes every cached completion
+func (s *Store) Clear() error {
+	if _, err := s.db.Exec(`DELETE FROM completion_cache`); err != nil {
+		return fmt.Errorf("clear cache: %w", err)
+	}
+	return nil
+}
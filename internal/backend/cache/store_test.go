@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+func openTestStore(t *testing.T, opts Options) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir(), opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestKey(t *testing.T) {
+	req := &backend.CompletionRequest{Prompt: "explain", SystemPrompt: "be brief", Temperature: 0.7}
+
+	k1, err := Key("llamacpp", "qwen3-4b", req)
+	require.NoError(t, err)
+	k2, _ := Key("llamacpp", "qwen3-4b", &backend.CompletionRequest{Prompt: "explain", SystemPrompt: "be brief", Temperature: 0.7})
+	assert.Equal(t, k1, k2)
+
+	for name, other := range map[string]func() (string, error){
+		"backend":     func() (string, error) { return Key("ollama", "qwen3-4b", req) },
+		"model":       func() (string, error) { return Key("llamacpp", "qwen3-1.7b", req) },
+		"prompt":      func() (string, error) { return Key("llamacpp", "qwen3-4b", &backend.CompletionRequest{Prompt: "review", SystemPrompt: "be brief", Temperature: 0.7}) },
+		"system":      func() (string, error) { return Key("llamacpp", "qwen3-4b", &backend.CompletionRequest{Prompt: "explain", Temperature: 0.7}) },
+		"temperature": func() (string, error) { return Key("llamacpp", "qwen3-4b", &backend.CompletionRequest{Prompt: "explain", SystemPrompt: "be brief"}) },
+	} {
+		k, err := other()
+		require.NoError(t, err)
+		assert.NotEqual(t, k1, k, "%s should change the key", name)
+	}
+}
+
+func TestStore_PutGet(t *testing.T) {
+	s := openTestStore(t, Options{})
+
+	_, ok := s.Get("k")
+	assert.False(t, ok)
+
+	require.NoError(t, s.Put("k", "llamacpp", "qwen3-4b", &backend.CompletionResponse{
+		Content:          "cached",
+		PromptTokens:     10,
+		CompletionTokens: 2,
+		FinishReason:     backend.FinishComplete,
+	}))
+
+	resp, ok := s.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, "cached", resp.Content)
+	assert.Equal(t, 10, resp.PromptTokens)
+	assert.Equal(t, 2, resp.CompletionTokens)
+	assert.Equal(t, 12, resp.TokensUsed)
+
+	stats, err := s.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Positive(t, stats.Bytes)
+
+	require.NoError(t, s.Clear())
+	_, ok = s.Get("k")
+	assert.False(t, ok)
+}
+
+func TestStore_TTL(t *testing.T) {
+	s := openTestStore(t, Options{TTL: time.Hour})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Put("k", "llamacpp", "", &backend.CompletionResponse{Content: "old"}))
+
+	now = now.Add(2 * time.Hour)
+	_, ok := s.Get("k")
+	assert.False(t, ok)
+
+	stats, err := s.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Entries, "expired entries are removed")
+}
+
+func TestStore_SizeLimit(t *testing.T) {
+	s := openTestStore(t, Options{MaxBytes: 450})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	content := strings.Repeat("x", 100)
+	for _, key := range []string{"a", "b"} {
+		require.NoError(t, s.Put(key, "llamacpp", "", &backend.CompletionResponse{Content: content}))
+		now = now.Add(time.Second)
+	}
+
+	// Touch "a" so "b" is the least recently used
+	_, ok := s.Get("a")
+	require.True(t, ok)
+	now = now.Add(time.Second)
+
+	require.NoError(t, s.Put("c", "llamacpp", "", &backend.CompletionResponse{Content: content}))
+
+	_, ok = s.Get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+	_, ok = s.Get("a")
+	assert.True(t, ok)
+	_, ok = s.Get("c")
+	assert.True(t, ok)
+}
@@ -0,0 +1,209 @@
+// Package cassette records backend interactions to a file and replays them,
+// for deterministic tests and offline demos without a model
+package cassette
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// Version is the cassette format version
+const Version = 1
+
+// Cassette is a recorded session with one backend
+type Cassette struct {
+	Version      int           `yaml:"version" json:"version"`
+	Backend      string        `yaml:"backend" json:"backend"`
+	Model        string        `yaml:"model,omitempty" json:"model,omitempty"`
+	ToolCalling  bool          `yaml:"tool_calling,omitempty" json:"tool_calling,omitempty"`
+	Interactions []Interaction `yaml:"interactions" json:"interactions"`
+}
+
+// Interaction is one request and the answer it got
+type Interaction struct {
+	Request  Request  `yaml:"request" json:"request"`
+	Response Response `yaml:"response" json:"response"`
+}
+
+// Request is the recorded form of a completion or tool request. Replay
+// matches on every field, so hand-edited cassettes keep working as long
+// as the request is edited consistently.
+type Request struct {
+	SystemPrompt   string                  `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`
+	Prompt         string                  `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+	Messages       []Message               `yaml:"messages,omitempty" json:"messages,omitempty"`
+	MaxTokens      int                     `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`
+	Temperature    float64                 `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	StopSequences  []string                `yaml:"stop,omitempty" json:"stop,omitempty"`
+	Sampling       backend.Sampling        `yaml:"sampling,omitempty" json:"sampling,omitempty"`
+	ResponseFormat *backend.ResponseFormat `yaml:"response_format,omitempty" json:"response_format,omitempty"`
+
+	// Tools and ToolChoice are set for tool-calling requests. Only tool
+	// names are kept; definitions rarely change between runs.
+	Tools      []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+	ToolChoice string   `yaml:"tool_choice,omitempty" json:"tool_choice,omitempty"`
+}
+
+// Message is a recorded conversation turn
+type Message struct {
+	Role       backend.Role `yaml:"role" json:"role"`
+	Content    string       `yaml:"content,omitempty" json:"content,omitempty"`
+	ToolCalls  []ToolCall   `yaml:"tool_calls,omitempty" json:"tool_calls,omitempty"`
+	ToolCallID string       `yaml:"tool_call_id,omitempty" json:"tool_call_id,omitempty"`
+	ToolName   string       `yaml:"tool_name,omitempty" json:"tool_name,omitempty"`
+}
+
+// ToolCall is a recorded tool call
+type ToolCall struct {
+	ID         string                 `yaml:"id,omitempty" json:"id,omitempty"`
+	Name       string                 `yaml:"name" json:"name"`
+	Parameters map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+}
+
+// Response is the recorded answer. Streamed answers also keep their
+// chunks and the delay before each one.
+type Response struct {
+	Content          string               `yaml:"content,omitempty" json:"content,omitempty"`
+	FinishReason     backend.FinishReason `yaml:"finish_reason,omitempty" json:"finish_reason,omitempty"`
+	PromptTokens     int                  `yaml:"prompt_tokens,omitempty" json:"prompt_tokens,omitempty"`
+	CompletionTokens int                  `yaml:"completion_tokens,omitempty" json:"completion_tokens,omitempty"`
+	ToolCalls        []ToolCall           `yaml:"tool_calls,omitempty" json:"tool_calls,omitempty"`
+	Chunks           []Chunk              `yaml:"chunks,omitempty" json:"chunks,omitempty"`
+	Error            string               `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// Chunk is a recorded stream chunk
+type Chunk struct {
+	Content string `yaml:"content" json:"content"`
+	DelayMS int64  `yaml:"delay_ms,omitempty" json:"delay_ms,omitempty"` // Since the previous chunk
+}
+
+// Load reads a cassette; .json files are JSON, anything else YAML
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+
+	var c Cassette
+	if isJSON(path) {
+		err = json.Unmarshal(data, &c)
+	} else {
+		err = yaml.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	if c.Version > Version {
+		return nil, fmt.Errorf("cassette %s has version %d; this scmd reads up to %d", path, c.Version, Version)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette atomically in the format its extension implies
+func (c *Cassette) Save(path string) error {
+	c.Version = Version
+
+	var data []byte
+	var err error
+	if isJSON(path) {
+		data, err = json.MarshalIndent(c, "", "  ")
+	} else {
+		data, err = yaml.Marshal(c)
+	}
+	if err != nil {
+		return fmt.Errorf("marshal cassette: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create cassette directory: %w", err)
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	return nil
+}
+
+// isJSON reports whether path should be read and written as JSON
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// key identifies a request for matching
+func (r *Request) key() string {
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newRequest records a completion request
+func newRequest(req *backend.CompletionRequest) Request {
+	r := Request{
+		SystemPrompt:   req.SystemPrompt,
+		Prompt:         req.Prompt,
+		MaxTokens:      req.MaxTokens,
+		Temperature:    req.Temperature,
+		StopSequences:  req.StopSequences,
+		Sampling:       req.Sampling,
+		ResponseFormat: req.ResponseFormat,
+	}
+	for _, m := range req.Messages {
+		r.Messages = append(r.Messages, Message{
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCalls:  newToolCalls(m.ToolCalls),
+			ToolCallID: m.ToolCallID,
+			ToolName:   m.ToolName,
+		})
+	}
+	return r
+}
+
+// newToolRequest records a tool-calling request
+func newToolRequest(req *backend.ToolRequest) Request {
+	r := newRequest(&req.CompletionRequest)
+	for _, t := range req.Tools {
+		r.Tools = append(r.Tools, t.Name)
+	}
+	r.ToolChoice = req.ToolChoice
+	return r
+}
+
+// newToolCalls records tool calls
+func newToolCalls(calls []backend.ToolCall) []ToolCall {
+	var out []ToolCall
+	for _, c := range calls {
+		out = append(out, ToolCall{ID: c.ID, Name: c.Name, Parameters: c.Parameters})
+	}
+	return out
+}
+
+// toolCalls converts recorded tool calls back
+func toolCalls(calls []ToolCall) []backend.ToolCall {
+	var out []backend.ToolCall
+	for _, c := range calls {
+		params := c.Parameters
+		if params == nil {
+			params = map[string]interface{}{}
+		}
+		out = append(out, backend.ToolCall{ID: c.ID, Name: c.Name, Parameters: params})
+	}
+	return out
+}
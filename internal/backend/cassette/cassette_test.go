@@ -0,0 +1,271 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+)
+
+// toolBackend answers tool requests with a fixed call
+type toolBackend struct {
+	*mock.Backend
+}
+
+func (b *toolBackend) SupportsToolCalling() bool {
+	return true
+}
+
+func (b *toolBackend) CompleteWithTools(_ context.Context, _ *backend.ToolRequest) (*backend.ToolResponse, error) {
+	return &backend.ToolResponse{
+		ToolCalls: []backend.ToolCall{{
+			ID:         "call_1",
+			Name:       "read_file",
+			Parameters: map[string]interface{}{"path": "main.go"},
+		}},
+		Usage: &backend.Usage{PromptTokens: 12, CompletionTokens: 3},
+	}, nil
+}
+
+func collect(t *testing.T, ch <-chan backend.StreamChunk) (string, backend.StreamChunk) {
+	t.Helper()
+	var sb strings.Builder
+	var last backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		sb.WriteString(chunk.Content)
+		last = chunk
+	}
+	return sb.String(), last
+}
+
+func TestRecordAndReplay_Complete(t *testing.T) {
+	for _, name := range []string{"session.yaml", "session.json"} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), name)
+
+			m := mock.New()
+			m.SetResponse("recorded answer")
+			rec, err := Record(m, path)
+			require.NoError(t, err)
+
+			seed := int64(7)
+			req := &backend.CompletionRequest{
+				Prompt:       "explain this",
+				SystemPrompt: "be brief",
+				Sampling:     backend.Sampling{TopP: 0.9, Seed: &seed},
+			}
+			_, err = rec.Complete(ctx, req)
+			require.NoError(t, err)
+
+			player, err := Open(path)
+			require.NoError(t, err)
+			assert.Equal(t, "mock-model", player.ModelInfo().Name)
+
+			resp, err := player.Complete(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, "recorded answer", resp.Content)
+			assert.Equal(t, backend.FinishComplete, resp.FinishReason)
+		})
+	}
+}
+
+func TestRecordAndReplay_Stream(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "stream.yaml")
+
+	m := mock.New()
+	m.SetResponse("a streamed answer in several chunks")
+	rec, err := Record(m, path)
+	require.NoError(t, err)
+
+	req := &backend.CompletionRequest{Prompt: "stream it"}
+	ch, err := rec.Stream(ctx, req)
+	require.NoError(t, err)
+	want, _ := collect(t, ch)
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 1)
+	assert.Len(t, c.Interactions[0].Response.Chunks, 4)
+	assert.Equal(t, want, c.Interactions[0].Response.Content)
+
+	player := NewPlayer(c, path)
+	player.SetRealtime(true)
+	ch, err = player.Stream(ctx, req)
+	require.NoError(t, err)
+	got, last := collect(t, ch)
+	assert.Equal(t, want, got)
+	assert.True(t, last.Done)
+
+	// A streamed recording also answers Complete
+	resp, err := player.Complete(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, want, resp.Content)
+}
+
+func TestReplay_StreamCompleteRecording(t *testing.T) {
+	c := &Cassette{Interactions: []Interaction{{
+		Request:  Request{Prompt: "hi"},
+		Response: Response{Content: "hello", CompletionTokens: 1},
+	}}}
+
+	ch, err := NewPlayer(c, "test").Stream(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+	got, last := collect(t, ch)
+	assert.Equal(t, "hello", got)
+	require.NotNil(t, last.Usage)
+	assert.Equal(t, 1, last.Usage.CompletionTokens)
+}
+
+func TestRecordAndReplay_Tools(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tools.yaml")
+
+	rec, err := Record(&toolBackend{mock.New()}, path)
+	require.NoError(t, err)
+
+	req := &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "what is in main.go?"},
+		Tools:             []backend.ToolDefinition{{Name: "read_file"}},
+	}
+	_, err = rec.CompleteWithTools(ctx, req)
+	require.NoError(t, err)
+
+	player, err := Open(path)
+	require.NoError(t, err)
+	assert.True(t, player.SupportsToolCalling())
+
+	resp, err := player.CompleteWithTools(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.ToolCalls, 1)
+	assert.Equal(t, "call_1", resp.ToolCalls[0].ID)
+	assert.Equal(t, "read_file", resp.ToolCalls[0].Name)
+	assert.Equal(t, "main.go", resp.ToolCalls[0].Parameters["path"])
+	assert.Equal(t, 12, resp.Usage.PromptTokens)
+
+	// The tool list is part of the match
+	_, err = player.CompleteWithTools(ctx, &backend.ToolRequest{CompletionRequest: req.CompletionRequest})
+	assert.Error(t, err)
+}
+
+func TestRecord_Error(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "error.yaml")
+
+	m := mock.New()
+	m.SetError(errors.New("model not loaded"))
+	rec, err := Record(m, path)
+	require.NoError(t, err)
+
+	req := &backend.CompletionRequest{Prompt: "hi"}
+	_, err = rec.Complete(ctx, req)
+	require.Error(t, err)
+
+	player, err := Open(path)
+	require.NoError(t, err)
+	_, err = player.Complete(ctx, req)
+	assert.EqualError(t, err, "model not loaded")
+}
+
+func TestRecord_AppendsToExisting(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.yaml")
+
+	for _, prompt := range []string{"first", "second"} {
+		rec, err := Record(mock.New(), path)
+		require.NoError(t, err)
+		_, err = rec.Complete(ctx, &backend.CompletionRequest{Prompt: prompt})
+		require.NoError(t, err)
+	}
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	assert.Len(t, c.Interactions, 2)
+}
+
+func TestRecord_SkipsCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.yaml")
+	rec, err := Record(mock.New(), path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = rec.Complete(ctx, &backend.CompletionRequest{Prompt: "hi"})
+
+	assert.NoFileExists(t, path)
+}
+
+func TestReplay_RepeatedRequests(t *testing.T) {
+	req := Request{Prompt: "roll a die"}
+	c := &Cassette{Interactions: []Interaction{
+		{Request: req, Response: Response{Content: "3"}},
+		{Request: req, Response: Response{Content: "5"}},
+	}}
+	player := NewPlayer(c, "test")
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		resp, err := player.Complete(context.Background(), &backend.CompletionRequest{Prompt: "roll a die"})
+		require.NoError(t, err)
+		got = append(got, resp.Content)
+	}
+	assert.Equal(t, []string{"3", "5", "5"}, got)
+}
+
+func TestReplay_NoMatch(t *testing.T) {
+	c := &Cassette{Interactions: []Interaction{{
+		Request:  Request{Prompt: "hi"},
+		Response: Response{Content: "hello"},
+	}}}
+	player := NewPlayer(c, "session.yaml")
+
+	_, err := player.Complete(context.Background(), &backend.CompletionRequest{Prompt: "bye"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "session.yaml")
+	assert.Contains(t, err.Error(), `"bye"`)
+
+	// Any difference in the request is a miss
+	_, err = player.Complete(context.Background(), &backend.CompletionRequest{Prompt: "hi", MaxTokens: 10})
+	assert.Error(t, err)
+}
+
+func TestReplay_StreamCancel(t *testing.T) {
+	c := &Cassette{Interactions: []Interaction{{
+		Request: Request{Prompt: "hi"},
+		Response: Response{Chunks: []Chunk{
+			{Content: "a"},
+			{Content: "b", DelayMS: 60000},
+		}},
+	}}}
+	player := NewPlayer(c, "test")
+	player.SetRealtime(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := player.Stream(ctx, &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	first := <-ch
+	assert.Equal(t, "a", first.Content)
+	cancel()
+	for range ch {
+	}
+}
+
+func TestLoad_NewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("version: %d\n", Version+1)), 0644))
+
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "version")
+}
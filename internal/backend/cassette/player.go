@@ -0,0 +1,228 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// Player is a backend that answers from a cassette. Requests are matched
+// exactly; identical requests get their recorded answers in order, and
+// the last answer is repeated once they run out.
+type Player struct {
+	cassette *Cassette
+	path     string
+	realtime bool
+
+	mu      sync.Mutex
+	matches map[string][]int // request key -> interaction indexes
+	played  map[string]int   // request key -> answers given so far
+}
+
+// Open loads the cassette at path for replay
+func Open(path string) (*Player, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayer(c, path), nil
+}
+
+// NewPlayer replays c; path is only used in messages
+func NewPlayer(c *Cassette, path string) *Player {
+	p := &Player{
+		cassette: c,
+		path:     path,
+		matches:  make(map[string][]int),
+		played:   make(map[string]int),
+	}
+	for i := range c.Interactions {
+		key := c.Interactions[i].Request.key()
+		p.matches[key] = append(p.matches[key], i)
+	}
+	return p
+}
+
+// SetRealtime makes Stream wait the recorded delay before each chunk
+func (p *Player) SetRealtime(realtime bool) {
+	p.realtime = realtime
+}
+
+// Name returns the backend name
+func (p *Player) Name() string {
+	return "replay"
+}
+
+// Type returns the backend type
+func (p *Player) Type() backend.Type {
+	return backend.TypeReplay
+}
+
+// Initialize initializes the backend
+func (p *Player) Initialize(_ context.Context) error {
+	return nil
+}
+
+// IsAvailable returns true; a loaded cassette is always available
+func (p *Player) IsAvailable(_ context.Context) (bool, error) {
+	return true, nil
+}
+
+// Shutdown shuts down the backend
+func (p *Player) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// next returns the recorded answer for req
+func (p *Player) next(req Request) (*Response, error) {
+	key := req.key()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	indexes := p.matches[key]
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("cassette %s has no recording for this request (prompt %q, %d messages)",
+			p.path, truncate(req.Prompt, 60), len(req.Messages))
+	}
+
+	n := p.played[key]
+	p.played[key] = n + 1
+	if n >= len(indexes) {
+		n = len(indexes) - 1
+	}
+	return &p.cassette.Interactions[indexes[n]].Response, nil
+}
+
+// Complete returns the recorded response
+func (p *Player) Complete(_ context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	resp, err := p.next(newRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	finish := resp.FinishReason
+	if finish == "" {
+		finish = backend.FinishComplete
+	}
+	return &backend.CompletionResponse{
+		Content:          resp.Content,
+		TokensUsed:       resp.PromptTokens + resp.CompletionTokens,
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		FinishReason:     finish,
+	}, nil
+}
+
+// Stream replays the recorded chunks. Responses recorded without
+// streaming are sent as a single chunk.
+func (p *Player) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	resp, err := p.next(newRequest(req))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := resp.Chunks
+	if len(chunks) == 0 && resp.Content != "" {
+		chunks = []Chunk{{Content: resp.Content}}
+	}
+
+	ch := make(chan backend.StreamChunk)
+	go func() {
+		defer close(ch)
+
+		send := func(chunk backend.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, c := range chunks {
+			if p.realtime && c.DelayMS > 0 {
+				select {
+				case <-time.After(time.Duration(c.DelayMS) * time.Millisecond):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if !send(backend.StreamChunk{Content: c.Content}) {
+				return
+			}
+		}
+
+		if resp.Error != "" {
+			send(backend.StreamChunk{Error: errors.New(resp.Error)})
+			return
+		}
+		send(backend.StreamChunk{
+			ToolCalls: toolCalls(resp.ToolCalls),
+			Usage: &backend.Usage{
+				PromptTokens:     resp.PromptTokens,
+				CompletionTokens: resp.CompletionTokens,
+			},
+			Done: true,
+		})
+	}()
+
+	return ch, nil
+}
+
+// SupportsToolCalling reports whether the recorded backend did
+func (p *Player) SupportsToolCalling() bool {
+	return p.cassette.ToolCalling
+}
+
+// CompleteWithTools returns the recorded tool response
+func (p *Player) CompleteWithTools(_ context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	resp, err := p.next(newToolRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return &backend.ToolResponse{
+		Content:   resp.Content,
+		ToolCalls: toolCalls(resp.ToolCalls),
+		Usage: &backend.Usage{
+			PromptTokens:     resp.PromptTokens,
+			CompletionTokens: resp.CompletionTokens,
+		},
+	}, nil
+}
+
+// ModelInfo describes the recorded model
+func (p *Player) ModelInfo() *backend.ModelInfo {
+	capabilities := []string{backend.CapabilityChat}
+	if p.cassette.ToolCalling {
+		capabilities = append(capabilities, backend.CapabilityToolCalling)
+	}
+	return &backend.ModelInfo{
+		Name:         p.cassette.Model,
+		Capabilities: capabilities,
+	}
+}
+
+// EstimateTokens estimates tokens (rough approximation)
+func (p *Player) EstimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// truncate shortens s for error messages
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,180 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// Recorder wraps a backend and appends every interaction to a cassette
+// file. The file is rewritten after each interaction, so a crash loses
+// at most the request in flight.
+type Recorder struct {
+	backend.Backend
+	path string
+
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+// Record wraps b, appending to the cassette at path if it already exists
+func Record(b backend.Backend, path string) (*Recorder, error) {
+	c, err := Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		c = &Cassette{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.Backend = b.Name()
+	if info := b.ModelInfo(); info != nil {
+		c.Model = info.Name
+	}
+	c.ToolCalling = b.SupportsToolCalling()
+
+	return &Recorder{Backend: b, path: path, cassette: c}, nil
+}
+
+// Unwrap returns the wrapped backend
+func (r *Recorder) Unwrap() backend.Backend {
+	return r.Backend
+}
+
+// Complete runs and records a completion
+func (r *Recorder) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	resp, err := r.Backend.Complete(ctx, req)
+
+	var recorded Response
+	if err != nil {
+		recorded.Error = err.Error()
+	} else {
+		recorded = Response{
+			Content:          resp.Content,
+			FinishReason:     resp.FinishReason,
+			PromptTokens:     resp.PromptTokens,
+			CompletionTokens: resp.CompletionTokens,
+		}
+	}
+	r.add(ctx, newRequest(req), recorded)
+
+	return resp, err
+}
+
+// CompleteWithTools runs and records a tool-calling completion
+func (r *Recorder) CompleteWithTools(ctx context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	resp, err := r.Backend.CompleteWithTools(ctx, req)
+
+	var recorded Response
+	switch {
+	case err != nil:
+		recorded.Error = err.Error()
+	case resp != nil:
+		recorded = Response{
+			Content:   resp.Content,
+			ToolCalls: newToolCalls(resp.ToolCalls),
+		}
+		if resp.Usage != nil {
+			recorded.PromptTokens = resp.Usage.PromptTokens
+			recorded.CompletionTokens = resp.Usage.CompletionTokens
+		}
+	}
+	r.add(ctx, newToolRequest(req), recorded)
+
+	return resp, err
+}
+
+// Stream passes chunks through and records them with their timing once
+// the stream ends
+func (r *Recorder) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	request := newRequest(req)
+
+	upstream, err := r.Backend.Stream(ctx, req)
+	if err != nil {
+		r.add(ctx, request, Response{Error: err.Error()})
+		return nil, err
+	}
+
+	ch := make(chan backend.StreamChunk)
+	go func() {
+		defer close(ch)
+
+		var recorded Response
+		var content strings.Builder
+		saved := false
+		save := func() {
+			if !saved {
+				recorded.Content = content.String()
+				r.add(ctx, request, recorded)
+				saved = true
+			}
+		}
+
+		last := time.Now()
+		for chunk := range upstream {
+			if chunk.Content != "" {
+				now := time.Now()
+				recorded.Chunks = append(recorded.Chunks, Chunk{
+					Content: chunk.Content,
+					DelayMS: now.Sub(last).Milliseconds(),
+				})
+				last = now
+				content.WriteString(chunk.Content)
+			}
+			if chunk.Error != nil {
+				recorded.Error = chunk.Error.Error()
+			}
+			if len(chunk.ToolCalls) > 0 {
+				recorded.ToolCalls = newToolCalls(chunk.ToolCalls)
+			}
+			if chunk.Usage != nil {
+				recorded.PromptTokens = chunk.Usage.PromptTokens
+				recorded.CompletionTokens = chunk.Usage.CompletionTokens
+			}
+
+			// Save before passing the final chunk on, since callers often
+			// exit as soon as they see it
+			if chunk.Done || chunk.Error != nil {
+				if recorded.Error == "" {
+					recorded.FinishReason = backend.FinishComplete
+				}
+				save()
+			}
+
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				// Drain so the upstream goroutine can exit; a cancelled
+				// stream is not recorded
+				for range upstream {
+				}
+				return
+			}
+		}
+		save()
+	}()
+
+	return ch, nil
+}
+
+// add appends an interaction and saves the cassette. Cancelled requests
+// are skipped since their outcome depends on timing.
+func (r *Recorder) add(ctx context.Context, req Request, resp Response) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: req, Response: resp})
+	// Recording must never fail the request itself
+	if err := r.cassette.Save(r.path); err != nil && os.Getenv("SCMD_DEBUG") != "" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] cassette: %v\n", err)
+	}
+}
@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrEmbeddingsUnsupported is returned by Embed for backends without an Embedder
+var ErrEmbeddingsUnsupported = errors.New("backend does not support embeddings")
+
+// Unwrapper is implemented by backends that wrap another backend
+type Unwrapper interface {
+	Unwrap() Backend
+}
+
+// AsEmbedder returns the Embedder behind b, looking through wrappers
+func AsEmbedder(b Backend) (Embedder, bool) {
+	for b != nil {
+		if e, ok := b.(Embedder); ok {
+			return e, true
+		}
+		w, ok := b.(Unwrapper)
+		if !ok {
+			break
+		}
+		b = w.Unwrap()
+	}
+	return nil, false
+}
+
+// Embed embeds texts with b, failing with ErrEmbeddingsUnsupported when b
+// has no Embedder. It also checks that one vector came back per text.
+func Embed(ctx context.Context, b Backend, texts []string) ([][]float32, error) {
+	e, ok := AsEmbedder(b)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", b.Name(), ErrEmbeddingsUnsupported)
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	vectors, err := e.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d inputs", b.Name(), len(vectors), len(texts))
+	}
+	return vectors, nil
+}
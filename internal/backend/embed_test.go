@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type embeddingBackend struct {
+	testBackend
+	dims int
+}
+
+func (b *embeddingBackend) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i := range texts {
+		vectors[i] = make([]float32, b.dims)
+	}
+	return vectors, nil
+}
+
+type wrappingBackend struct {
+	Backend
+}
+
+func (w *wrappingBackend) Unwrap() Backend { return w.Backend }
+
+func TestAsEmbedder(t *testing.T) {
+	_, ok := AsEmbedder(&testBackend{name: "plain"})
+	assert.False(t, ok)
+
+	inner := &embeddingBackend{testBackend: testBackend{name: "inner"}, dims: 3}
+	e, ok := AsEmbedder(&wrappingBackend{Backend: inner})
+	require.True(t, ok)
+	assert.Same(t, inner, e)
+}
+
+func TestEmbed(t *testing.T) {
+	b := &embeddingBackend{testBackend: testBackend{name: "embed"}, dims: 4}
+
+	vectors, err := Embed(context.Background(), b, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Len(t, vectors, 2)
+	assert.Len(t, vectors[0], 4)
+
+	_, err = Embed(context.Background(), &testBackend{name: "plain"}, []string{"a"})
+	assert.ErrorIs(t, err, ErrEmbeddingsUnsupported)
+}
+
+func TestModelInfo_HasCapability(t *testing.T) {
+	info := &ModelInfo{Capabilities: []string{CapabilityChat, CapabilityEmbeddings}}
+	assert.True(t, info.HasCapability(CapabilityEmbeddings))
+	assert.False(t, info.HasCapability(CapabilityToolCalling))
+}
@@ -3,6 +3,7 @@ package backend
 
 import (
 	"context"
+	"sort"
 )
 
 // Backend defines the LLM backend interface
@@ -26,6 +27,9 @@ type Backend interface {
 
 	// Info
 	ModelInfo() *ModelInfo
+
+	// EstimateTokens counts tokens in text. Local backends may ask their
+	// tokenizer over HTTP, so avoid calling it per chunk in hot loops.
 	EstimateTokens(text string) int
 }
 
@@ -38,30 +42,95 @@ const (
 	TypeClaude Type = "claude"
 	TypeOpenAI Type = "openai"
 	TypeMock   Type = "mock"
+	TypeReplay Type = "replay"
 )
 
 // CompletionRequest for inference
 type CompletionRequest struct {
 	Prompt        string
 	SystemPrompt  string
+	Messages      []Message // Role-structured conversation; takes precedence over Prompt
 	MaxTokens     int
 	Temperature   float64
 	StopSequences []string
+
+	// Sampling controls beyond temperature; see Sampling
+	Sampling
+
+	// ResponseFormat constrains the output to JSON; nil means free text
+	ResponseFormat *ResponseFormat
+
+	// Model asks for a specific local model; empty means the backend's
+	// current one. Backends that cannot switch per request ignore it.
+	Model string
+}
+
+// ConversationMessages returns the request as role-structured messages.
+// Prompt-only requests become a single user message, and a Prompt set
+// alongside Messages is appended as the final user turn. SystemPrompt is
+// not included; backends send it through their own system field.
+func (r *CompletionRequest) ConversationMessages() []Message {
+	messages := make([]Message, 0, len(r.Messages)+1)
+	messages = append(messages, r.Messages...)
+	if r.Prompt != "" || len(messages) == 0 {
+		messages = append(messages, Message{Role: RoleUser, Content: r.Prompt})
+	}
+	return messages
+}
+
+// Role identifies the author of a conversation message
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// Message is a single turn in a role-structured conversation
+type Message struct {
+	Role    Role
+	Content string
+
+	// ToolCalls requested by an assistant turn
+	ToolCalls []ToolCall
+
+	// ToolCallID and ToolName identify the call a tool turn answers;
+	// Content holds the tool result
+	ToolCallID string
+	ToolName   string
 }
 
 // CompletionResponse from inference
 type CompletionResponse struct {
-	Content      string
-	TokensUsed   int
-	FinishReason FinishReason
-	Timing       *Timing
+	Content          string
+	TokensUsed       int // PromptTokens + CompletionTokens
+	PromptTokens     int
+	CompletionTokens int
+	FinishReason     FinishReason
+	Timing           *Timing
 }
 
 // StreamChunk for streaming responses
 type StreamChunk struct {
-	Content string
-	Done    bool
-	Error   error
+	Content   string
+	ToolCalls []ToolCall // Set on the final chunk of a tool-calling stream
+	Timing    *Timing    // Set on the final chunk when the backend reports it
+	Usage     *Usage     // Set on the final chunk when the backend reports it
+	Done      bool
+	Error     error
+}
+
+// Usage reports token counts for a completed request
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Total returns the combined prompt and completion token count
+func (u *Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
 }
 
 // FinishReason why generation stopped
@@ -81,6 +150,15 @@ type Timing struct {
 	TokensPerSec float64
 }
 
+// Capabilities reported in ModelInfo.Capabilities
+const (
+	CapabilityText        = "text"
+	CapabilityCode        = "code"
+	CapabilityChat        = "chat"
+	CapabilityToolCalling = "tool_calling"
+	CapabilityEmbeddings  = "embeddings"
+)
+
 // ModelInfo describes the loaded model
 type ModelInfo struct {
 	Name          string
@@ -90,10 +168,38 @@ type ModelInfo struct {
 	Capabilities  []string
 }
 
+// HasCapability reports whether the model lists capability
+func (m *ModelInfo) HasCapability(capability string) bool {
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 // ToolRequest for tool-calling inference
 type ToolRequest struct {
 	CompletionRequest
 	Tools []ToolDefinition
+
+	// ToolChoice is "auto" (default), "none", "required", or a tool name.
+	// The OpenAI and Claude backends honour every value; Ollama and llama.cpp only
+	// honour "none" and treat the rest as "auto".
+	ToolChoice string
+}
+
+// Embedder is implemented by backends that can embed text. Use AsEmbedder
+// to look for it, since wrappers such as the completion cache hide it.
+type Embedder interface {
+	// Embed returns one vector per input text, in input order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ToolStreamer is implemented by backends that can stream tool-calling
+// completions. Tool calls are delivered on the final chunk.
+type ToolStreamer interface {
+	StreamWithTools(ctx context.Context, req *ToolRequest) (<-chan StreamChunk, error)
 }
 
 // ToolDefinition defines a tool for the LLM
@@ -103,6 +209,39 @@ type ToolDefinition struct {
 	Parameters  map[string]ToolParameter
 }
 
+// JSONSchema returns the tool parameters as a JSON Schema object
+func (d ToolDefinition) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(d.Parameters))
+	required := []string{}
+
+	for name, param := range d.Parameters {
+		prop := map[string]interface{}{
+			"type": param.Type,
+		}
+		if param.Description != "" {
+			prop["description"] = param.Description
+		}
+		if len(param.Enum) > 0 {
+			prop["enum"] = param.Enum
+		}
+		properties[name] = prop
+
+		if param.Required {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
 // ToolParameter defines a tool parameter
 type ToolParameter struct {
 	Type        string
@@ -115,10 +254,12 @@ type ToolParameter struct {
 type ToolResponse struct {
 	Content   string
 	ToolCalls []ToolCall
+	Usage     *Usage
 }
 
 // ToolCall represents an LLM's request to call a tool
 type ToolCall struct {
+	ID         string // Provider-assigned call ID, if any
 	Name       string
 	Parameters map[string]interface{}
 }
@@ -0,0 +1,59 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionRequest_ConversationMessages_PromptOnly(t *testing.T) {
+	req := &CompletionRequest{Prompt: "hello", SystemPrompt: "be brief"}
+
+	messages := req.ConversationMessages()
+	assert.Equal(t, []Message{{Role: RoleUser, Content: "hello"}}, messages)
+}
+
+func TestCompletionRequest_ConversationMessages_Messages(t *testing.T) {
+	req := &CompletionRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: "hi"},
+			{Role: RoleAssistant, Content: "hello"},
+		},
+	}
+
+	messages := req.ConversationMessages()
+	assert.Len(t, messages, 2)
+	assert.Equal(t, RoleAssistant, messages[1].Role)
+}
+
+func TestCompletionRequest_ConversationMessages_MessagesAndPrompt(t *testing.T) {
+	history := []Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}}
+	req := &CompletionRequest{Messages: history, Prompt: "again"}
+
+	messages := req.ConversationMessages()
+	assert.Len(t, messages, 3)
+	assert.Equal(t, Message{Role: RoleUser, Content: "again"}, messages[2])
+
+	// The caller's slice must not be modified
+	assert.Len(t, req.Messages, 2)
+}
+
+func TestToolDefinition_JSONSchema(t *testing.T) {
+	def := ToolDefinition{
+		Name: "shell",
+		Parameters: map[string]ToolParameter{
+			"command": {Type: "string", Description: "Command to run", Required: true},
+			"shell":   {Type: "string", Enum: []string{"bash", "sh"}},
+			"cwd":     {Type: "string", Required: true},
+		},
+	}
+
+	schema := def.JSONSchema()
+	assert.Equal(t, "object", schema["type"])
+	assert.Equal(t, []string{"command", "cwd"}, schema["required"])
+
+	props := schema["properties"].(map[string]interface{})
+	assert.Len(t, props, 3)
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []string{"bash", "sh"}}, props["shell"])
+	assert.Equal(t, "Command to run", props["command"].(map[string]interface{})["description"])
+}
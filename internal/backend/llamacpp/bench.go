@@ -0,0 +1,330 @@
+package llamacpp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBenchPort keeps benchmark servers away from the server pool
+const DefaultBenchPort = 8199
+
+// rssSampleInterval is how often a benchmark server's memory is read
+const rssSampleInterval = 250 * time.Millisecond
+
+// BenchPrompt is one prompt of the benchmark suite
+type BenchPrompt struct {
+	Name   string
+	Prompt string
+}
+
+// BenchPrompts is the fixed benchmark suite: a short question, a code task
+// and a long document, so both prompt processing and generation count
+var BenchPrompts = []BenchPrompt{
+	{
+		Name:   "question",
+		Prompt: "What does the Unix command `find . -name '*.go' -mtime -1` do? Answer briefly.",
+	},
+	{
+		Name:   "code",
+		Prompt: "Write a Go function that parses a duration such as \"1h30m\" or \"2d\" and returns a time.Duration, with tests.",
+	},
+	{
+		Name:   "summary",
+		Prompt: "Summarize the following log in three bullet points:\n\n" + strings.Repeat(benchLogLines, 12),
+	},
+}
+
+const benchLogLines = `2026-03-02T10:14:07Z INFO  http: GET /api/v1/users 200 12ms
+2026-03-02T10:14:08Z WARN  db: slow query (812ms): SELECT * FROM orders WHERE status = 'pending'
+2026-03-02T10:14:09Z ERROR worker: job 4411 failed: context deadline exceeded
+2026-03-02T10:14:11Z INFO  worker: retrying job 4411 (attempt 2 of 5)
+`
+
+// BenchResult is the throughput of one model with one server
+// configuration, averaged over the prompt suite
+type BenchResult struct {
+	Model        string    `json:"model"`
+	ModelPath    string    `json:"model_path"`
+	Threads      int       `json:"threads"`
+	ContextSize  int       `json:"context_size"`
+	GPULayers    int       `json:"gpu_layers"`
+	PromptTPS    float64   `json:"prompt_tokens_per_sec"`
+	GenTPS       float64   `json:"generation_tokens_per_sec"`
+	TTFTMillis   int64     `json:"ttft_ms"`
+	PeakRSSBytes int64     `json:"peak_rss_bytes,omitempty"` // 0 if unknown
+	Prompts      int       `json:"prompts"`
+	Machine      string    `json:"machine"`
+	Time         time.Time `json:"time"`
+}
+
+// benchSample is the measurement of one prompt
+type benchSample struct {
+	promptTPS float64
+	genTPS    float64
+	ttft      time.Duration
+}
+
+// BenchConfigs returns the server configurations to benchmark modelPath
+// with, one per thread count and context size. GPU offload comes from
+// CalculateOptimalConfig. Without explicit lists, threads sweep half and
+// all CPUs, plus the count used when every layer is offloaded, and
+// contexts are 4096 and the model's native size up to 32768.
+func BenchConfigs(modelPath string, threads, contexts []int) []*ServerConfig {
+	base := &ServerConfig{ModelPath: modelPath, GPULayers: 99}
+	if resources, err := DetectSystemResources(); err == nil {
+		var size int64
+		if info, err := os.Stat(modelPath); err == nil {
+			size = info.Size()
+		}
+		base.GPULayers = CalculateOptimalConfig(resources, size).GPULayers
+	}
+	meta, _ := ReadGGUFMetadata(modelPath)
+
+	if len(threads) == 0 {
+		threads = []int{runtime.NumCPU() / 2, runtime.NumCPU()}
+		if meta != nil {
+			tuned := *base
+			tuned.TuneForModel(meta)
+			threads = append(threads, tuned.Threads)
+		}
+	}
+	if len(contexts) == 0 {
+		native := 32768
+		if meta != nil && meta.ContextLength > 0 && meta.ContextLength < native {
+			native = meta.ContextLength
+		}
+		contexts = []int{4096, native}
+	}
+
+	var configs []*ServerConfig
+	for _, t := range uniquePositive(threads) {
+		for _, c := range uniquePositive(contexts) {
+			config := *base
+			config.Threads = t
+			config.ContextSize = c
+			configs = append(configs, &config)
+		}
+	}
+	return configs
+}
+
+// uniquePositive sorts ns and drops duplicates and values below 1
+func uniquePositive(ns []int) []int {
+	sorted := append([]int(nil), ns...)
+	sort.Ints(sorted)
+	var out []int
+	for _, n := range sorted {
+		if n > 0 && (len(out) == 0 || out[len(out)-1] != n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Bench starts llama-server with config, runs the prompt suite with up to
+// maxTokens generated per prompt, and stops the server again
+func Bench(ctx context.Context, config *ServerConfig, maxTokens int) (*BenchResult, error) {
+	server, err := launchServer(config)
+	if err != nil {
+		return nil, err
+	}
+	defer server.Stop()
+
+	// Sample memory while the suite runs; on Linux the kernel keeps the peak
+	var peak int64
+	var peakMu sync.Mutex
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(rssSampleInterval)
+		defer ticker.Stop()
+		for {
+			if rss, err := processRSS(server.cmd.Process.Pid); err == nil {
+				peakMu.Lock()
+				if rss > peak {
+					peak = rss
+				}
+				peakMu.Unlock()
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", server.Port())
+	var samples []benchSample
+	for _, p := range BenchPrompts {
+		sample, err := benchPrompt(ctx, baseURL, p.Prompt, maxTokens)
+		if err != nil {
+			close(done)
+			<-sampled
+			return nil, fmt.Errorf("prompt %q: %w", p.Name, err)
+		}
+		samples = append(samples, sample)
+	}
+	close(done)
+	<-sampled
+
+	result := &BenchResult{
+		Model:       modelName(config.ModelPath),
+		ModelPath:   config.ModelPath,
+		Threads:     config.Threads,
+		ContextSize: config.ContextSize,
+		GPULayers:   config.GPULayers,
+		Prompts:     len(samples),
+		Machine:     MachineClass(),
+		Time:        time.Now(),
+	}
+	var ttft time.Duration
+	for _, s := range samples {
+		result.PromptTPS += s.promptTPS / float64(len(samples))
+		result.GenTPS += s.genTPS / float64(len(samples))
+		ttft += s.ttft / time.Duration(len(samples))
+	}
+	result.TTFTMillis = ttft.Milliseconds()
+	peakMu.Lock()
+	result.PeakRSSBytes = peak
+	peakMu.Unlock()
+	return result, nil
+}
+
+// benchPrompt streams one completion from the server at baseURL and
+// measures it. Generation ignores end-of-sequence so every model produces
+// maxTokens tokens.
+func benchPrompt(ctx context.Context, baseURL, prompt string, maxTokens int) (benchSample, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"prompt":       prompt,
+		"n_predict":    maxTokens,
+		"stream":       true,
+		"temperature":  0,
+		"seed":         42,
+		"cache_prompt": false,
+		"ignore_eos":   true,
+	})
+	if err != nil {
+		return benchSample{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/completion", bytes.NewReader(body))
+	if err != nil {
+		return benchSample{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	start := time.Now()
+	resp, err := (&http.Client{}).Do(httpReq)
+	if err != nil {
+		return benchSample{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return benchSample{}, fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var sample benchSample
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			return benchSample{}, fmt.Errorf("parse stream event: %w", err)
+		}
+		if event.Error != nil {
+			return benchSample{}, fmt.Errorf("llama-server: %s", event.Error.Message)
+		}
+		if sample.ttft == 0 && event.Content != "" {
+			sample.ttft = time.Since(start)
+		}
+		if event.Stop {
+			if t := event.Timings; t != nil {
+				if t.PromptMS > 0 {
+					sample.promptTPS = float64(t.PromptN) * 1000 / t.PromptMS
+				}
+				sample.genTPS = t.PredictedPerSecond
+			}
+			return sample, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return benchSample{}, err
+	}
+	return benchSample{}, io.ErrUnexpectedEOF
+}
+
+// MachineClass describes this machine for comparing benchmark results,
+// e.g. "darwin/arm64, 10 CPUs, 16.0 GB, Metal"
+func MachineClass() string {
+	class := fmt.Sprintf("%s/%s, %d CPUs", runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+	if resources, err := DetectSystemResources(); err == nil {
+		class += ", " + FormatBytes(resources.TotalRAMBytes)
+		if resources.HasGPU {
+			class += ", " + resources.GPUType
+		}
+	}
+	return class
+}
+
+// benchFilePath is where benchmark results are kept
+func benchFilePath(dataDir string) string {
+	return filepath.Join(dataDir, "bench.json")
+}
+
+// LoadBenchResults returns the stored benchmark results, oldest first
+func LoadBenchResults(dataDir string) ([]BenchResult, error) {
+	data, err := os.ReadFile(benchFilePath(dataDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BenchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", benchFilePath(dataDir), err)
+	}
+	return results, nil
+}
+
+// SaveBenchResult appends r to the stored benchmark results
+func SaveBenchResult(dataDir string, r *BenchResult) error {
+	results, err := LoadBenchResults(dataDir)
+	if err != nil {
+		return err
+	}
+	results = append(results, *r)
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(benchFilePath(dataDir), data, 0644)
+}
@@ -0,0 +1,75 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchPrompt(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"content\":\"Hello\"}\n\n")
+		fmt.Fprint(w, "data: {\"content\":\" world\"}\n\n")
+		fmt.Fprint(w, "data: {\"content\":\"\",\"stop\":true,\"timings\":{\"prompt_n\":50,\"prompt_ms\":100,\"predicted_n\":64,\"predicted_ms\":2000,\"predicted_per_second\":32}}\n\n")
+	}))
+	defer srv.Close()
+
+	sample, err := benchPrompt(context.Background(), srv.URL, "Hi", 64)
+	require.NoError(t, err)
+	assert.Equal(t, 500.0, sample.promptTPS)
+	assert.Equal(t, 32.0, sample.genTPS)
+	assert.Greater(t, sample.ttft, time.Duration(0))
+
+	assert.Equal(t, float64(64), got["n_predict"])
+	assert.Equal(t, true, got["ignore_eos"])
+	assert.Equal(t, false, got["cache_prompt"])
+}
+
+func TestBenchPrompt_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"error\":{\"message\":\"context overflow\"}}\n\n")
+	}))
+	defer srv.Close()
+
+	_, err := benchPrompt(context.Background(), srv.URL, "Hi", 64)
+	assert.ErrorContains(t, err, "context overflow")
+}
+
+func TestBenchConfigs(t *testing.T) {
+	configs := BenchConfigs("/nonexistent/model.gguf", []int{8, 4, 8, 0}, []int{8192, 4096})
+	require.Len(t, configs, 4)
+
+	var got [][2]int
+	for _, c := range configs {
+		got = append(got, [2]int{c.Threads, c.ContextSize})
+		assert.Equal(t, "/nonexistent/model.gguf", c.ModelPath)
+	}
+	assert.Equal(t, [][2]int{{4, 4096}, {4, 8192}, {8, 4096}, {8, 8192}}, got)
+}
+
+func TestBenchResults_SaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	results, err := LoadBenchResults(dir)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	require.NoError(t, SaveBenchResult(dir, &BenchResult{Model: "qwen3-4b", Threads: 4, GenTPS: 21.5}))
+	require.NoError(t, SaveBenchResult(dir, &BenchResult{Model: "qwen3-1.7b", Threads: 8, GenTPS: 48}))
+
+	results, err = LoadBenchResults(dir)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "qwen3-4b", results[0].Model)
+	assert.Equal(t, 48.0, results[1].GenTPS)
+}
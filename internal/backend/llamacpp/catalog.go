@@ -0,0 +1,138 @@
+package llamacpp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogFile is the user model catalog in the data directory
+const CatalogFile = "models.yaml"
+
+// catalog is the layout of CatalogFile
+type catalog struct {
+	Models []Model `yaml:"models"`
+}
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// LoadCatalog reads the user models defined in dataDir/models.yaml. A
+// missing file is an empty catalog.
+func LoadCatalog(dataDir string) ([]Model, error) {
+	path := filepath.Join(dataDir, CatalogFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read model catalog: %w", err)
+	}
+
+	var c catalog
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse model catalog %s: %w", path, err)
+	}
+	for i := range c.Models {
+		if err := c.Models[i].Validate(); err != nil {
+			return nil, fmt.Errorf("model catalog %s: %w", path, err)
+		}
+	}
+	return c.Models, nil
+}
+
+// AddToCatalog adds model to dataDir/models.yaml, replacing any entry
+// with the same name
+func AddToCatalog(dataDir string, model Model) error {
+	if err := model.Validate(); err != nil {
+		return err
+	}
+
+	models, err := LoadCatalog(dataDir)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range models {
+		if models[i].Name == model.Name {
+			models[i] = model
+			replaced = true
+		}
+	}
+	if !replaced {
+		models = append(models, model)
+	}
+
+	data, err := yaml.Marshal(catalog{Models: models})
+	if err != nil {
+		return fmt.Errorf("marshal model catalog: %w", err)
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("create data directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dataDir, CatalogFile), data, 0644)
+}
+
+// Validate checks a catalog entry
+func (m *Model) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("model has no name")
+	}
+	if (m.URL == "") == (m.Path == "") {
+		return fmt.Errorf("model %s: exactly one of url and path must be set", m.Name)
+	}
+	if m.URL != "" {
+		u, err := url.Parse(m.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("model %s: url must be an http(s) URL, got %q", m.Name, m.URL)
+		}
+	}
+	if len(m.Mirrors) > 0 && m.URL == "" {
+		return fmt.Errorf("model %s: mirrors need a url", m.Name)
+	}
+	for _, mirror := range m.Mirrors {
+		u, err := url.Parse(mirror)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("model %s: mirrors must be http(s) URLs, got %q", m.Name, mirror)
+		}
+	}
+	if m.SHA256 != "" && !sha256Pattern.MatchString(m.SHA256) {
+		return fmt.Errorf("model %s: sha256 must be 64 hex characters", m.Name)
+	}
+	if m.ContextSize < 0 {
+		return fmt.Errorf("model %s: context_size must not be negative", m.Name)
+	}
+	if _, ok := ChatTemplateByName(m.ChatTemplate); m.ChatTemplate != "" && !ok {
+		return fmt.Errorf("model %s: unknown chat_template %q (known: %s)",
+			m.Name, m.ChatTemplate, strings.Join(ChatTemplateNames(), ", "))
+	}
+	return nil
+}
+
+// mergeModels returns defaults with the user models added; a user model
+// with a built-in name replaces the built-in one
+func mergeModels(defaults, user []Model) []Model {
+	models := make([]Model, 0, len(defaults)+len(user))
+	models = append(models, defaults...)
+
+	for _, u := range user {
+		replaced := false
+		for i := range models {
+			if models[i].Name == u.Name {
+				models[i] = u
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			models = append(models, u)
+		}
+	}
+	return models
+}
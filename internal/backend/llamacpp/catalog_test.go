@@ -0,0 +1,146 @@
+package llamacpp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+func TestLoadCatalog_Missing(t *testing.T) {
+	models, err := LoadCatalog(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, models)
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir := t.TempDir()
+	catalog := `models:
+  - name: llama3.2-3b
+    url: https://example.com/llama-3.2-3b-q4_k_m.gguf
+    sha256: ` + strings.Repeat("ab", 32) + `
+    context_size: 131072
+    tool_calling: true
+    chat_template: llama3
+    mirrors:
+      - https://mirror.example.org/llama-3.2-3b-q4_k_m.gguf
+  - name: my-finetune
+    path: /models/finetune.gguf
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, CatalogFile), []byte(catalog), 0644))
+
+	models, err := LoadCatalog(dir)
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	assert.Equal(t, "llama3.2-3b", models[0].Name)
+	assert.Equal(t, 131072, models[0].ContextSize)
+	assert.True(t, models[0].ToolCalling)
+	assert.Equal(t, "llama3", models[0].ChatTemplate)
+	assert.Equal(t, []string{
+		"https://example.com/llama-3.2-3b-q4_k_m.gguf",
+		"https://mirror.example.org/llama-3.2-3b-q4_k_m.gguf",
+	}, models[0].URLs())
+	assert.Equal(t, "/models/finetune.gguf", models[1].Path)
+}
+
+func TestLoadCatalog_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"no source":    "models:\n  - name: x\n",
+		"both":         "models:\n  - name: x\n    url: https://example.com/x.gguf\n    path: /x.gguf\n",
+		"bad url":      "models:\n  - name: x\n    url: ftp://example.com/x.gguf\n",
+		"bad mirror":   "models:\n  - name: x\n    url: https://example.com/x.gguf\n    mirrors: [mirror.example.org]\n",
+		"mirror only":  "models:\n  - name: x\n    path: /x.gguf\n    mirrors: [https://example.com/x.gguf]\n",
+		"bad sha256":   "models:\n  - name: x\n    path: /x.gguf\n    sha256: abc\n",
+		"no name":      "models:\n  - path: /x.gguf\n",
+		"bad template": "models:\n  - name: x\n    path: /x.gguf\n    chat_template: vicuna\n",
+		"not a model":  "models: 3\n",
+	}
+	for name, catalog := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, CatalogFile), []byte(catalog), 0644))
+			_, err := LoadCatalog(dir)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAddToCatalog(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, AddToCatalog(dir, Model{Name: "a", Path: "/a.gguf"}))
+	require.NoError(t, AddToCatalog(dir, Model{Name: "b", URL: "https://example.com/b.gguf"}))
+	require.NoError(t, AddToCatalog(dir, Model{Name: "a", Path: "/a2.gguf"}))
+
+	models, err := LoadCatalog(dir)
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	assert.Equal(t, "/a2.gguf", models[0].Path)
+	assert.Equal(t, "b", models[1].Name)
+
+	assert.Error(t, AddToCatalog(dir, Model{Name: "c"}))
+}
+
+func TestModelManager_Catalog(t *testing.T) {
+	dir := t.TempDir()
+	modelFile := filepath.Join(dir, "custom.gguf")
+	require.NoError(t, os.WriteFile(modelFile, []byte("GGUF"), 0644))
+
+	require.NoError(t, AddToCatalog(dir, Model{Name: "custom", Path: modelFile, ContextSize: 4096}))
+	require.NoError(t, AddToCatalog(dir, Model{
+		Name:        DefaultModels[0].Name,
+		URL:         "https://example.com/override.gguf",
+		ContextSize: 1024,
+	}))
+
+	mgr := NewModelManager(dir)
+	assert.Len(t, mgr.ListModels(), len(DefaultModels)+1)
+
+	override, ok := mgr.FindModel(DefaultModels[0].Name)
+	require.True(t, ok)
+	assert.Equal(t, 1024, override.ContextSize)
+
+	path, err := mgr.GetModelPath(context.Background(), "custom")
+	require.NoError(t, err)
+	assert.Equal(t, modelFile, path)
+
+	assert.Error(t, mgr.DeleteModel("custom"), "local files are never deleted")
+	assert.FileExists(t, modelFile)
+}
+
+func TestBackend_CatalogModelInfo(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, AddToCatalog(dir, Model{Name: "plain", Path: "/plain.gguf", ContextSize: 8192}))
+
+	b := New(dir)
+	require.NoError(t, b.SetModel("plain"))
+	assert.Equal(t, 8192, b.GetContextSize())
+
+	info := b.ModelInfo()
+	assert.Equal(t, 8192, info.ContextLength)
+	assert.False(t, info.HasCapability(backend.CapabilityToolCalling))
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	require.NoError(t, os.WriteFile(path, []byte("GGUF"), 0644))
+	sum := sha256.Sum256([]byte("GGUF"))
+	hash := hex.EncodeToString(sum[:])
+
+	assert.NoError(t, VerifyChecksum(path, hash))
+	assert.NoError(t, VerifyChecksum(path, strings.ToUpper(hash)))
+	assert.Error(t, VerifyChecksum(path, strings.Repeat("0", 64)))
+}
+
+func TestModel_Filename(t *testing.T) {
+	assert.Equal(t, "qwen-q4_k_m.gguf", (&Model{Name: "qwen", Variant: "q4_k_m"}).Filename())
+	assert.Equal(t, "custom.gguf", (&Model{Name: "custom"}).Filename())
+}
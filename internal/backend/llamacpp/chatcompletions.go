@@ -0,0 +1,266 @@
+package llamacpp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// chatCompletionBody builds a llama-server /v1/chat/completions request
+// body, used when the server applies the model's own chat template
+func chatCompletionBody(turns []chatTurn, req *backend.CompletionRequest, stream bool) map[string]interface{} {
+	reqBody := map[string]interface{}{
+		"messages":    turns,
+		"max_tokens":  req.MaxTokens,
+		"temperature": req.Temperature,
+		"stream":      stream,
+	}
+
+	if req.MaxTokens == 0 {
+		reqBody["max_tokens"] = 2048
+	}
+	if req.Temperature == 0 {
+		reqBody["temperature"] = 0.7
+	}
+	if len(req.StopSequences) > 0 {
+		reqBody["stop"] = req.StopSequences
+	}
+	setSamplingParams(reqBody, req.Sampling)
+
+	// llama-server reads the same grammar fields here as on /completion
+	if f := req.ResponseFormat; f != nil {
+		if f.Type == backend.ResponseJSONSchema && f.Schema != nil {
+			reqBody["json_schema"] = f.Schema
+		} else {
+			reqBody["grammar"] = jsonObjectGrammar
+		}
+	}
+
+	return reqBody
+}
+
+// chatCompletionResponse is a /v1/chat/completions response or stream chunk
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
+	Timings *serverTimings `json:"timings,omitempty"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// finishReason maps an OpenAI-style finish reason
+func chatFinishReason(reason string) backend.FinishReason {
+	switch reason {
+	case "length":
+		return backend.FinishLength
+	default:
+		return backend.FinishComplete
+	}
+}
+
+// toResult converts a non-streaming response
+func (r *chatCompletionResponse) toResult() (*CompletionResult, error) {
+	if len(r.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from server")
+	}
+
+	choice := r.Choices[0]
+	result := &CompletionResult{
+		Content:      strings.TrimSpace(choice.Message.Content),
+		FinishReason: backend.FinishComplete,
+		Timing:       r.Timings.toTiming(),
+	}
+	if choice.FinishReason != nil {
+		result.FinishReason = chatFinishReason(*choice.FinishReason)
+	}
+	if r.Usage != nil {
+		result.PromptTokens = r.Usage.PromptTokens
+		result.CompletionTokens = r.Usage.CompletionTokens
+	} else if r.Timings != nil {
+		result.PromptTokens = r.Timings.PromptN
+		result.CompletionTokens = r.Timings.PredictedN
+	}
+	return result, nil
+}
+
+// runChatInference runs a completion through /v1/chat/completions
+func (b *Backend) runChatInference(ctx context.Context, turns []chatTurn, req *backend.CompletionRequest) (*CompletionResult, error) {
+	baseURL, release, err := b.acquireServer()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	jsonBody, err := json.Marshal(chatCompletionBody(turns, req, false))
+	if err != nil {
+		return nil, ParseError(err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, ParseError(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// CPU-only mode is much slower, so use longer timeout
+	timeout := 2 * time.Minute
+	if os.Getenv("SCMD_CPU_ONLY") != "" {
+		timeout = 10 * time.Minute
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(httpReq)
+	if err != nil {
+		return nil, ParseError(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ParseError(fmt.Errorf("read response: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, ParseError(fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody)))
+	}
+
+	var result chatCompletionResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, ParseError(fmt.Errorf("parse response: %w", err))
+	}
+	completion, err := result.toResult()
+	if err != nil {
+		return nil, ParseError(err)
+	}
+	return completion, nil
+}
+
+// runChatStream streams a completion from /v1/chat/completions. Like
+// runServerStream, cancelling ctx aborts generation server-side.
+func (b *Backend) runChatStream(ctx context.Context, turns []chatTurn, req *backend.CompletionRequest, ch chan<- backend.StreamChunk) {
+	send := func(chunk backend.StreamChunk) bool {
+		select {
+		case ch <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	baseURL, release, err := b.acquireServer()
+	if err != nil {
+		send(backend.StreamChunk{Error: err})
+		return
+	}
+	defer release()
+
+	jsonBody, err := json.Marshal(chatCompletionBody(turns, req, true))
+	if err != nil {
+		send(backend.StreamChunk{Error: ParseError(err)})
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		send(backend.StreamChunk{Error: ParseError(err)})
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := (&http.Client{}).Do(httpReq)
+	if err != nil {
+		send(backend.StreamChunk{Error: ParseError(err)})
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		send(backend.StreamChunk{Error: ParseError(fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody)))})
+		return
+	}
+
+	final := backend.StreamChunk{Done: true}
+	finished := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			send(final)
+			return
+		}
+
+		var event chatCompletionResponse
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			send(backend.StreamChunk{Error: ParseError(fmt.Errorf("parse stream event: %w", err))})
+			return
+		}
+		if event.Error != nil {
+			send(backend.StreamChunk{Error: ParseError(fmt.Errorf("llama-server: %s", event.Error.Message))})
+			return
+		}
+
+		// Usage and timings may arrive on the finishing chunk or after it
+		if event.Timings != nil {
+			final.Timing = event.Timings.toTiming()
+			final.Usage = &backend.Usage{
+				PromptTokens:     event.Timings.PromptN,
+				CompletionTokens: event.Timings.PredictedN,
+			}
+		}
+		if event.Usage != nil {
+			final.Usage = &backend.Usage{
+				PromptTokens:     event.Usage.PromptTokens,
+				CompletionTokens: event.Usage.CompletionTokens,
+			}
+		}
+
+		for _, choice := range event.Choices {
+			if choice.Delta.Content != "" && !send(backend.StreamChunk{Content: choice.Delta.Content}) {
+				return
+			}
+			if choice.FinishReason != nil {
+				finished = true
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	err = scanner.Err()
+	if err == nil && finished {
+		// Some servers end the body without [DONE]
+		send(final)
+		return
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	send(backend.StreamChunk{Error: ParseError(err)})
+}
@@ -0,0 +1,254 @@
+package llamacpp
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// ChatTemplate renders a conversation in the prompt layout a model family
+// was trained on. Tool calls and results use the same <tool_call> and
+// <tool_response> markup in every layout, since parseToolCalls reads it
+// back out of plain text.
+//
+// None of the layouts start with a BOS token: llama-server adds it when it
+// tokenizes the prompt, and a second one degrades output.
+type ChatTemplate struct {
+	Name string
+
+	// Stop holds the end-of-turn markers sent to llama-server as stop words
+	Stop []string
+
+	// ServerSide templates are not rendered by scmd. Requests go to
+	// llama-server's /v1/chat/completions, which applies the template
+	// embedded in the GGUF file.
+	ServerSide bool
+
+	// turns maps each role to the text around its content. Formats
+	// without a system role have no entry for it; the system prompt is
+	// then folded into the first user turn.
+	turns map[backend.Role]turnFormat
+
+	// generation opens the assistant turn the model completes
+	generation string
+}
+
+// turnFormat is the text before and after a turn's content
+type turnFormat struct {
+	prefix, suffix string
+}
+
+// Built-in chat templates
+var (
+	ChatML = &ChatTemplate{
+		Name: "chatml",
+		Stop: []string{"<|im_end|>", "<|endoftext|>"},
+		turns: map[backend.Role]turnFormat{
+			backend.RoleSystem:    {"<|im_start|>system\n", "<|im_end|>\n"},
+			backend.RoleUser:      {"<|im_start|>user\n", "<|im_end|>\n"},
+			backend.RoleAssistant: {"<|im_start|>assistant\n", "<|im_end|>\n"},
+		},
+		generation: "<|im_start|>assistant\n",
+	}
+
+	Llama3 = &ChatTemplate{
+		Name: "llama3",
+		Stop: []string{"<|eot_id|>", "<|end_of_text|>"},
+		turns: map[backend.Role]turnFormat{
+			backend.RoleSystem:    {"<|start_header_id|>system<|end_header_id|>\n\n", "<|eot_id|>"},
+			backend.RoleUser:      {"<|start_header_id|>user<|end_header_id|>\n\n", "<|eot_id|>"},
+			backend.RoleAssistant: {"<|start_header_id|>assistant<|end_header_id|>\n\n", "<|eot_id|>"},
+		},
+		generation: "<|start_header_id|>assistant<|end_header_id|>\n\n",
+	}
+
+	Mistral = &ChatTemplate{
+		Name: "mistral",
+		Stop: []string{"</s>", "[INST]"},
+		turns: map[backend.Role]turnFormat{
+			backend.RoleUser:      {"[INST] ", " [/INST]"},
+			backend.RoleAssistant: {" ", "</s>"},
+		},
+	}
+
+	Gemma = &ChatTemplate{
+		Name: "gemma",
+		Stop: []string{"<end_of_turn>", "<eos>"},
+		turns: map[backend.Role]turnFormat{
+			backend.RoleUser:      {"<start_of_turn>user\n", "<end_of_turn>\n"},
+			backend.RoleAssistant: {"<start_of_turn>model\n", "<end_of_turn>\n"},
+		},
+		generation: "<start_of_turn>model\n",
+	}
+
+	Phi3 = &ChatTemplate{
+		Name: "phi3",
+		Stop: []string{"<|end|>", "<|endoftext|>"},
+		turns: map[backend.Role]turnFormat{
+			backend.RoleSystem:    {"<|system|>\n", "<|end|>\n"},
+			backend.RoleUser:      {"<|user|>\n", "<|end|>\n"},
+			backend.RoleAssistant: {"<|assistant|>\n", "<|end|>\n"},
+		},
+		generation: "<|assistant|>\n",
+	}
+
+	// ServerTemplate hands rendering to llama-server
+	ServerTemplate = &ChatTemplate{
+		Name:       "server",
+		ServerSide: true,
+		turns: map[backend.Role]turnFormat{
+			backend.RoleSystem:    {},
+			backend.RoleUser:      {},
+			backend.RoleAssistant: {},
+		},
+	}
+)
+
+var chatTemplates = map[string]*ChatTemplate{
+	ChatML.Name:         ChatML,
+	Llama3.Name:         Llama3,
+	Mistral.Name:        Mistral,
+	Gemma.Name:          Gemma,
+	Phi3.Name:           Phi3,
+	ServerTemplate.Name: ServerTemplate,
+
+	// Common aliases
+	"qwen":    ChatML,
+	"llama-3": Llama3,
+	"phi":     Phi3,
+	"phi-3":   Phi3,
+}
+
+// ChatTemplateByName returns a built-in template by name or alias
+func ChatTemplateByName(name string) (*ChatTemplate, bool) {
+	t, ok := chatTemplates[strings.ToLower(name)]
+	return t, ok
+}
+
+// ChatTemplateNames lists the names ChatTemplateByName accepts, without aliases
+func ChatTemplateNames() []string {
+	names := []string{ChatML.Name, Llama3.Name, Mistral.Name, Gemma.Name, Phi3.Name, ServerTemplate.Name}
+	sort.Strings(names)
+	return names
+}
+
+// templateMarkers identify built-in layouts in GGUF Jinja templates. Order
+// matters: Phi-3 templates also mention <|endoftext|>, for example.
+var templateMarkers = []struct {
+	marker   string
+	template *ChatTemplate
+}{
+	{"<|im_start|>", ChatML},
+	{"<|start_header_id|>", Llama3},
+	{"<start_of_turn>", Gemma},
+	{"[INST]", Mistral},
+	{"<|assistant|>", Phi3},
+}
+
+// DetectChatTemplate picks the built-in template matching a GGUF
+// tokenizer.chat_template, or nil if none does
+func DetectChatTemplate(jinja string) *ChatTemplate {
+	for _, m := range templateMarkers {
+		if strings.Contains(jinja, m.marker) {
+			return m.template
+		}
+	}
+	return nil
+}
+
+// Render returns the prompt for system and messages, ending with the
+// opening of the assistant turn
+func (t *ChatTemplate) Render(system string, messages []backend.Message) string {
+	var sb strings.Builder
+	for _, turn := range t.conversation(system, messages) {
+		f := t.turns[turn.Role]
+		sb.WriteString(f.prefix)
+		sb.WriteString(turn.Content)
+		sb.WriteString(f.suffix)
+	}
+	sb.WriteString(t.generation)
+	return sb.String()
+}
+
+// chatTurn is a conversation turn reduced to what a template can express
+type chatTurn struct {
+	Role    backend.Role `json:"role"`
+	Content string       `json:"content"`
+}
+
+// conversation reduces system and messages to system, user and assistant
+// turns. Tool results become user turns; assistant tool calls are written
+// out as <tool_call> markup unless the content already carries it, as
+// content from CompleteWithTools does.
+func (t *ChatTemplate) conversation(system string, messages []backend.Message) []chatTurn {
+	turns := make([]chatTurn, 0, len(messages)+1)
+	if system != "" {
+		turns = append(turns, chatTurn{Role: backend.RoleSystem, Content: system})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case backend.RoleTool:
+			turns = append(turns, chatTurn{
+				Role:    backend.RoleUser,
+				Content: "<tool_response>\n" + msg.Content + "\n</tool_response>",
+			})
+
+		case backend.RoleAssistant:
+			var sb strings.Builder
+			sb.WriteString(msg.Content)
+			if !strings.Contains(msg.Content, "<tool_call>") {
+				for _, call := range msg.ToolCalls {
+					callJSON, _ := json.Marshal(map[string]interface{}{
+						"name":       call.Name,
+						"parameters": call.Parameters,
+					})
+					sb.WriteString("\n<tool_call>")
+					sb.Write(callJSON)
+					sb.WriteString("</tool_call>")
+				}
+			}
+			turns = append(turns, chatTurn{Role: backend.RoleAssistant, Content: sb.String()})
+
+		default:
+			turns = append(turns, chatTurn{Role: msg.Role, Content: msg.Content})
+		}
+	}
+
+	if _, ok := t.turns[backend.RoleSystem]; ok {
+		return turns
+	}
+	return foldSystem(turns)
+}
+
+// foldSystem prepends system turns to the user turn that follows them,
+// for formats without a system role
+func foldSystem(turns []chatTurn) []chatTurn {
+	out := make([]chatTurn, 0, len(turns))
+	var pending []string
+	for _, turn := range turns {
+		switch {
+		case turn.Role == backend.RoleSystem:
+			pending = append(pending, turn.Content)
+		case turn.Role == backend.RoleUser && len(pending) > 0:
+			turn.Content = strings.Join(append(pending, turn.Content), "\n\n")
+			pending = nil
+			out = append(out, turn)
+		default:
+			out = append(out, turn)
+		}
+	}
+	if len(pending) > 0 {
+		out = append(out, chatTurn{Role: backend.RoleUser, Content: strings.Join(pending, "\n\n")})
+	}
+	return out
+}
+
+// withStop returns req with the template's stop words ahead of its own
+func (t *ChatTemplate) withStop(req *backend.CompletionRequest) *backend.CompletionRequest {
+	r := *req
+	r.StopSequences = append(append([]string{}, t.Stop...), req.StopSequences...)
+	return &r
+}
@@ -0,0 +1,222 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// goldenConversation covers every turn kind a template has to render
+func goldenConversation() (string, []backend.Message) {
+	return "You are a helpful assistant.", []backend.Message{
+		{Role: backend.RoleUser, Content: "What's in go.mod?"},
+		{Role: backend.RoleAssistant, Content: "Let me check.", ToolCalls: []backend.ToolCall{
+			{Name: "read_file", Parameters: map[string]interface{}{"path": "go.mod"}},
+		}},
+		{Role: backend.RoleTool, Content: "module example.com/x", ToolName: "read_file"},
+		{Role: backend.RoleAssistant, Content: "The module is example.com/x."},
+		{Role: backend.RoleUser, Content: "Thanks!"},
+	}
+}
+
+func TestChatTemplate_Golden(t *testing.T) {
+	system, messages := goldenConversation()
+
+	for _, tmpl := range []*ChatTemplate{ChatML, Llama3, Mistral, Gemma, Phi3} {
+		t.Run(tmpl.Name, func(t *testing.T) {
+			got := tmpl.Render(system, messages)
+
+			path := filepath.Join("testdata", "chat_templates", tmpl.Name+".golden")
+			if *update {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, os.WriteFile(path, []byte(got), 0644))
+			}
+
+			want, err := os.ReadFile(path)
+			require.NoError(t, err, "run go test -update to create golden files")
+			assert.Equal(t, string(want), got)
+		})
+	}
+}
+
+func TestChatTemplateByName(t *testing.T) {
+	for _, name := range ChatTemplateNames() {
+		tmpl, ok := ChatTemplateByName(name)
+		require.True(t, ok, name)
+		assert.Equal(t, name, tmpl.Name)
+	}
+
+	tmpl, ok := ChatTemplateByName("Llama-3")
+	require.True(t, ok)
+	assert.Same(t, Llama3, tmpl)
+
+	_, ok = ChatTemplateByName("vicuna")
+	assert.False(t, ok)
+}
+
+func TestDetectChatTemplate(t *testing.T) {
+	tests := map[string]*ChatTemplate{
+		"{{ '<|im_start|>' + message['role'] }}":                   ChatML,
+		"{{ '<|start_header_id|>' + message['role'] }}":            Llama3,
+		"{{ bos_token }}{{ '[INST] ' + message['content'] }}":      Mistral,
+		"{{ '<start_of_turn>' + role + '\n' }}":                    Gemma,
+		"{{ '<|user|>\n' + message['content'] + '<|end|>' }}":      nil,
+		"{{ '<|assistant|>\n' + message['content'] + '<|end|>' }}": Phi3,
+		"{{ '### Instruction:' + message['content'] }}":            nil,
+	}
+	for jinja, want := range tests {
+		assert.Same(t, want, DetectChatTemplate(jinja), jinja)
+	}
+}
+
+func TestFoldSystem(t *testing.T) {
+	turns := foldSystem([]chatTurn{
+		{Role: backend.RoleSystem, Content: "Be brief."},
+		{Role: backend.RoleUser, Content: "hi"},
+		{Role: backend.RoleAssistant, Content: "hello"},
+		{Role: backend.RoleSystem, Content: "Now be verbose."},
+	})
+
+	assert.Equal(t, []chatTurn{
+		{Role: backend.RoleUser, Content: "Be brief.\n\nhi"},
+		{Role: backend.RoleAssistant, Content: "hello"},
+		{Role: backend.RoleUser, Content: "Now be verbose."},
+	}, turns)
+}
+
+func TestChatTemplate_WithStop(t *testing.T) {
+	req := &backend.CompletionRequest{StopSequences: []string{"\n\n"}}
+
+	got := Llama3.withStop(req)
+	assert.Equal(t, []string{"<|eot_id|>", "<|end_of_text|>", "\n\n"}, got.StopSequences)
+	assert.Equal(t, []string{"\n\n"}, req.StopSequences, "request must not be modified")
+
+	assert.Empty(t, ServerTemplate.withStop(&backend.CompletionRequest{}).StopSequences)
+}
+
+func TestBackend_ChatTemplateSelection(t *testing.T) {
+	dir := t.TempDir()
+
+	// Detected from the GGUF header
+	llamaPath := writeTestGGUF(t, dir, "{{ '<|start_header_id|>' + message['role'] }}")
+	b := New(dir)
+	require.NoError(t, b.SetModel(llamaPath))
+	assert.Same(t, Llama3, b.chatTemplate())
+
+	// An embedded template scmd doesn't know is left to llama-server
+	otherDir := t.TempDir()
+	otherPath := writeTestGGUF(t, otherDir, "{{ '### Instruction:' }}")
+	require.NoError(t, b.SetModel(otherPath))
+	assert.Same(t, ServerTemplate, b.chatTemplate())
+
+	// The catalog overrides detection
+	require.NoError(t, AddToCatalog(dir, Model{Name: "pinned", Path: llamaPath, ChatTemplate: "gemma"}))
+	b = New(dir)
+	require.NoError(t, b.SetModel("pinned"))
+	assert.Same(t, Gemma, b.chatTemplate())
+
+	// No header to read
+	require.NoError(t, b.SetModel("/missing/model.gguf"))
+	assert.Same(t, ChatML, b.chatTemplate())
+}
+
+func TestBackend_RunChatInference(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+
+		var body struct {
+			Messages []chatTurn `json:"messages"`
+			Stream   bool       `json:"stream"`
+			Stop     []string   `json:"stop"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.False(t, body.Stream)
+		assert.Empty(t, body.Stop)
+		assert.Equal(t, []chatTurn{
+			{Role: backend.RoleSystem, Content: "Be brief."},
+			{Role: backend.RoleUser, Content: "hi"},
+		}, body.Messages)
+
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":" hello "},"finish_reason":"length"}],"usage":{"prompt_tokens":7,"completion_tokens":2}}`)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	req := &backend.CompletionRequest{Prompt: "hi", SystemPrompt: "Be brief."}
+	turns := ServerTemplate.conversation(req.SystemPrompt, req.ConversationMessages())
+	result, err := b.runChatInference(context.Background(), turns, ServerTemplate.withStop(req))
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", result.Content)
+	assert.Equal(t, backend.FinishLength, result.FinishReason)
+	assert.Equal(t, 7, result.PromptTokens)
+	assert.Equal(t, 2, result.CompletionTokens)
+}
+
+func TestBackend_RunChatStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, tok := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q},\"finish_reason\":null}]}\n\n", tok)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":4,\"completion_tokens\":2},\"timings\":{\"prompt_n\":4,\"prompt_ms\":8,\"predicted_n\":2,\"predicted_ms\":20,\"predicted_per_second\":100}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	ch := make(chan backend.StreamChunk, 10)
+	b.runChatStream(context.Background(), []chatTurn{{Role: backend.RoleUser, Content: "hi"}}, &backend.CompletionRequest{}, ch)
+	close(ch)
+
+	var chunks []backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		chunks = append(chunks, chunk)
+	}
+
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "Hel", chunks[0].Content)
+	assert.Equal(t, "lo", chunks[1].Content)
+	assert.True(t, chunks[2].Done)
+	require.NotNil(t, chunks[2].Usage)
+	assert.Equal(t, 4, chunks[2].Usage.PromptTokens)
+	require.NotNil(t, chunks[2].Timing)
+	assert.Equal(t, int64(20), chunks[2].Timing.CompletionMS)
+}
+
+func TestBackend_RunChatStream_Truncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"},\"finish_reason\":null}]}\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	ch := make(chan backend.StreamChunk, 10)
+	b.runChatStream(context.Background(), []chatTurn{{Role: backend.RoleUser, Content: "hi"}}, &backend.CompletionRequest{}, ch)
+	close(ch)
+
+	var last backend.StreamChunk
+	for chunk := range ch {
+		last = chunk
+	}
+	assert.Error(t, last.Error)
+}
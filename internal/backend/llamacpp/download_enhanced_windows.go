@@ -22,6 +22,8 @@ type DownloadConfig struct {
 	RetryDelay      time.Duration
 	BufferSize      int
 	ResumeSupported bool
+	Workers         int   // Parallel range requests for segmented downloads
+	MinSegmentSize  int64 // Smallest segment worth its own connection
 }
 
 // DefaultDownloadConfig returns the default download configuration
@@ -31,6 +33,8 @@ func DefaultDownloadConfig() DownloadConfig {
 		RetryDelay:      time.Second,
 		BufferSize:      128 * 1024, // 128KB buffer for optimal performance
 		ResumeSupported: true,
+		Workers:         4,
+		MinSegmentSize:  16 * 1024 * 1024,
 	}
 }
 
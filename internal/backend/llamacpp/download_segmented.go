@@ -0,0 +1,478 @@
+package llamacpp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentFlushInterval is how often progress is reported, the checksum
+// advanced and the resume state saved
+const segmentFlushInterval = 200 * time.Millisecond
+
+// downloadState is the resume state of a segmented download, kept in
+// <dest>.part.json next to the <dest>.part data file
+type downloadState struct {
+	Size     int64          `json:"size"` // -1 if the server did not say
+	Ranged   bool           `json:"ranged"`
+	Segments []segmentState `json:"segments"`
+
+	// The SHA256 of the first Hashed bytes, so a resumed download does not
+	// read them again
+	Hashed    int64  `json:"hashed"`
+	HashState []byte `json:"hash_state,omitempty"`
+}
+
+// segmentState is one byte range [Start, End) and how much of it is on disk
+type segmentState struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"` // -1 for an unknown size
+	Done  int64 `json:"done"`
+}
+
+func (s *segmentState) complete() bool {
+	return s.End >= 0 && s.Start+s.Done >= s.End
+}
+
+// mirrorSet hands out download URLs in order of preference, skipping
+// mirrors that keep failing
+type mirrorSet struct {
+	mu       sync.Mutex
+	urls     []string
+	failures map[string]int
+	limit    int
+}
+
+// pick returns the first mirror that has not failed limit times
+func (m *mirrorSet) pick() (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, url := range m.urls {
+		if m.failures[url] < m.limit {
+			return url, true
+		}
+	}
+	return "", false
+}
+
+// fail records a failed request to url
+func (m *mirrorSet) fail(url string) {
+	m.mu.Lock()
+	m.failures[url]++
+	m.mu.Unlock()
+}
+
+// DownloadFromMirrors downloads a file from the first working URL in urls,
+// splitting it into segments fetched in parallel with HTTP Range requests.
+// A mirror that keeps failing is dropped in favour of the next. Segment
+// progress survives interruptions, and the SHA256 is computed while the
+// file arrives; expectedSHA256 may be empty to skip the check. Servers
+// without range support are downloaded over a single connection.
+func (d *EnhancedDownloader) DownloadFromMirrors(ctx context.Context, urls []string, destPath string, expectedSize int64, expectedSHA256 string, onProgress func(current, total int64)) error {
+	if len(urls) == 0 {
+		return fmt.Errorf("no download URL")
+	}
+	if err := d.CheckDiskSpace(destPath, expectedSize); err != nil {
+		return err
+	}
+
+	limit := d.config.MaxRetries
+	if limit < 1 {
+		limit = 1
+	}
+	mirrors := &mirrorSet{urls: urls, failures: make(map[string]int), limit: limit}
+
+	size, ranged, err := d.probe(ctx, mirrors)
+	if err != nil {
+		return &DownloadError{
+			Stage:   "download",
+			Err:     err,
+			Message: fmt.Sprintf("None of the %d download URL(s) answered.", len(urls)),
+			Help: []string{
+				"Check your internet connection",
+				"Add a mirror for the model in ~/.scmd/models.yaml",
+				"Download manually and place in: " + filepath.Dir(destPath),
+			},
+		}
+	}
+
+	partPath := destPath + ".part"
+	statePath := partPath + ".json"
+	state := d.loadState(statePath, size, ranged)
+	if state.done() > 0 {
+		fmt.Printf("\n⚡ Resuming download from %.1f MB\n", float64(state.done())/(1024*1024))
+	}
+
+	out, err := os.OpenFile(partPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer out.Close()
+	// Segments write at their offsets, so the file gets its final size up
+	// front; without a size nothing is resumed and it starts empty
+	allocate := size
+	if allocate < 0 {
+		allocate = 0
+	}
+	if err := out.Truncate(allocate); err != nil {
+		return fmt.Errorf("allocate file: %w", err)
+	}
+
+	hasher, err := restoreHash(state)
+	if err != nil {
+		// Start the checksum over rather than trust a bad state
+		hasher, state.Hashed = sha256.New(), 0
+	}
+
+	var mu sync.Mutex // guards state
+	flush := func() {
+		mu.Lock()
+		prefix, done := state.prefix(), state.done()
+		mu.Unlock()
+
+		if prefix > state.Hashed {
+			if _, err := io.Copy(hasher, io.NewSectionReader(out, state.Hashed, prefix-state.Hashed)); err == nil {
+				state.Hashed = prefix
+			}
+		}
+		mu.Lock()
+		if m, ok := hasher.(encoding.BinaryMarshaler); ok {
+			state.HashState, _ = m.MarshalBinary()
+		}
+		saveState(statePath, state)
+		mu.Unlock()
+
+		if onProgress != nil {
+			onProgress(done, size)
+		}
+	}
+
+	// Fetch the segments, reporting and saving progress as they go
+	errs := make(chan error, len(state.Segments))
+	var wg sync.WaitGroup
+	for i := range state.Segments {
+		seg := &state.Segments[i]
+		if seg.complete() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.fetchSegment(ctx, mirrors, out, seg, ranged, &mu); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	ticker := time.NewTicker(segmentFlushInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-finished:
+			running = false
+		case <-ticker.C:
+			flush()
+		}
+	}
+	flush()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return &DownloadError{
+			Stage:   "download",
+			Err:     err,
+			Message: "The download stopped; run the same command again to resume it.",
+			Help: []string{
+				"Check your internet connection",
+				"Try again later (network may be temporarily unavailable)",
+				"Download manually and place in: " + filepath.Dir(destPath),
+			},
+		}
+	}
+	out.Close()
+
+	if size > 0 && state.Hashed != size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d bytes", size, state.Hashed)
+	}
+	if expectedSHA256 != "" {
+		got := hex.EncodeToString(hasher.Sum(nil))
+		if !strings.EqualFold(got, expectedSHA256) {
+			os.Remove(partPath)
+			os.Remove(statePath)
+			return &DownloadError{
+				Stage:   "verification",
+				Err:     fmt.Errorf("checksum mismatch: expected %s, got %s", expectedSHA256, got),
+				Message: "Downloaded file checksum doesn't match expected value.",
+				Help: []string{
+					"The file may be corrupted during download",
+					"Try downloading again",
+					"Check your internet connection",
+				},
+			}
+		}
+	}
+
+	if err := os.Rename(partPath, destPath); err != nil {
+		return fmt.Errorf("move file: %w", err)
+	}
+	os.Remove(statePath)
+	return nil
+}
+
+// probe asks the mirrors for the file's size and range support with a
+// one-byte range request
+func (d *EnhancedDownloader) probe(ctx context.Context, mirrors *mirrorSet) (size int64, ranged bool, err error) {
+	for {
+		url, ok := mirrors.pick()
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("all mirrors failed")
+			}
+			return 0, false, err
+		}
+
+		size, ranged, err = d.probeURL(ctx, url)
+		if err == nil {
+			return size, ranged, nil
+		}
+		if ctx.Err() != nil {
+			return 0, false, ctx.Err()
+		}
+		// A mirror that cannot answer a probe is not retried
+		for i := 0; i < mirrors.limit; i++ {
+			mirrors.fail(url)
+		}
+	}
+}
+
+func (d *EnhancedDownloader) probeURL(ctx context.Context, url string) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "scmd/1.0 (https://github.com/scmd/scmd)")
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", url, err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if size, err := strconv.ParseInt(total, 10, 64); ok && err == nil && size > 0 {
+			return size, true, nil
+		}
+		return -1, false, nil
+	case http.StatusOK:
+		return resp.ContentLength, false, nil
+	default:
+		return 0, false, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+}
+
+// loadState resumes the state in statePath if it describes the same
+// download, or splits a new one into segments
+func (d *EnhancedDownloader) loadState(statePath string, size int64, ranged bool) *downloadState {
+	if data, err := os.ReadFile(statePath); err == nil {
+		var state downloadState
+		if json.Unmarshal(data, &state) == nil && state.Size == size && state.Ranged == ranged &&
+			ranged && len(state.Segments) > 0 {
+			return &state
+		}
+	}
+
+	state := &downloadState{Size: size, Ranged: ranged}
+	if !ranged {
+		state.Segments = []segmentState{{Start: 0, End: size}}
+		return state
+	}
+
+	n := d.config.Workers
+	if n < 1 {
+		n = 1
+	}
+	if minSize := d.config.MinSegmentSize; minSize > 0 && size/minSize < int64(n) {
+		n = int(size / minSize)
+		if n < 1 {
+			n = 1
+		}
+	}
+	segSize := size / int64(n)
+	for i := 0; i < n; i++ {
+		start := int64(i) * segSize
+		end := start + segSize
+		if i == n-1 {
+			end = size
+		}
+		state.Segments = append(state.Segments, segmentState{Start: start, End: end})
+	}
+	return state
+}
+
+// saveState writes state atomically
+func saveState(statePath string, state *downloadState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	tmp := statePath + ".tmp"
+	if os.WriteFile(tmp, data, 0644) == nil {
+		os.Rename(tmp, statePath)
+	}
+}
+
+// restoreHash rebuilds the checksum of the bytes already hashed
+func restoreHash(state *downloadState) (hash.Hash, error) {
+	h := sha256.New()
+	if state.Hashed == 0 || len(state.HashState) == 0 {
+		state.Hashed = 0
+		return h, nil
+	}
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.HashState); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// prefix is how many bytes from the start of the file are on disk
+func (s *downloadState) prefix() int64 {
+	for _, seg := range s.Segments {
+		if !seg.complete() {
+			return seg.Start + seg.Done
+		}
+	}
+	return s.Segments[len(s.Segments)-1].End
+}
+
+// done is how many bytes are on disk
+func (s *downloadState) done() int64 {
+	var n int64
+	for _, seg := range s.Segments {
+		n += seg.Done
+	}
+	return n
+}
+
+// fetchSegment downloads the rest of seg into out, moving to the next
+// mirror after failures
+func (d *EnhancedDownloader) fetchSegment(ctx context.Context, mirrors *mirrorSet, out *os.File, seg *segmentState, ranged bool, mu *sync.Mutex) error {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		url, ok := mirrors.pick()
+		if !ok {
+			return lastErr
+		}
+		if attempt > 0 {
+			select {
+			case <-time.After(d.config.RetryDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		err := d.fetchRange(ctx, url, out, seg, ranged, mu)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		mirrors.fail(url)
+		lastErr = err
+	}
+}
+
+// fetchRange makes one request for the rest of seg
+func (d *EnhancedDownloader) fetchRange(ctx context.Context, url string, out *os.File, seg *segmentState, ranged bool, mu *sync.Mutex) error {
+	mu.Lock()
+	if !ranged {
+		// Without ranges every attempt starts over
+		seg.Done = 0
+	}
+	offset := seg.Start + seg.Done
+	mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "scmd/1.0 (https://github.com/scmd/scmd)")
+	if ranged {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, seg.End-1))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	want := http.StatusOK
+	if ranged {
+		want = http.StatusPartialContent
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+
+	bufSize := d.config.BufferSize
+	if bufSize <= 0 {
+		bufSize = 128 * 1024
+	}
+	buf := make([]byte, bufSize)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := out.WriteAt(buf[:n], offset); werr != nil {
+				return fmt.Errorf("write to file: %w", werr)
+			}
+			offset += int64(n)
+			mu.Lock()
+			seg.Done += int64(n)
+			mu.Unlock()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read response: %w", err)
+		}
+	}
+
+	if seg.End >= 0 && offset < seg.End {
+		return fmt.Errorf("read response: %w", io.ErrUnexpectedEOF)
+	}
+	if seg.End < 0 {
+		// Unknown size: the segment ends where the body did
+		mu.Lock()
+		seg.End = offset
+		mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package llamacpp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSegmentConfig splits small test files into several segments
+func testSegmentConfig() DownloadConfig {
+	config := DefaultDownloadConfig()
+	config.RetryDelay = time.Millisecond
+	config.MinSegmentSize = 1024
+	config.BufferSize = 512
+	return config
+}
+
+func testPayload(size int) ([]byte, string) {
+	data := bytes.Repeat([]byte("scmd-segment-"), size/13+1)[:size]
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:])
+}
+
+// rangeServer serves data with Range support and counts range requests
+func rangeServer(t *testing.T, data []byte, ranges *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" && r.Header.Get("Range") != "bytes=0-0" {
+			atomic.AddInt32(ranges, 1)
+		}
+		http.ServeContent(w, r, "model.gguf", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadFromMirrors_Segmented(t *testing.T) {
+	data, sum := testPayload(10 * 1024)
+	var ranges int32
+	srv := rangeServer(t, data, &ranges)
+
+	dest := filepath.Join(t.TempDir(), "model.gguf")
+	d := NewEnhancedDownloader(testSegmentConfig())
+	var last int64
+	err := d.DownloadFromMirrors(context.Background(), []string{srv.URL}, dest, int64(len(data)), sum, func(current, total int64) {
+		last = current
+	})
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int64(len(data)), last)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&ranges), "one request per segment")
+	assert.NoFileExists(t, dest+".part")
+	assert.NoFileExists(t, dest+".part.json")
+}
+
+func TestDownloadFromMirrors_Resume(t *testing.T) {
+	data, sum := testPayload(8 * 1024)
+	var ranges int32
+	srv := rangeServer(t, data, &ranges)
+
+	dest := filepath.Join(t.TempDir(), "model.gguf")
+
+	// The first segment is on disk, the second lacks its last 1KB
+	state := &downloadState{
+		Size:   int64(len(data)),
+		Ranged: true,
+		Segments: []segmentState{
+			{Start: 0, End: 4096, Done: 4096},
+			{Start: 4096, End: 8192, Done: 3072},
+		},
+	}
+	part := make([]byte, len(data))
+	copy(part[:7168], data[:7168])
+	require.NoError(t, os.WriteFile(dest+".part", part, 0644))
+	saveState(dest+".part.json", state)
+
+	d := NewEnhancedDownloader(testSegmentConfig())
+	require.NoError(t, d.DownloadFromMirrors(context.Background(), []string{srv.URL}, dest, int64(len(data)), sum, nil))
+
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&ranges), "only the missing range is fetched")
+}
+
+func TestDownloadFromMirrors_Failover(t *testing.T) {
+	data, sum := testPayload(4 * 1024)
+	var ranges int32
+	good := rangeServer(t, data, &ranges)
+
+	var badHits int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badHits, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	dest := filepath.Join(t.TempDir(), "model.gguf")
+	d := NewEnhancedDownloader(testSegmentConfig())
+	require.NoError(t, d.DownloadFromMirrors(context.Background(), []string{bad.URL, good.URL}, dest, int64(len(data)), sum, nil))
+
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&badHits), "a mirror that fails the probe is skipped")
+}
+
+func TestDownloadFromMirrors_FailoverMidDownload(t *testing.T) {
+	data, sum := testPayload(4 * 1024)
+	var ranges int32
+	good := rangeServer(t, data, &ranges)
+
+	// Answers the probe but fails every range request
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=0-0" {
+			http.ServeContent(w, r, "model.gguf", time.Time{}, bytes.NewReader(data))
+			return
+		}
+		http.Error(w, "reset", http.StatusBadGateway)
+	}))
+	defer flaky.Close()
+
+	dest := filepath.Join(t.TempDir(), "model.gguf")
+	d := NewEnhancedDownloader(testSegmentConfig())
+	require.NoError(t, d.DownloadFromMirrors(context.Background(), []string{flaky.URL, good.URL}, dest, int64(len(data)), sum, nil))
+
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestDownloadFromMirrors_NoRangeSupport(t *testing.T) {
+	data, sum := testPayload(6 * 1024)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "model.gguf")
+	d := NewEnhancedDownloader(testSegmentConfig())
+	require.NoError(t, d.DownloadFromMirrors(context.Background(), []string{srv.URL}, dest, 0, sum, nil))
+
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests), "probe plus a single download")
+}
+
+func TestDownloadFromMirrors_ChecksumMismatch(t *testing.T) {
+	data, _ := testPayload(4 * 1024)
+	var ranges int32
+	srv := rangeServer(t, data, &ranges)
+
+	dest := filepath.Join(t.TempDir(), "model.gguf")
+	d := NewEnhancedDownloader(testSegmentConfig())
+	err := d.DownloadFromMirrors(context.Background(), []string{srv.URL}, dest, int64(len(data)), strings.Repeat("0", 64), nil)
+
+	var dlErr *DownloadError
+	require.ErrorAs(t, err, &dlErr)
+	assert.Equal(t, "verification", dlErr.Stage)
+	assert.NoFileExists(t, dest)
+	assert.NoFileExists(t, dest+".part")
+	assert.NoFileExists(t, dest+".part.json")
+}
+
+func TestLoadState_Segments(t *testing.T) {
+	d := NewEnhancedDownloader(testSegmentConfig())
+	statePath := filepath.Join(t.TempDir(), "model.gguf.part.json")
+
+	// Small files get fewer segments than workers
+	state := d.loadState(statePath, 2500, true)
+	require.Len(t, state.Segments, 2)
+	assert.Equal(t, segmentState{Start: 0, End: 1250}, state.Segments[0])
+	assert.Equal(t, segmentState{Start: 1250, End: 2500}, state.Segments[1])
+
+	// A saved state for another size is not resumed
+	saveState(statePath, &downloadState{Size: 999, Ranged: true, Segments: []segmentState{{End: 999, Done: 999}}})
+	state = d.loadState(statePath, 2500, true)
+	assert.Zero(t, state.done())
+}
@@ -1,6 +1,8 @@
 package llamacpp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -201,6 +203,11 @@ func ParseError(err error) error {
 		return nil
 	}
 
+	// A cancelled request is not a server problem
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
 	errStr := strings.ToLower(err.Error())
 
 	// Check for context size exceeded error first (most specific)
@@ -0,0 +1,394 @@
+package llamacpp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// GGUFMetadata is what scmd reads from a GGUF file header
+type GGUFMetadata struct {
+	Version         int
+	Architecture    string // e.g. "llama", "qwen2"
+	Name            string // general.name, if set
+	ParameterCount  int64  // Sum of all tensor sizes
+	FileType        int    // general.file_type; -1 when missing
+	ContextLength   int    // Native context length
+	LayerCount      int    // Transformer blocks
+	EmbeddingLength int
+	ChatTemplate    string // tokenizer.chat_template (Jinja), if embedded
+}
+
+// Quantization returns the name of the file type, e.g. "Q4_K_M"
+func (m *GGUFMetadata) Quantization() string {
+	if name, ok := ggufFileTypes[m.FileType]; ok {
+		return name
+	}
+	if m.FileType < 0 {
+		return ""
+	}
+	return fmt.Sprintf("type %d", m.FileType)
+}
+
+// Parameters returns the parameter count in short form, e.g. "1.5B"
+func (m *GGUFMetadata) Parameters() string {
+	n := float64(m.ParameterCount)
+	switch {
+	case n >= 1e9:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", n/1e9), ".0") + "B"
+	case n >= 1e6:
+		return fmt.Sprintf("%.0fM", n/1e6)
+	default:
+		return fmt.Sprintf("%d", m.ParameterCount)
+	}
+}
+
+// SupportsTools guesses tool calling support from the chat template,
+// which has to render a tool list for the model to use one
+func (m *GGUFMetadata) SupportsTools() bool {
+	return strings.Contains(m.ChatTemplate, "tools")
+}
+
+// ggufFileTypes names general.file_type values (llama_ftype in llama.cpp)
+var ggufFileTypes = map[int]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16", 36: "TQ1_0", 37: "TQ2_0",
+}
+
+// GGUF value types
+const (
+	ggufUint8 uint32 = iota
+	ggufInt8
+	ggufUint16
+	ggufInt16
+	ggufUint32
+	ggufInt32
+	ggufFloat32
+	ggufBool
+	ggufString
+	ggufArray
+	ggufUint64
+	ggufInt64
+	ggufFloat64
+)
+
+const ggufMagic = 0x46554747 // "GGUF" little-endian
+
+// Limits that stop a corrupt header from allocating gigabytes
+const (
+	maxGGUFString  = 16 << 20
+	maxGGUFEntries = 1 << 24
+	maxGGUFDims    = 8
+)
+
+// ErrNotGGUF is returned for files without the GGUF magic
+var ErrNotGGUF = errors.New("not a GGUF file")
+
+// ReadGGUFMetadata reads the metadata and tensor index of a GGUF file.
+// Tensor data is not read, so this is fast even for large models.
+func ReadGGUFMetadata(path string) (*GGUFMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	meta, err := parseGGUF(bufio.NewReaderSize(f, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("read GGUF %s: %w", path, err)
+	}
+	return meta, nil
+}
+
+// ggufReader decodes little-endian GGUF values
+type ggufReader struct {
+	r   *bufio.Reader
+	buf [8]byte
+}
+
+func parseGGUF(r *bufio.Reader) (*GGUFMetadata, error) {
+	g := &ggufReader{r: r}
+
+	magic, err := g.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if magic != ggufMagic {
+		return nil, ErrNotGGUF
+	}
+	version, err := g.uint32()
+	if err != nil {
+		return nil, err
+	}
+	// Version 1 used 32-bit lengths and predates every model scmd supports
+	if version < 2 || version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+
+	tensorCount, err := g.uint64()
+	if err != nil {
+		return nil, err
+	}
+	kvCount, err := g.uint64()
+	if err != nil {
+		return nil, err
+	}
+	if tensorCount > maxGGUFEntries || kvCount > maxGGUFEntries {
+		return nil, fmt.Errorf("implausible header: %d tensors, %d keys", tensorCount, kvCount)
+	}
+
+	meta := &GGUFMetadata{Version: int(version), FileType: -1}
+	values := make(map[string]interface{})
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := g.string()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		typ, err := g.uint32()
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		value, err := g.value(typ)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		if value != nil {
+			values[key] = value
+		}
+	}
+
+	meta.Architecture, _ = values["general.architecture"].(string)
+	meta.Name, _ = values["general.name"].(string)
+	meta.ChatTemplate, _ = values["tokenizer.chat_template"].(string)
+	if v, ok := toInt(values["general.file_type"]); ok {
+		meta.FileType = v
+	}
+	if arch := meta.Architecture; arch != "" {
+		meta.ContextLength, _ = toInt(values[arch+".context_length"])
+		meta.LayerCount, _ = toInt(values[arch+".block_count"])
+		meta.EmbeddingLength, _ = toInt(values[arch+".embedding_length"])
+	}
+
+	for i := uint64(0); i < tensorCount; i++ {
+		n, err := g.tensorInfo()
+		if err != nil {
+			return nil, fmt.Errorf("tensor %d: %w", i, err)
+		}
+		meta.ParameterCount += n
+	}
+
+	return meta, nil
+}
+
+// tensorInfo reads one tensor index entry and returns its element count
+func (g *ggufReader) tensorInfo() (int64, error) {
+	if err := g.skipString(); err != nil {
+		return 0, err
+	}
+	dims, err := g.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if dims > maxGGUFDims {
+		return 0, fmt.Errorf("implausible tensor with %d dimensions", dims)
+	}
+
+	elements := int64(1)
+	for d := uint32(0); d < dims; d++ {
+		n, err := g.uint64()
+		if err != nil {
+			return 0, err
+		}
+		if elements > 0 && n > uint64(math.MaxInt64/elements) {
+			return 0, fmt.Errorf("implausible tensor size")
+		}
+		elements *= int64(n)
+	}
+
+	// Tensor type and data offset
+	if _, err := g.r.Discard(4 + 8); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return elements, nil
+}
+
+// value reads a value of type typ. Arrays are skipped and return nil,
+// since none of the keys scmd reads is an array.
+func (g *ggufReader) value(typ uint32) (interface{}, error) {
+	switch typ {
+	case ggufUint8, ggufInt8, ggufBool:
+		b, err := g.r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if typ == ggufInt8 {
+			return int64(int8(b)), nil
+		}
+		return uint64(b), nil
+	case ggufUint16, ggufInt16:
+		b, err := g.read(2)
+		if err != nil {
+			return nil, err
+		}
+		v := binary.LittleEndian.Uint16(b)
+		if typ == ggufInt16 {
+			return int64(int16(v)), nil
+		}
+		return uint64(v), nil
+	case ggufUint32:
+		v, err := g.uint32()
+		return uint64(v), err
+	case ggufInt32:
+		v, err := g.uint32()
+		return int64(int32(v)), err
+	case ggufFloat32:
+		v, err := g.uint32()
+		return float64(math.Float32frombits(v)), err
+	case ggufUint64:
+		return g.uint64()
+	case ggufInt64:
+		v, err := g.uint64()
+		return int64(v), err
+	case ggufFloat64:
+		v, err := g.uint64()
+		return math.Float64frombits(v), err
+	case ggufString:
+		return g.string()
+	case ggufArray:
+		return nil, g.skipArray()
+	default:
+		return nil, fmt.Errorf("unknown value type %d", typ)
+	}
+}
+
+// skipArray skips an array value without decoding its elements
+func (g *ggufReader) skipArray() error {
+	typ, err := g.uint32()
+	if err != nil {
+		return err
+	}
+	count, err := g.uint64()
+	if err != nil {
+		return err
+	}
+	if count > maxGGUFEntries {
+		return fmt.Errorf("implausible array of %d elements", count)
+	}
+
+	var size int
+	switch typ {
+	case ggufUint8, ggufInt8, ggufBool:
+		size = 1
+	case ggufUint16, ggufInt16:
+		size = 2
+	case ggufUint32, ggufInt32, ggufFloat32:
+		size = 4
+	case ggufUint64, ggufInt64, ggufFloat64:
+		size = 8
+	case ggufString:
+		for i := uint64(0); i < count; i++ {
+			if err := g.skipString(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ggufArray:
+		for i := uint64(0); i < count; i++ {
+			if err := g.skipArray(); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown array element type %d", typ)
+	}
+
+	if _, err := g.r.Discard(int(count) * size); err != nil {
+		return unexpectedEOF(err)
+	}
+	return nil
+}
+
+func (g *ggufReader) read(n int) ([]byte, error) {
+	if _, err := io.ReadFull(g.r, g.buf[:n]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return g.buf[:n], nil
+}
+
+func (g *ggufReader) uint32() (uint32, error) {
+	b, err := g.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (g *ggufReader) uint64() (uint64, error) {
+	b, err := g.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (g *ggufReader) stringLen() (int, error) {
+	n, err := g.uint64()
+	if err != nil {
+		return 0, err
+	}
+	if n > maxGGUFString {
+		return 0, fmt.Errorf("implausible string of %d bytes", n)
+	}
+	return int(n), nil
+}
+
+func (g *ggufReader) string() (string, error) {
+	n, err := g.stringLen()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(g.r, b); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return string(b), nil
+}
+
+func (g *ggufReader) skipString() error {
+	n, err := g.stringLen()
+	if err != nil {
+		return err
+	}
+	if _, err := g.r.Discard(n); err != nil {
+		return unexpectedEOF(err)
+	}
+	return nil
+}
+
+// unexpectedEOF reports a header cut short as a truncated file
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// toInt converts a decoded integer value
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case uint64:
+		return int(n), true
+	case int64:
+		return int(n), true
+	}
+	return 0, false
+}
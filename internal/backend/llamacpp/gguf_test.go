@@ -0,0 +1,167 @@
+package llamacpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// ggufWriter builds GGUF headers for tests
+type ggufWriter struct {
+	bytes.Buffer
+}
+
+func (w *ggufWriter) u32(v uint32) { binary.Write(w, binary.LittleEndian, v) }
+func (w *ggufWriter) u64(v uint64) { binary.Write(w, binary.LittleEndian, v) }
+
+func (w *ggufWriter) str(s string) {
+	w.u64(uint64(len(s)))
+	w.WriteString(s)
+}
+
+func (w *ggufWriter) kvString(key, value string) {
+	w.str(key)
+	w.u32(ggufString)
+	w.str(value)
+}
+
+func (w *ggufWriter) kvUint32(key string, value uint32) {
+	w.str(key)
+	w.u32(ggufUint32)
+	w.u32(value)
+}
+
+func (w *ggufWriter) tensor(name string, dims ...uint64) {
+	w.str(name)
+	w.u32(uint32(len(dims)))
+	for _, d := range dims {
+		w.u64(d)
+	}
+	w.u32(0) // type
+	w.u64(0) // offset
+}
+
+// writeTestGGUF writes a small qwen2-like header to dir
+func writeTestGGUF(t *testing.T, dir string, template string) string {
+	t.Helper()
+
+	var w ggufWriter
+	w.u32(ggufMagic)
+	w.u32(3)
+	w.u64(2) // tensors
+	w.u64(8) // keys
+
+	w.kvString("general.architecture", "qwen2")
+	w.kvString("general.name", "Qwen2.5 1.5B Instruct")
+	w.kvUint32("general.file_type", 15)
+	w.kvUint32("qwen2.context_length", 32768)
+	w.kvUint32("qwen2.block_count", 28)
+	w.kvUint32("qwen2.embedding_length", 1536)
+
+	// A token list, which the reader has to skip
+	w.str("tokenizer.ggml.tokens")
+	w.u32(ggufArray)
+	w.u32(ggufString)
+	w.u64(3)
+	w.str("a")
+	w.str("b")
+	w.str("<|im_end|>")
+
+	w.kvString("tokenizer.chat_template", template)
+
+	w.tensor("token_embd.weight", 1536, 151936)
+	w.tensor("blk.0.attn_q.weight", 1536, 1536)
+
+	path := filepath.Join(dir, "model.gguf")
+	require.NoError(t, os.WriteFile(path, w.Bytes(), 0644))
+	return path
+}
+
+func TestReadGGUFMetadata(t *testing.T) {
+	template := "{% for message in messages %}{% if tools %}...{% endif %}{% endfor %}"
+	path := writeTestGGUF(t, t.TempDir(), template)
+
+	meta, err := ReadGGUFMetadata(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, meta.Version)
+	assert.Equal(t, "qwen2", meta.Architecture)
+	assert.Equal(t, "Qwen2.5 1.5B Instruct", meta.Name)
+	assert.Equal(t, "Q4_K_M", meta.Quantization())
+	assert.Equal(t, 32768, meta.ContextLength)
+	assert.Equal(t, 28, meta.LayerCount)
+	assert.Equal(t, 1536, meta.EmbeddingLength)
+	assert.Equal(t, int64(1536*151936+1536*1536), meta.ParameterCount)
+	assert.Equal(t, "236M", meta.Parameters())
+	assert.Equal(t, template, meta.ChatTemplate)
+	assert.True(t, meta.SupportsTools())
+}
+
+func TestReadGGUFMetadata_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	notGGUF := filepath.Join(dir, "not.gguf")
+	require.NoError(t, os.WriteFile(notGGUF, []byte("PK\x03\x04 not a model"), 0644))
+	_, err := ReadGGUFMetadata(notGGUF)
+	assert.ErrorIs(t, err, ErrNotGGUF)
+
+	// Cut a valid header short
+	full, err := os.ReadFile(writeTestGGUF(t, dir, "x"))
+	require.NoError(t, err)
+	truncated := filepath.Join(dir, "truncated.gguf")
+	require.NoError(t, os.WriteFile(truncated, full[:len(full)-10], 0644))
+	_, err = ReadGGUFMetadata(truncated)
+	assert.Error(t, err)
+
+	var w ggufWriter
+	w.u32(ggufMagic)
+	w.u32(1)
+	v1 := filepath.Join(dir, "v1.gguf")
+	require.NoError(t, os.WriteFile(v1, w.Bytes(), 0644))
+	_, err = ReadGGUFMetadata(v1)
+	assert.ErrorContains(t, err, "version 1")
+}
+
+func TestGGUFMetadata_Parameters(t *testing.T) {
+	assert.Equal(t, "1.5B", (&GGUFMetadata{ParameterCount: 1_543_714_304}).Parameters())
+	assert.Equal(t, "7B", (&GGUFMetadata{ParameterCount: 7_000_000_000}).Parameters())
+	assert.Equal(t, "494M", (&GGUFMetadata{ParameterCount: 494_032_768}).Parameters())
+	assert.Equal(t, "", (&GGUFMetadata{FileType: -1}).Quantization())
+}
+
+func TestServerConfig_TuneForModel(t *testing.T) {
+	meta := &GGUFMetadata{ContextLength: 131072, LayerCount: 28}
+
+	config := &ServerConfig{GPULayers: 99}
+	config.TuneForModel(meta)
+	assert.Equal(t, 131072, config.ContextSize)
+	assert.Equal(t, min(offloadedThreads, runtime.NumCPU()), config.Threads)
+
+	// Partial offload and explicit context are left alone
+	config = &ServerConfig{GPULayers: 10, ContextSize: 4096}
+	config.TuneForModel(meta)
+	assert.Equal(t, 4096, config.ContextSize)
+	assert.Zero(t, config.Threads)
+}
+
+func TestBackend_ModelInfoFromGGUF(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestGGUF(t, dir, "{{ tools }}")
+
+	b := New(dir)
+	require.NoError(t, b.SetModel(path))
+	assert.Equal(t, 32768, b.GetContextSize())
+
+	info := b.ModelInfo()
+	assert.Equal(t, "Q4_K_M", info.Quantization)
+	assert.Equal(t, 32768, info.ContextLength)
+	assert.True(t, info.HasCapability(backend.CapabilityToolCalling))
+}
@@ -0,0 +1,269 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultHuggingFaceURL is the Hugging Face Hub
+const DefaultHuggingFaceURL = "https://huggingface.co"
+
+// HFPrefix marks a Hugging Face repository in place of a model name,
+// as in hf:Qwen/Qwen2.5-3B-Instruct-GGUF
+const HFPrefix = "hf:"
+
+// HFClient browses GGUF repositories on the Hugging Face Hub, or on any
+// server that offers the same API at BaseURL
+type HFClient struct {
+	BaseURL    string
+	httpClient *http.Client
+}
+
+// NewHFClient creates a client for the hub at baseURL; empty means
+// DefaultHuggingFaceURL
+func NewHFClient(baseURL string) *HFClient {
+	if baseURL == "" {
+		baseURL = DefaultHuggingFaceURL
+	}
+	return &HFClient{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// HFRepo is a model repository found by Search
+type HFRepo struct {
+	ID        string `json:"id"` // org/name
+	Downloads int    `json:"downloads"`
+	Likes     int    `json:"likes"`
+}
+
+// HFFile is a GGUF file in a repository
+type HFFile struct {
+	Repo   string
+	Path   string
+	Size   int64
+	SHA256 string // from the LFS pointer; empty if the hub did not say
+	Quant  string // e.g. "Q4_K_M"; empty if the name does not say
+	URL    string
+}
+
+// Search returns GGUF repositories matching query, most downloaded first
+func (c *HFClient) Search(ctx context.Context, query string, limit int) ([]HFRepo, error) {
+	params := url.Values{
+		"search":    {query},
+		"filter":    {"gguf"},
+		"sort":      {"downloads"},
+		"direction": {"-1"},
+		"limit":     {fmt.Sprint(limit)},
+	}
+	var repos []HFRepo
+	if _, err := c.get(ctx, c.BaseURL+"/api/models?"+params.Encode(), &repos); err != nil {
+		return nil, fmt.Errorf("search models: %w", err)
+	}
+	return repos, nil
+}
+
+// hfTreeEntry is one entry of the repository tree API
+type hfTreeEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	LFS  *struct {
+		OID  string `json:"oid"` // SHA256 of the file
+		Size int64  `json:"size"`
+	} `json:"lfs"`
+}
+
+// shardPattern matches the parts of a model split across files, which a
+// single download cannot use
+var shardPattern = regexp.MustCompile(`(?i)-\d{5}-of-\d{5}\.gguf$`)
+
+// ListGGUF returns the GGUF model files in repo, smallest first. Split
+// models and multimodal projectors are left out.
+func (c *HFClient) ListGGUF(ctx context.Context, repo string) ([]HFFile, error) {
+	if strings.Count(repo, "/") != 1 || strings.HasPrefix(repo, "/") || strings.HasSuffix(repo, "/") {
+		return nil, fmt.Errorf("invalid repository %q, expected <org>/<name>", repo)
+	}
+
+	var files []HFFile
+	next := fmt.Sprintf("%s/api/models/%s/tree/main?recursive=true", c.BaseURL, escapePath(repo))
+	for next != "" {
+		var entries []hfTreeEntry
+		var err error
+		if next, err = c.get(ctx, next, &entries); err != nil {
+			return nil, fmt.Errorf("list %s: %w", repo, err)
+		}
+
+		for _, e := range entries {
+			lower := strings.ToLower(e.Path)
+			if e.Type != "file" || !strings.HasSuffix(lower, ".gguf") ||
+				shardPattern.MatchString(e.Path) || strings.Contains(lower, "mmproj") {
+				continue
+			}
+			f := HFFile{
+				Repo:  repo,
+				Path:  e.Path,
+				Size:  e.Size,
+				Quant: ParseQuant(e.Path),
+				URL:   fmt.Sprintf("%s/%s/resolve/main/%s", c.BaseURL, escapePath(repo), escapePath(e.Path)),
+			}
+			if e.LFS != nil {
+				f.Size = e.LFS.Size
+				f.SHA256 = e.LFS.OID
+			}
+			files = append(files, f)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size < files[j].Size })
+	return files, nil
+}
+
+// get fetches a JSON document into v and returns the URL of the next page,
+// if the response links one
+func (c *HFClient) get(ctx context.Context, endpoint string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "scmd/1.0 (https://github.com/scmd/scmd)")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("not found on %s", c.BaseURL)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("access denied (HTTP %d); the repository may be gated or private", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("parse response: %w", err)
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink returns the rel="next" URL of a Link header
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// escapePath escapes each segment of a slash-separated path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// quantPattern matches llama.cpp quantization names in file names, e.g.
+// Q4_K_M, IQ4_XS, Q8_0 or BF16
+var quantPattern = regexp.MustCompile(`(?i)(?:^|[-._])(I?Q\d(?:_[A-Z0-9]+)*|BF16|F16|F32)(?:[-._]|$)`)
+
+// ParseQuant returns the quantization named in a GGUF file name, upper
+// case, or "" if there is none
+func ParseQuant(filename string) string {
+	base := filename[strings.LastIndex(filename, "/")+1:]
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".gguf"), ".GGUF")
+	matches := quantPattern.FindAllStringSubmatch(base, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	// The quantization comes last, after names like "qwen2-7b"
+	return strings.ToUpper(matches[len(matches)-1][1])
+}
+
+// fullPrecision reports whether quant leaves the weights unquantized
+func fullPrecision(quant string) bool {
+	return quant == "F16" || quant == "BF16" || quant == "F32"
+}
+
+// RecommendFile picks the file to download on a machine with resources:
+// the largest quantized file that fits in memory with room for the
+// context, since larger quantizations lose less quality. Full precision
+// files are picked only when no quantized one fits. If nothing fits, the
+// smallest file is returned with fits false. files must not be empty.
+func RecommendFile(files []HFFile, resources *SystemResources) (best int, fits bool) {
+	budget := resources.AvailableRAMBytes - systemReserveBytes
+
+	best = -1
+	for _, quantized := range []bool{true, false} {
+		for i, f := range files {
+			if quantized && fullPrecision(f.Quant) {
+				continue
+			}
+			need := f.Size + int64(float64(f.Size)*memoryOverhead)
+			if need <= budget && (best < 0 || f.Size > files[best].Size) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return best, true
+		}
+	}
+
+	best = 0
+	for i, f := range files {
+		if f.Size < files[best].Size {
+			best = i
+		}
+	}
+	return best, false
+}
+
+// ParseHFRef splits "hf:<org>/<repo>[:<quant>]" into the repository and
+// the optional quantization. ok is false without the hf: prefix.
+func ParseHFRef(ref string) (repo, quant string, ok bool) {
+	rest, ok := strings.CutPrefix(ref, HFPrefix)
+	if !ok {
+		return "", "", false
+	}
+	repo, quant, _ = strings.Cut(rest, ":")
+	return repo, strings.ToUpper(quant), true
+}
+
+// HFModelName derives a catalog name from a repository, e.g.
+// "Qwen/Qwen2.5-3B-Instruct-GGUF" becomes "qwen2.5-3b-instruct"
+func HFModelName(repo string) string {
+	name := strings.ToLower(repo[strings.LastIndex(repo, "/")+1:])
+	for _, suffix := range []string{"-gguf", "_gguf", ".gguf"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
+
+// Model returns a catalog entry that downloads f under name
+func (f *HFFile) Model(name string) Model {
+	return Model{
+		Name:        name,
+		Variant:     f.Quant,
+		URL:         f.URL,
+		Size:        f.Size,
+		SHA256:      f.SHA256,
+		Description: "From " + HFPrefix + f.Repo,
+	}
+}
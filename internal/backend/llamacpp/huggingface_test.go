@@ -0,0 +1,158 @@
+package llamacpp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gb = 1024 * 1024 * 1024
+
+// hubServer stands in for the Hugging Face API with one repository whose
+// tree listing comes in two pages
+func hubServer(t *testing.T) *httptest.Server {
+	sha := strings.Repeat("ab", 32)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/models":
+			assert.Equal(t, "qwen", r.URL.Query().Get("search"))
+			assert.Equal(t, "gguf", r.URL.Query().Get("filter"))
+			fmt.Fprint(w, `[{"id":"Qwen/Qwen2.5-3B-Instruct-GGUF","downloads":1200,"likes":80},{"id":"bartowski/Qwen2.5-7B-Instruct-GGUF","downloads":900,"likes":40}]`)
+		case r.URL.Path == "/api/models/Qwen/Qwen2.5-3B-Instruct-GGUF/tree/main" && r.URL.Query().Get("cursor") == "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/models/Qwen/Qwen2.5-3B-Instruct-GGUF/tree/main?recursive=true&cursor=2>; rel="next"`, srv.URL))
+			fmt.Fprintf(w, `[
+				{"type":"file","path":"README.md","size":4000},
+				{"type":"file","path":"qwen2.5-3b-instruct-q8_0.gguf","size":10,"lfs":{"oid":"%s","size":%d}},
+				{"type":"file","path":"qwen2.5-3b-instruct-q4_k_m.gguf","size":10,"lfs":{"oid":"%s","size":%d}}
+			]`, sha, 4*gb, sha, 2*gb)
+		case r.URL.Path == "/api/models/Qwen/Qwen2.5-3B-Instruct-GGUF/tree/main":
+			fmt.Fprintf(w, `[
+				{"type":"directory","path":"fp16"},
+				{"type":"file","path":"fp16/qwen2.5-3b-instruct-fp16-00001-of-00002.gguf","size":10,"lfs":{"oid":"%s","size":%d}},
+				{"type":"file","path":"mmproj-qwen2.5-f16.gguf","size":600},
+				{"type":"file","path":"qwen2.5-3b-instruct-f16.gguf","size":10,"lfs":{"oid":"%s","size":%d}}
+			]`, sha, 3*gb, sha, 7*gb)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHFClient_Search(t *testing.T) {
+	client := NewHFClient(hubServer(t).URL)
+
+	repos, err := client.Search(context.Background(), "qwen", 10)
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	assert.Equal(t, "Qwen/Qwen2.5-3B-Instruct-GGUF", repos[0].ID)
+	assert.Equal(t, 1200, repos[0].Downloads)
+}
+
+func TestHFClient_ListGGUF(t *testing.T) {
+	srv := hubServer(t)
+	client := NewHFClient(srv.URL + "/")
+
+	files, err := client.ListGGUF(context.Background(), "Qwen/Qwen2.5-3B-Instruct-GGUF")
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, "Q4_K_M", files[0].Quant)
+	assert.Equal(t, int64(2*gb), files[0].Size)
+	assert.Equal(t, strings.Repeat("ab", 32), files[0].SHA256)
+	assert.Equal(t, srv.URL+"/Qwen/Qwen2.5-3B-Instruct-GGUF/resolve/main/qwen2.5-3b-instruct-q4_k_m.gguf", files[0].URL)
+	assert.Equal(t, "Q8_0", files[1].Quant)
+	assert.Equal(t, "F16", files[2].Quant)
+}
+
+func TestHFClient_ListGGUF_Errors(t *testing.T) {
+	client := NewHFClient(hubServer(t).URL)
+
+	_, err := client.ListGGUF(context.Background(), "Qwen/missing")
+	assert.ErrorContains(t, err, "not found")
+
+	_, err = client.ListGGUF(context.Background(), "no-org")
+	assert.ErrorContains(t, err, "expected <org>/<name>")
+}
+
+func TestParseQuant(t *testing.T) {
+	tests := map[string]string{
+		"qwen2.5-3b-instruct-q4_k_m.gguf":       "Q4_K_M",
+		"Llama-3.2-3B-Instruct-IQ4_XS.gguf":     "IQ4_XS",
+		"Meta-Llama-3-8B-Instruct.Q8_0.gguf":    "Q8_0",
+		"sub/dir/gemma-2-2b-it-BF16.gguf":       "BF16",
+		"phi-3-mini-4k-instruct-q4_0_4_4.gguf":  "Q4_0_4_4",
+		"qwen2-7b.gguf":                         "",
+		"tinyllama-1.1b-chat-v1.0.Q5_K_S.gguf":  "Q5_K_S",
+		"mistral-7b-instruct-v0.3.f16.gguf":     "F16",
+		"Qwen2.5-Coder-7B-Instruct-Q6_K_L.gguf": "Q6_K_L",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, ParseQuant(name), name)
+	}
+}
+
+func TestRecommendFile(t *testing.T) {
+	files := []HFFile{
+		{Quant: "Q4_K_M", Size: 2 * gb},
+		{Quant: "Q8_0", Size: 4 * gb},
+		{Quant: "F16", Size: 7 * gb},
+	}
+
+	// 16 GB: the largest quantized file, not full precision
+	best, fits := RecommendFile(files, &SystemResources{AvailableRAMBytes: 16 * gb})
+	assert.Equal(t, 1, best)
+	assert.True(t, fits)
+
+	// 6 GB leaves room for Q4_K_M only
+	best, fits = RecommendFile(files, &SystemResources{AvailableRAMBytes: 6 * gb})
+	assert.Equal(t, 0, best)
+	assert.True(t, fits)
+
+	// Nothing fits: the smallest, flagged
+	best, fits = RecommendFile(files, &SystemResources{AvailableRAMBytes: 3 * gb})
+	assert.Equal(t, 0, best)
+	assert.False(t, fits)
+
+	// Full precision when it is all there is
+	best, fits = RecommendFile(files[2:], &SystemResources{AvailableRAMBytes: 16 * gb})
+	assert.Equal(t, 0, best)
+	assert.True(t, fits)
+}
+
+func TestParseHFRef(t *testing.T) {
+	repo, quant, ok := ParseHFRef("hf:Qwen/Qwen2.5-3B-Instruct-GGUF:q4_k_m")
+	assert.True(t, ok)
+	assert.Equal(t, "Qwen/Qwen2.5-3B-Instruct-GGUF", repo)
+	assert.Equal(t, "Q4_K_M", quant)
+
+	repo, quant, ok = ParseHFRef("hf:Qwen/Qwen2.5-3B-Instruct-GGUF")
+	assert.True(t, ok)
+	assert.Equal(t, "Qwen/Qwen2.5-3B-Instruct-GGUF", repo)
+	assert.Empty(t, quant)
+
+	_, _, ok = ParseHFRef("qwen2.5-3b")
+	assert.False(t, ok)
+}
+
+func TestHFFile_Model(t *testing.T) {
+	f := HFFile{
+		Repo:   "Qwen/Qwen2.5-3B-Instruct-GGUF",
+		Quant:  "Q4_K_M",
+		Size:   2 * gb,
+		SHA256: strings.Repeat("ab", 32),
+		URL:    "https://huggingface.co/Qwen/Qwen2.5-3B-Instruct-GGUF/resolve/main/qwen2.5-3b-instruct-q4_k_m.gguf",
+	}
+	m := f.Model(HFModelName(f.Repo))
+	require.NoError(t, m.Validate())
+	assert.Equal(t, "qwen2.5-3b-instruct", m.Name)
+	assert.Equal(t, "qwen2.5-3b-instruct-Q4_K_M.gguf", m.Filename())
+}
@@ -1,6 +1,7 @@
 package llamacpp
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -11,6 +12,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,17 +33,13 @@ type Server struct {
 	logFile     *os.File
 }
 
-var (
-	globalServer *Server
-	serverMu     sync.Mutex
-)
-
 // ServerConfig holds server configuration
 type ServerConfig struct {
 	ModelPath   string
 	Port        int
 	ContextSize int
 	GPULayers   int
+	Threads     int // Generation threads; 0 = all CPUs
 }
 
 // DefaultServerConfig returns default configuration
@@ -135,45 +134,22 @@ func StartServer(modelPath string, port int) (*Server, error) {
 	})
 }
 
-// StartServerWithConfig starts llama-server with custom configuration
+// StartServerWithConfig returns a llama-server for config.ModelPath from
+// the server pool, starting one if none is running. The pool picks the
+// port; see Pool.
 func StartServerWithConfig(config *ServerConfig) (*Server, error) {
-	serverMu.Lock()
-	defer serverMu.Unlock()
-
-	debug := os.Getenv("SCMD_DEBUG") != ""
-
-	// Check if already running with same model
-	if globalServer != nil && globalServer.modelPath == config.ModelPath && globalServer.ready {
-		if debug {
-			fmt.Fprintf(os.Stderr, "[DEBUG] Using existing server with model %s\n", config.ModelPath)
-		}
-		return globalServer, nil
-	}
-
-	// If running with different model, restart
-	if globalServer != nil && globalServer.modelPath != config.ModelPath && globalServer.ready {
-		if debug {
-			fmt.Fprintf(os.Stderr, "[DEBUG] Model changed from %s to %s, restarting server\n",
-				globalServer.modelPath, config.ModelPath)
-		}
-		globalServer.Stop()
-		globalServer = nil
+	server, release, err := defaultPool.Acquire(config)
+	if err != nil {
+		return nil, err
 	}
+	release()
+	return server, nil
+}
 
-	// Check if a server is already running on this port (external instance)
-	if IsServerRunning(config.Port) {
-		// Use the existing external server
-		if debug {
-			fmt.Fprintf(os.Stderr, "[DEBUG] Using existing llama-server on port %d\n", config.Port)
-		}
-		server := &Server{
-			port:      config.Port,
-			modelPath: config.ModelPath,
-			ready:     true,
-		}
-		globalServer = server
-		return server, nil
-	}
+// launchServer starts llama-server on config.Port and waits until it is
+// ready
+func launchServer(config *ServerConfig) (*Server, error) {
+	debug := os.Getenv("SCMD_DEBUG") != ""
 
 	// Auto-tune configuration based on system resources if not explicitly set
 	if config.ContextSize == 0 || config.GPULayers == 0 {
@@ -209,6 +185,17 @@ func StartServerWithConfig(config *ServerConfig) (*Server, error) {
 		}
 	}
 
+	// Fill in the rest from the model's own header
+	if meta, err := ReadGGUFMetadata(config.ModelPath); err == nil {
+		config.TuneForModel(meta)
+		if debug {
+			fmt.Fprintf(os.Stderr, "[DEBUG] GGUF: %s %s %s, context=%d, layers=%d\n",
+				meta.Architecture, meta.Parameters(), meta.Quantization(), meta.ContextLength, meta.LayerCount)
+		}
+	} else if debug {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Could not read model metadata: %v\n", err)
+	}
+
 	// Ensure we have sensible defaults
 	// Note: ContextSize should be set by backend from model metadata
 	// If still 0 here, use a large default (will be limited by model's actual max)
@@ -219,11 +206,6 @@ func StartServerWithConfig(config *ServerConfig) (*Server, error) {
 		config.GPULayers = 99 // Default to full GPU
 	}
 
-	// Stop existing server if any
-	if globalServer != nil {
-		globalServer.Stop()
-	}
-
 	// Find llama-server binary
 	serverPath, err := findLlamaServer()
 	if err != nil {
@@ -234,7 +216,7 @@ func StartServerWithConfig(config *ServerConfig) (*Server, error) {
 	dataDir := getDataDir()
 	logDir := filepath.Join(dataDir, "logs")
 	os.MkdirAll(logDir, 0755)
-	logPath := filepath.Join(logDir, "llama-server.log")
+	logPath := serverLogPath(dataDir, config.Port)
 	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: could not create log file: %v\n", err)
@@ -244,6 +226,11 @@ func StartServerWithConfig(config *ServerConfig) (*Server, error) {
 	// Check if CPU-only mode is enabled (for more conservative memory settings)
 	cpuOnly := os.Getenv("SCMD_CPU_ONLY") != ""
 
+	threads := config.Threads
+	if threads <= 0 || cpuOnly {
+		threads = runtime.NumCPU()
+	}
+
 	// Build arguments - use conservative settings for CPU-only mode
 	args := []string{
 		"-m", config.ModelPath,
@@ -264,7 +251,7 @@ func StartServerWithConfig(config *ServerConfig) (*Server, error) {
 			"--mlock", // Lock model in memory (prevent swapping)
 
 			// Threading optimizations
-			"-t", fmt.Sprintf("%d", runtime.NumCPU()), // Use all CPU threads for prompt processing
+			"-t", fmt.Sprintf("%d", threads), // Generation threads, fewer when fully offloaded
 			"--threads-batch", fmt.Sprintf("%d", runtime.NumCPU()), // Use all threads for batch processing
 
 			// Cache optimizations
@@ -385,10 +372,9 @@ func StartServerWithConfig(config *ServerConfig) (*Server, error) {
 	}
 
 	server.ready = true
-	globalServer = server
 
 	// Write PID file for management
-	pidPath := filepath.Join(dataDir, "llama-server.pid")
+	pidPath := pidFilePath(dataDir, config.Port)
 	os.WriteFile(pidPath, []byte(fmt.Sprintf("%d", cmd.Process.Pid)), 0644)
 
 	if debug {
@@ -462,9 +448,7 @@ func (s *Server) Stop() error {
 		}
 
 		// Clean up PID file
-		dataDir := getDataDir()
-		pidPath := filepath.Join(dataDir, "llama-server.pid")
-		os.Remove(pidPath)
+		os.Remove(pidFilePath(getDataDir(), s.port))
 	}
 
 	if s.logFile != nil {
@@ -476,30 +460,17 @@ func (s *Server) Stop() error {
 	return nil
 }
 
-// Complete sends a completion request to the server
-func (s *Server) Complete(ctx context.Context, prompt string, req *backend.CompletionRequest) (string, error) {
+// Complete sends a completion request to the server. Cancelling ctx
+// closes the connection, which llama-server takes as the signal to stop
+// generating and free the slot.
+func (s *Server) Complete(ctx context.Context, prompt string, req *backend.CompletionRequest) (*CompletionResult, error) {
 	debug := os.Getenv("SCMD_DEBUG") != ""
 	url := fmt.Sprintf("http://127.0.0.1:%d/completion", s.port)
+	markActive(s.port)
 
-	// Build request body
-	reqBody := map[string]interface{}{
-		"prompt":      prompt,
-		"n_predict":   req.MaxTokens,
-		"temperature": req.Temperature,
-		"stop":        []string{"<|im_end|>", "<|endoftext|>"},
-		"stream":      false,
-	}
-
-	if req.MaxTokens == 0 {
-		reqBody["n_predict"] = 2048
-	}
-	if req.Temperature == 0 {
-		reqBody["temperature"] = 0.7
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
+	jsonBody, err := json.Marshal(completionBody(prompt, req, false))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if debug {
@@ -508,7 +479,7 @@ func (s *Server) Complete(ctx context.Context, prompt string, req *backend.Compl
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
@@ -524,7 +495,7 @@ func (s *Server) Complete(ctx context.Context, prompt string, req *backend.Compl
 	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("HTTP request failed: %w", err)
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -534,7 +505,7 @@ func (s *Server) Complete(ctx context.Context, prompt string, req *backend.Compl
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
+		return nil, fmt.Errorf("read response: %w", err)
 	}
 
 	if debug {
@@ -545,31 +516,28 @@ func (s *Server) Complete(ctx context.Context, prompt string, req *backend.Compl
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody))
 	}
 
 	// Parse response - llama-server returns {"content": "...", ...}
-	var result struct {
-		Content string `json:"content"`
-	}
+	var result completionResponse
 
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", fmt.Errorf("parse response: %w\nRaw: %s", err, string(respBody))
+		return nil, fmt.Errorf("parse response: %w\nRaw: %s", err, string(respBody))
 	}
 
-	content := strings.TrimSpace(result.Content)
-	if content == "" {
+	if strings.TrimSpace(result.Content) == "" {
 		// Check if there was an error in the response
 		var errResult struct {
 			Error string `json:"error"`
 		}
 		if json.Unmarshal(respBody, &errResult) == nil && errResult.Error != "" {
-			return "", fmt.Errorf("llama-server: %s", errResult.Error)
+			return nil, fmt.Errorf("llama-server: %s", errResult.Error)
 		}
-		return "", fmt.Errorf("empty response from model.\nPrompt was: %s...\nResponse: %s", truncate(prompt, 100), string(respBody))
+		return nil, fmt.Errorf("empty response from model.\nPrompt was: %s...\nResponse: %s", truncate(prompt, 100), string(respBody))
 	}
 
-	return content, nil
+	return result.toResult(), nil
 }
 
 func truncate(s string, n int) string {
@@ -635,19 +603,21 @@ func findLlamaServer() (string, error) {
 	return "", fmt.Errorf("llama-server not found. Install with: make install-llamacpp")
 }
 
-// runServerInference uses llama-server for inference
-func (b *Backend) runServerInference(ctx context.Context, prompt string, req *backend.CompletionRequest) (string, error) {
-	debug := os.Getenv("SCMD_DEBUG") != ""
-
-	// Use existing server URL
-	url := b.serverURL + "/completion"
+// completionBody builds a llama-server /completion request body. The
+// Backend puts its chat template's stop words in req.StopSequences;
+// requests without any get the ChatML ones.
+func completionBody(prompt string, req *backend.CompletionRequest, stream bool) map[string]interface{} {
+	stop := req.StopSequences
+	if len(stop) == 0 {
+		stop = ChatML.Stop
+	}
 
 	reqBody := map[string]interface{}{
 		"prompt":      prompt,
 		"n_predict":   req.MaxTokens,
 		"temperature": req.Temperature,
-		"stop":        []string{"<|im_end|>", "<|endoftext|>"},
-		"stream":      false,
+		"stop":        stop,
+		"stream":      stream,
 	}
 
 	if req.MaxTokens == 0 {
@@ -656,15 +626,317 @@ func (b *Backend) runServerInference(ctx context.Context, prompt string, req *ba
 	if req.Temperature == 0 {
 		reqBody["temperature"] = 0.7
 	}
+	setSamplingParams(reqBody, req.Sampling)
+
+	// llama-server turns json_schema into a grammar itself; plain JSON
+	// mode uses the generic object grammar
+	if f := req.ResponseFormat; f != nil {
+		if f.Type == backend.ResponseJSONSchema && f.Schema != nil {
+			reqBody["json_schema"] = f.Schema
+		} else {
+			reqBody["grammar"] = jsonObjectGrammar
+		}
+	}
 
-	jsonBody, err := json.Marshal(reqBody)
+	return reqBody
+}
+
+// jsonObjectGrammar is a GBNF grammar accepting a single JSON object,
+// adapted from llama.cpp's grammars/json.gbnf
+const jsonObjectGrammar = `root   ::= object
+value  ::= object | array | string | number | ("true" | "false" | "null") ws
+
+object ::=
+  "{" ws (
+            string ":" ws value
+    ("," ws string ":" ws value)*
+  )? "}" ws
+
+array  ::=
+  "[" ws (
+            value
+    ("," ws value)*
+  )? "]" ws
+
+string ::=
+  "\"" (
+    [^"\\\x7F\x00-\x1F] |
+    "\\" (["\\bfnrt] | "u" [0-9a-fA-F]{4})
+  )* "\"" ws
+
+number ::= ("-"? ([0-9] | [1-9] [0-9]{0,15})) ("." [0-9]+)? ([eE] [-+]? [0-9] [1-9]{0,15})? ws
+
+ws ::= | " " | "\n" [ \t]{0,20}
+`
+
+// setSamplingParams copies the sampling controls into a /completion body
+func setSamplingParams(reqBody map[string]interface{}, s backend.Sampling) {
+	if s.TopP > 0 {
+		reqBody["top_p"] = s.TopP
+	}
+	if s.TopK > 0 {
+		reqBody["top_k"] = s.TopK
+	}
+	if s.MinP > 0 {
+		reqBody["min_p"] = s.MinP
+	}
+	if s.Seed != nil {
+		reqBody["seed"] = *s.Seed
+	}
+	if s.RepeatPenalty > 0 {
+		reqBody["repeat_penalty"] = s.RepeatPenalty
+	}
+	if s.FrequencyPenalty != 0 {
+		reqBody["frequency_penalty"] = s.FrequencyPenalty
+	}
+	if s.PresencePenalty != 0 {
+		reqBody["presence_penalty"] = s.PresencePenalty
+	}
+	if len(s.LogitBias) > 0 {
+		// llama-server takes [[token, bias], ...] where token is an ID or
+		// a string it tokenizes itself
+		tokens := make([]string, 0, len(s.LogitBias))
+		for token := range s.LogitBias {
+			tokens = append(tokens, token)
+		}
+		sort.Strings(tokens)
+
+		bias := make([][]interface{}, 0, len(tokens))
+		for _, token := range tokens {
+			var key interface{} = token
+			if id, err := strconv.Atoi(token); err == nil {
+				key = id
+			}
+			bias = append(bias, []interface{}{key, s.LogitBias[token]})
+		}
+		reqBody["logit_bias"] = bias
+	}
+}
+
+// serverTimings is the timings block llama-server attaches to final responses
+type serverTimings struct {
+	PromptN            int     `json:"prompt_n"`
+	PromptMS           float64 `json:"prompt_ms"`
+	PredictedN         int     `json:"predicted_n"`
+	PredictedMS        float64 `json:"predicted_ms"`
+	PredictedPerSecond float64 `json:"predicted_per_second"`
+}
+
+// toTiming converts server timings into backend timing information
+func (t *serverTimings) toTiming() *backend.Timing {
+	if t == nil {
+		return nil
+	}
+	return &backend.Timing{
+		PromptMS:     int64(t.PromptMS),
+		CompletionMS: int64(t.PredictedMS),
+		TokensPerSec: t.PredictedPerSecond,
+	}
+}
+
+// streamEvent is a single server-sent event from a streaming /completion call
+type streamEvent struct {
+	completionResponse
+	Stop  bool `json:"stop"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// completionResponse is a non-streaming llama-server /completion response
+type completionResponse struct {
+	Content         string         `json:"content"`
+	TokensEvaluated int            `json:"tokens_evaluated"`
+	TokensPredicted int            `json:"tokens_predicted"`
+	StopType        string         `json:"stop_type"`     // eos, limit or word
+	StoppedLimit    bool           `json:"stopped_limit"` // older servers
+	Timings         *serverTimings `json:"timings,omitempty"`
+}
+
+// CompletionResult is the parsed outcome of a llama-server completion
+type CompletionResult struct {
+	Content          string
+	PromptTokens     int
+	CompletionTokens int
+	FinishReason     backend.FinishReason
+	Timing           *backend.Timing
+}
+
+// toResult converts a raw server response into a CompletionResult.
+// tokens_evaluated counts the whole prompt, while timings.prompt_n only
+// counts tokens not served from the prompt cache, so prefer the former.
+func (r *completionResponse) toResult() *CompletionResult {
+	result := &CompletionResult{
+		Content:          strings.TrimSpace(r.Content),
+		PromptTokens:     r.TokensEvaluated,
+		CompletionTokens: r.TokensPredicted,
+		FinishReason:     backend.FinishComplete,
+		Timing:           r.Timings.toTiming(),
+	}
+
+	if r.Timings != nil {
+		if result.PromptTokens == 0 {
+			result.PromptTokens = r.Timings.PromptN
+		}
+		if result.CompletionTokens == 0 {
+			result.CompletionTokens = r.Timings.PredictedN
+		}
+	}
+
+	switch {
+	case r.StopType == "limit" || r.StoppedLimit:
+		result.FinishReason = backend.FinishLength
+	case r.StopType == "word":
+		result.FinishReason = backend.FinishStop
+	}
+
+	return result
+}
+
+// acquireServer returns the llama-server for the backend's model,
+// starting one in the pool if needed. Call release when the request is
+// done so the pool may stop the server for another model.
+func (b *Backend) acquireServer() (baseURL string, release func(), err error) {
+	if b.serverURL != "" {
+		return b.serverURL, func() {}, nil
+	}
+
+	server, release, err := defaultPool.Acquire(b.serverConfig())
 	if err != nil {
-		return "", ParseError(err)
+		return "", nil, ParseError(err)
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", server.Port()), release, nil
+}
+
+// serverConfig is the llama-server configuration for the backend's model
+func (b *Backend) serverConfig() *ServerConfig {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	config := DefaultServerConfig(b.modelPath)
+	config.ContextSize = b.contextSize
+	return config
+}
+
+// runServerStream streams tokens from llama-server's SSE /completion output.
+// Cancelling ctx aborts the HTTP request, which stops generation server-side.
+func (b *Backend) runServerStream(ctx context.Context, prompt string, req *backend.CompletionRequest, ch chan<- backend.StreamChunk) {
+	debug := os.Getenv("SCMD_DEBUG") != ""
+
+	send := func(chunk backend.StreamChunk) bool {
+		select {
+		case ch <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	baseURL, release, err := b.acquireServer()
+	if err != nil {
+		send(backend.StreamChunk{Error: err})
+		return
+	}
+	defer release()
+
+	jsonBody, err := json.Marshal(completionBody(prompt, req, true))
+	if err != nil {
+		send(backend.StreamChunk{Error: ParseError(err)})
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/completion", bytes.NewReader(jsonBody))
+	if err != nil {
+		send(backend.StreamChunk{Error: ParseError(err)})
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	if debug {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Streaming from %s/completion\n", baseURL)
+	}
+
+	// No client timeout: a long generation is legitimate while tokens keep
+	// arriving, and ctx governs the request lifetime
+	resp, err := (&http.Client{}).Do(httpReq)
+	if err != nil {
+		send(backend.StreamChunk{Error: ParseError(err)})
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		send(backend.StreamChunk{Error: ParseError(fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody)))})
+		return
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			send(backend.StreamChunk{Error: ParseError(fmt.Errorf("parse stream event: %w", err))})
+			return
+		}
+
+		if event.Error != nil {
+			send(backend.StreamChunk{Error: ParseError(fmt.Errorf("llama-server: %s", event.Error.Message))})
+			return
+		}
+
+		if event.Stop {
+			result := event.toResult()
+			send(backend.StreamChunk{
+				Content: event.Content,
+				Timing:  result.Timing,
+				Usage: &backend.Usage{
+					PromptTokens:     result.PromptTokens,
+					CompletionTokens: result.CompletionTokens,
+				},
+				Done: true,
+			})
+			return
+		}
+
+		if event.Content != "" && !send(backend.StreamChunk{Content: event.Content}) {
+			return
+		}
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	err = scanner.Err()
+	if err == nil {
+		// The body closed before a stop event, e.g. llama-server crashed
+		err = io.ErrUnexpectedEOF
+	}
+	send(backend.StreamChunk{Error: ParseError(err)})
+}
+
+// runServerInference uses llama-server for inference. As with Stream,
+// cancelling ctx closes the connection and stops generation server-side.
+func (b *Backend) runServerInference(ctx context.Context, prompt string, req *backend.CompletionRequest) (*CompletionResult, error) {
+	debug := os.Getenv("SCMD_DEBUG") != ""
+
+	// Use existing server URL
+	url := b.serverURL + "/completion"
+
+	jsonBody, err := json.Marshal(completionBody(prompt, req, false))
+	if err != nil {
+		return nil, ParseError(err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", ParseError(err)
+		return nil, ParseError(err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
@@ -680,62 +952,168 @@ func (b *Backend) runServerInference(ctx context.Context, prompt string, req *ba
 	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return "", ParseError(err)
+		return nil, ParseError(err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", ParseError(fmt.Errorf("read response: %w", err))
+		return nil, ParseError(fmt.Errorf("read response: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", ParseError(fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody)))
+		return nil, ParseError(fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody)))
 	}
 
-	var result struct {
-		Content string `json:"content"`
-	}
+	var result completionResponse
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", ParseError(fmt.Errorf("parse response: %w", err))
+		return nil, ParseError(fmt.Errorf("parse response: %w", err))
 	}
 
-	content := strings.TrimSpace(result.Content)
-	if content == "" {
-		return "", ParseError(fmt.Errorf("empty response from server"))
+	if strings.TrimSpace(result.Content) == "" {
+		return nil, ParseError(fmt.Errorf("empty response from server"))
 	}
 
-	return content, nil
+	return result.toResult(), nil
 }
 
 // runCGOInference uses CGO bindings for direct inference
 // This requires the go-llama.cpp library to be properly linked
-func (b *Backend) runCGOInference(ctx context.Context, prompt string, req *backend.CompletionRequest) (string, error) {
+func (b *Backend) runCGOInference(ctx context.Context, prompt string, req *backend.CompletionRequest) (*CompletionResult, error) {
 	// Start a server if not running
-	server, err := StartServer(b.modelPath, 8089)
+	server, release, err := defaultPool.Acquire(b.serverConfig())
 	if err != nil {
-		return "", ParseError(err)
+		return nil, ParseError(err)
 	}
+	defer release()
 
 	result, err := server.Complete(ctx, prompt, req)
 	if err != nil {
-		return "", ParseError(err)
+		return nil, ParseError(err)
 	}
 
 	return result, nil
 }
 
+// tokenize counts tokens with llama-server's /tokenize endpoint
+func (b *Backend) tokenize(ctx context.Context, baseURL, text string) (int, error) {
+	jsonBody, err := json.Marshal(map[string]string{"content": text})
+	if err != nil {
+		return 0, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/tokenize", bytes.NewReader(jsonBody))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("tokenize failed (HTTP %d)", resp.StatusCode)
+	}
+
+	var result struct {
+		Tokens []json.RawMessage `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("parse tokenize response: %w", err)
+	}
+
+	return len(result.Tokens), nil
+}
+
+// Embed embeds texts with llama-server's OpenAI-compatible /v1/embeddings
+// endpoint. llama-server only serves it when started with --embeddings,
+// usually with a dedicated embedding model.
+func (b *Backend) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	baseURL, release, err := b.acquireServer()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	jsonBody, err := json.Marshal(map[string]interface{}{"input": texts})
+	if err != nil {
+		return nil, ParseError(err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/embeddings", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, ParseError(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: 2 * time.Minute}).Do(httpReq)
+	if err != nil {
+		return nil, ParseError(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ParseError(fmt.Errorf("read response: %w", err))
+	}
+
+	if resp.StatusCode == http.StatusNotImplemented {
+		b.setEmbeddings(false)
+		return nil, fmt.Errorf("llama-server was not started with --embeddings: %w", backend.ErrEmbeddingsUnsupported)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, ParseError(fmt.Errorf("server error (HTTP %d): %s", resp.StatusCode, string(respBody)))
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, ParseError(fmt.Errorf("parse embeddings response: %w", err))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("invalid embedding index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("missing embedding for input %d", i)
+		}
+	}
+
+	b.setEmbeddings(true)
+	return vectors, nil
+}
+
+// setEmbeddings records whether the server supports embeddings
+func (b *Backend) setEmbeddings(supported bool) {
+	b.mu.Lock()
+	b.embeddings = &supported
+	b.mu.Unlock()
+}
+
 // SetServerURL sets the URL of an external llama-server
 func (b *Backend) SetServerURL(url string) {
 	b.serverURL = url
 }
 
-// StopServer stops the global inference server
+// StopServer stops the llama-servers this process started and those
+// recorded in PID files, e.g. started by earlier scmd commands
 func StopServer() {
-	serverMu.Lock()
-	defer serverMu.Unlock()
-	if globalServer != nil {
-		globalServer.Stop()
-		globalServer = nil
+	defaultPool.StopAll()
+
+	paths, _ := filepath.Glob(filepath.Join(getDataDir(), "llama-server*.pid"))
+	for _, pidPath := range paths {
+		stopPIDFile(pidPath)
 	}
 }
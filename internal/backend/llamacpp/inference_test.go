@@ -0,0 +1,378 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+func TestBackend_RunServerStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/completion", r.URL.Path)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"])
+		assert.Equal(t, "prompt", body["prompt"])
+
+		for _, tok := range []string{"Hel", "lo", "!"} {
+			fmt.Fprintf(w, "data: {\"content\":%q,\"stop\":false}\n\n", tok)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: {\"content\":\"\",\"stop\":true,\"tokens_evaluated\":5,\"tokens_predicted\":3,\"timings\":{\"prompt_n\":5,\"prompt_ms\":12.5,\"predicted_n\":3,\"predicted_ms\":30.2,\"predicted_per_second\":99.3}}\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	ch := make(chan backend.StreamChunk, 10)
+	b.runServerStream(context.Background(), "prompt", &backend.CompletionRequest{}, ch)
+	close(ch)
+
+	var chunks []backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		chunks = append(chunks, chunk)
+	}
+
+	require.Len(t, chunks, 4)
+	assert.Equal(t, "Hel", chunks[0].Content)
+	assert.Equal(t, "lo", chunks[1].Content)
+	assert.Equal(t, "!", chunks[2].Content)
+
+	final := chunks[3]
+	assert.True(t, final.Done)
+	require.NotNil(t, final.Timing)
+	assert.Equal(t, int64(12), final.Timing.PromptMS)
+	assert.Equal(t, int64(30), final.Timing.CompletionMS)
+	assert.InDelta(t, 99.3, final.Timing.TokensPerSec, 0.001)
+	require.NotNil(t, final.Usage)
+	assert.Equal(t, 5, final.Usage.PromptTokens)
+	assert.Equal(t, 3, final.Usage.CompletionTokens)
+}
+
+func TestBackend_RunServerStream_Cancel(t *testing.T) {
+	closed := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"content\":\"first\",\"stop\":false}\n\n")
+		w.(http.Flusher).Flush()
+
+		// Keep generating until the client goes away
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan backend.StreamChunk)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.runServerStream(ctx, "prompt", &backend.CompletionRequest{}, ch)
+	}()
+
+	chunk := <-ch
+	assert.Equal(t, "first", chunk.Content)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not stop after cancellation")
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server request was not closed after cancellation")
+	}
+}
+
+func TestBackend_RunServerStream_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":{"message":"loading model"}}`)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	ch := make(chan backend.StreamChunk, 1)
+	b.runServerStream(context.Background(), "prompt", &backend.CompletionRequest{}, ch)
+
+	chunk := <-ch
+	assert.Error(t, chunk.Error)
+}
+
+func TestBackend_RunServerStream_Truncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Server goes away mid-generation without a stop event
+		fmt.Fprint(w, "data: {\"content\":\"partial\",\"stop\":false}\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	ch := make(chan backend.StreamChunk, 10)
+	b.runServerStream(context.Background(), "prompt", &backend.CompletionRequest{}, ch)
+	close(ch)
+
+	var chunks []backend.StreamChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "partial", chunks[0].Content)
+	assert.False(t, chunks[1].Done)
+	assert.ErrorIs(t, chunks[1].Error, io.ErrUnexpectedEOF)
+}
+
+func TestCompletionResponse_ToResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		resp       completionResponse
+		prompt     int
+		completion int
+		finish     backend.FinishReason
+	}{
+		{
+			name:       "token counts",
+			resp:       completionResponse{Content: " hi\n", TokensEvaluated: 40, TokensPredicted: 8, StopType: "eos"},
+			prompt:     40,
+			completion: 8,
+			finish:     backend.FinishComplete,
+		},
+		{
+			name:       "prompt cache hit prefers tokens_evaluated",
+			resp:       completionResponse{TokensEvaluated: 40, TokensPredicted: 8, Timings: &serverTimings{PromptN: 2, PredictedN: 8}},
+			prompt:     40,
+			completion: 8,
+			finish:     backend.FinishComplete,
+		},
+		{
+			name:       "timings fallback",
+			resp:       completionResponse{Timings: &serverTimings{PromptN: 12, PredictedN: 6}},
+			prompt:     12,
+			completion: 6,
+			finish:     backend.FinishComplete,
+		},
+		{
+			name:   "length limit",
+			resp:   completionResponse{StopType: "limit"},
+			finish: backend.FinishLength,
+		},
+		{
+			name:   "older servers report stopped_limit",
+			resp:   completionResponse{StoppedLimit: true},
+			finish: backend.FinishLength,
+		},
+		{
+			name:   "stop word",
+			resp:   completionResponse{StopType: "word"},
+			finish: backend.FinishStop,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.resp.toResult()
+			assert.Equal(t, tt.prompt, result.PromptTokens)
+			assert.Equal(t, tt.completion, result.CompletionTokens)
+			assert.Equal(t, tt.finish, result.FinishReason)
+		})
+	}
+
+	assert.Equal(t, "hi", tests[0].resp.toResult().Content)
+}
+
+func TestBackend_Complete_Usage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/completion", r.URL.Path)
+		fmt.Fprint(w, `{"content":"hello","tokens_evaluated":21,"tokens_predicted":4,"stop_type":"eos","timings":{"prompt_n":21,"predicted_n":4,"predicted_per_second":40}}`)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	result, err := b.runServerInference(context.Background(), "prompt", &backend.CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", result.Content)
+	assert.Equal(t, 21, result.PromptTokens)
+	assert.Equal(t, 4, result.CompletionTokens)
+	assert.Equal(t, backend.FinishComplete, result.FinishReason)
+	require.NotNil(t, result.Timing)
+	assert.InDelta(t, 40.0, result.Timing.TokensPerSec, 0.001)
+}
+
+func TestBackend_EstimateTokens_Tokenize(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/tokenize", r.URL.Path)
+		calls++
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "hello world", body["content"])
+		fmt.Fprint(w, `{"tokens":[15339,1917]}`)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	assert.Equal(t, 2, b.EstimateTokens("hello world"))
+	assert.Equal(t, 2, b.EstimateTokens("hello world"))
+	assert.Equal(t, 1, calls, "repeated counts should be served from the cache")
+}
+
+func TestBackend_EstimateTokens_Fallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	// Old servers without /tokenize fall back to the heuristic
+	assert.Equal(t, 4, b.EstimateTokens("sixteen chars!!!"))
+	assert.Equal(t, 0, b.EstimateTokens(""))
+}
+
+func TestCompletionBody_ResponseFormat(t *testing.T) {
+	schema := map[string]interface{}{"type": "object"}
+
+	body := completionBody("p", &backend.CompletionRequest{}, false)
+	assert.NotContains(t, body, "grammar")
+	assert.NotContains(t, body, "json_schema")
+
+	body = completionBody("p", &backend.CompletionRequest{
+		ResponseFormat: &backend.ResponseFormat{Type: backend.ResponseJSON},
+	}, false)
+	assert.Equal(t, jsonObjectGrammar, body["grammar"])
+
+	body = completionBody("p", &backend.CompletionRequest{
+		ResponseFormat: &backend.ResponseFormat{Type: backend.ResponseJSONSchema, Schema: schema},
+	}, false)
+	assert.Equal(t, schema, body["json_schema"])
+	assert.NotContains(t, body, "grammar")
+}
+
+func TestBackend_Embed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		var body struct {
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, []string{"a", "b"}, body.Input)
+		fmt.Fprint(w, `{"object":"list","data":[{"index":0,"embedding":[0.5,0.5]},{"index":1,"embedding":[1,0]}]}`)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+	assert.False(t, b.ModelInfo().HasCapability(backend.CapabilityEmbeddings), "unknown until tried")
+
+	vectors, err := b.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{0.5, 0.5}, {1, 0}}, vectors)
+	assert.True(t, b.ModelInfo().HasCapability(backend.CapabilityEmbeddings))
+}
+
+func TestBackend_Embed_NotEnabled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, `{"error":{"code":501,"message":"This server does not support embeddings. Start it with `+"`--embeddings`"+`","type":"not_supported_error"}}`)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	_, err := b.Embed(context.Background(), []string{"a"})
+	assert.ErrorIs(t, err, backend.ErrEmbeddingsUnsupported)
+	assert.False(t, b.ModelInfo().HasCapability(backend.CapabilityEmbeddings))
+}
+
+func TestCompletionBody_Sampling(t *testing.T) {
+	seed := int64(42)
+	body := completionBody("p", &backend.CompletionRequest{
+		Sampling: backend.Sampling{
+			TopP:            0.9,
+			TopK:            40,
+			MinP:            0.05,
+			Seed:            &seed,
+			RepeatPenalty:   1.1,
+			PresencePenalty: 0.5,
+			LogitBias:       map[string]float64{"15339": 2, "Hello": -1},
+		},
+	}, false)
+
+	assert.Equal(t, 0.9, body["top_p"])
+	assert.Equal(t, 40, body["top_k"])
+	assert.Equal(t, 0.05, body["min_p"])
+	assert.Equal(t, int64(42), body["seed"])
+	assert.Equal(t, 1.1, body["repeat_penalty"])
+	assert.Equal(t, 0.5, body["presence_penalty"])
+	assert.NotContains(t, body, "frequency_penalty")
+	assert.Equal(t, [][]interface{}{{15339, 2.0}, {"Hello", -1.0}}, body["logit_bias"])
+}
+
+func TestBackend_RunServerInference_Cancel(t *testing.T) {
+	closed := make(chan struct{})
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		close(started)
+		// A non-streaming completion answers only when done
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer srv.Close()
+
+	b := New(t.TempDir())
+	b.SetServerURL(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := b.runServerInference(ctx, "prompt", &backend.CompletionRequest{})
+		errs <- err
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.Canceled)
+		var backendErr *BackendError
+		assert.False(t, errors.As(err, &backendErr), "cancellation is not reported as a server error")
+	case <-time.After(5 * time.Second):
+		t.Fatal("completion did not return after cancellation")
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server request was not closed after cancellation")
+	}
+}
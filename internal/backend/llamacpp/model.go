@@ -12,20 +12,40 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/scmd/scmd/internal/backend"
 )
 
 // Model represents a downloadable model
 type Model struct {
-	Name        string `json:"name"`
-	Variant     string `json:"variant"` // e.g., "Q4_K_M", "Q8_0"
-	URL         string `json:"url"`
-	Size        int64  `json:"size"` // bytes
-	SHA256      string `json:"sha256"`
-	Description string `json:"description"`
-	ContextSize int    `json:"context_size"`
-	ToolCalling bool   `json:"tool_calling"`
+	Name        string   `json:"name" yaml:"name"`
+	Variant     string   `json:"variant" yaml:"variant,omitempty"` // e.g., "Q4_K_M", "Q8_0"
+	URL         string   `json:"url" yaml:"url,omitempty"`
+	Mirrors     []string `json:"mirrors,omitempty" yaml:"mirrors,omitempty"` // Tried in order when URL fails
+	Path        string   `json:"path,omitempty" yaml:"path,omitempty"`       // Local GGUF file, used instead of URL
+	Size        int64    `json:"size" yaml:"size,omitempty"`                 // bytes
+	SHA256      string   `json:"sha256" yaml:"sha256,omitempty"`
+	Description string   `json:"description" yaml:"description,omitempty"`
+	ContextSize int      `json:"context_size" yaml:"context_size,omitempty"`
+	ToolCalling bool     `json:"tool_calling" yaml:"tool_calling,omitempty"`
+
+	// ChatTemplate names the prompt format, e.g. "llama3"; empty means
+	// detect it from the GGUF header
+	ChatTemplate string `json:"chat_template,omitempty" yaml:"chat_template,omitempty"`
+}
+
+// URLs returns the download URL followed by the mirrors
+func (m *Model) URLs() []string {
+	return append([]string{m.URL}, m.Mirrors...)
+}
+
+// Filename returns the name the model is stored under in the models directory
+func (m *Model) Filename() string {
+	if m.Variant == "" {
+		return m.Name + ".gguf"
+	}
+	return fmt.Sprintf("%s-%s.gguf", m.Name, m.Variant)
 }
 
 // DefaultModels contains pre-configured models
@@ -84,33 +104,46 @@ var DefaultModels = []Model{
 // ModelManager handles model downloading and management
 type ModelManager struct {
 	modelsDir  string
+	models     []Model // DefaultModels plus the user catalog
 	httpClient *http.Client
+	workers    int // Parallel connections per download; 0 = default
 	mu         sync.Mutex
 }
 
-// NewModelManager creates a new model manager
+// NewModelManager creates a new model manager. Models defined in
+// models.yaml in dataDir are added to DefaultModels; a broken catalog is
+// reported and otherwise ignored.
 func NewModelManager(dataDir string) *ModelManager {
+	catalog, err := LoadCatalog(dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
 	return &ModelManager{
 		modelsDir:  filepath.Join(dataDir, "models"),
+		models:     mergeModels(DefaultModels, catalog),
 		httpClient: &http.Client{},
 	}
 }
 
+// FindModel returns the model called name
+func (m *ModelManager) FindModel(name string) (*Model, bool) {
+	for i := range m.models {
+		if m.models[i].Name == name {
+			return &m.models[i], true
+		}
+	}
+	return nil, false
+}
+
 // GetModelPath returns the path to a model, downloading if necessary
 func (m *ModelManager) GetModelPath(ctx context.Context, modelName string) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Find model spec
-	var model *Model
-	for i := range DefaultModels {
-		if DefaultModels[i].Name == modelName {
-			model = &DefaultModels[i]
-			break
-		}
-	}
-
-	if model == nil {
+	model, ok := m.FindModel(modelName)
+	if !ok {
 		// Check if it's a local path
 		if _, err := os.Stat(modelName); err == nil {
 			return modelName, nil
@@ -118,14 +151,21 @@ func (m *ModelManager) GetModelPath(ctx context.Context, modelName string) (stri
 		return "", fmt.Errorf("unknown model: %s", modelName)
 	}
 
+	// Catalog models may point at a file instead of a URL
+	if model.Path != "" {
+		if _, err := os.Stat(model.Path); err != nil {
+			return "", fmt.Errorf("model %s: %w", model.Name, err)
+		}
+		return model.Path, nil
+	}
+
 	// Create models directory
 	if err := os.MkdirAll(m.modelsDir, 0755); err != nil {
 		return "", err
 	}
 
 	// Check if already downloaded
-	filename := fmt.Sprintf("%s-%s.gguf", model.Name, model.Variant)
-	modelPath := filepath.Join(m.modelsDir, filename)
+	modelPath := filepath.Join(m.modelsDir, model.Filename())
 
 	if _, err := os.Stat(modelPath); err == nil {
 		return modelPath, nil
@@ -149,10 +189,38 @@ func (m *ModelManager) GetModelPath(ctx context.Context, modelName string) (stri
 	return modelPath, nil
 }
 
-// downloadModel downloads a model from URL with enhanced retry and resume support
+// LocalPath returns the model file if it is on disk, without downloading.
+// name may also be a path to a GGUF file or a file in the models directory.
+func (m *ModelManager) LocalPath(name string) (string, bool) {
+	path := name
+	if model, ok := m.FindModel(name); ok {
+		path = model.Path
+		if path == "" {
+			path = filepath.Join(m.modelsDir, model.Filename())
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		// A file name from ListDownloaded
+		path = filepath.Join(m.modelsDir, name)
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
+// SetDownloadWorkers sets how many connections a download uses
+func (m *ModelManager) SetDownloadWorkers(n int) {
+	m.workers = n
+}
+
+// downloadModel downloads a model over parallel range requests, falling
+// back to the model's mirrors, and verifies its checksum as it arrives
 func (m *ModelManager) downloadModel(ctx context.Context, model *Model, destPath string) error {
-	// Create enhanced downloader
-	downloader := NewEnhancedDownloader(DefaultDownloadConfig())
+	config := DefaultDownloadConfig()
+	if m.workers > 0 {
+		config.Workers = m.workers
+	}
+	downloader := NewEnhancedDownloader(config)
 
 	// Simple progress display
 	var lastPercent int
@@ -168,37 +236,20 @@ func (m *ModelManager) downloadModel(ctx context.Context, model *Model, destPath
 		}
 	}
 
-	// Download with all enhancements
-	if err := downloader.DownloadWithProgress(ctx, model.URL, destPath, model.Size, progressCallback); err != nil {
+	if err := downloader.DownloadFromMirrors(ctx, model.URLs(), destPath, model.Size, model.SHA256, progressCallback); err != nil {
 		return err
 	}
 
 	fmt.Println() // New line after progress
-
-	// Verify checksum if provided
 	if model.SHA256 != "" {
-		fmt.Printf("  Verifying download...\n")
-		if err := verifyChecksum(destPath, model.SHA256); err != nil {
-			os.Remove(destPath)
-			return &DownloadError{
-				Stage:   "verification",
-				Err:     err,
-				Message: "Downloaded file checksum doesn't match expected value.",
-				Help: []string{
-					"The file may be corrupted during download",
-					"Try downloading again",
-					"Check your internet connection",
-				},
-			}
-		}
+		fmt.Printf("  ✓ Checksum verified\n")
 	}
-
 	fmt.Printf("  ✓ Downloaded: %s\n", destPath)
 	return nil
 }
 
-// verifyChecksum verifies the SHA256 checksum of a file
-func verifyChecksum(path string, expectedHash string) error {
+// VerifyChecksum verifies the SHA256 checksum of a file
+func VerifyChecksum(path string, expectedHash string) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return err
@@ -211,16 +262,16 @@ func verifyChecksum(path string, expectedHash string) error {
 	}
 
 	gotHash := hex.EncodeToString(hash.Sum(nil))
-	if gotHash != expectedHash {
+	if !strings.EqualFold(gotHash, expectedHash) {
 		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHash, gotHash)
 	}
 
 	return nil
 }
 
-// ListModels returns available models
+// ListModels returns available models, including the user catalog
 func (m *ModelManager) ListModels() []Model {
-	return DefaultModels
+	return m.models
 }
 
 // ListDownloaded returns downloaded models
@@ -248,12 +299,11 @@ func (m *ModelManager) DeleteModel(name string) error {
 	defer m.mu.Unlock()
 
 	// Find model
-	for _, model := range DefaultModels {
-		if model.Name == name {
-			filename := fmt.Sprintf("%s-%s.gguf", model.Name, model.Variant)
-			modelPath := filepath.Join(m.modelsDir, filename)
-			return os.Remove(modelPath)
+	if model, ok := m.FindModel(name); ok {
+		if model.Path != "" {
+			return fmt.Errorf("model %s is a local file (%s); remove it from %s instead", name, model.Path, CatalogFile)
 		}
+		return os.Remove(filepath.Join(m.modelsDir, model.Filename()))
 	}
 
 	// Try as filename
@@ -296,8 +346,30 @@ type Backend struct {
 	// These will be set when CGO binding is available
 	// For now, we use HTTP API to llama-server as fallback
 	serverURL string
+
+	// httpClient is used for short llama-server calls such as /tokenize
+	httpClient *http.Client
+
+	// tokenCounts caches /tokenize results keyed by text
+	tokenCounts   map[string]int
+	tokenCountsMu sync.Mutex
+
+	// embeddings records whether llama-server answered /v1/embeddings,
+	// once Embed has found out; guarded by mu
+	embeddings *bool
+
+	// gguf caches the header of the model file at ggufPath; guarded by mu
+	gguf     *GGUFMetadata
+	ggufPath string
+
+	// siblings serve requests that name another model, keyed by model
+	// name; guarded by mu
+	siblings map[string]*Backend
 }
 
+// maxCachedTokenCounts bounds the EstimateTokens cache
+const maxCachedTokenCounts = 256
+
 // New creates a new llama.cpp backend
 func New(dataDir string) *Backend {
 	return &Backend{
@@ -305,6 +377,8 @@ func New(dataDir string) *Backend {
 		modelName:      GetDefaultModel(),
 		contextSize:    0, // 0 = use model's native context size (no limits)
 		contextSizeSet: false,
+		httpClient:     &http.Client{Timeout: 2 * time.Second},
+		tokenCounts:    make(map[string]int),
 	}
 }
 
@@ -338,16 +412,42 @@ func (b *Backend) GetContextSize() int {
 	}
 
 	// Otherwise, use model's native context size
-	for _, m := range DefaultModels {
-		if m.Name == b.modelName {
-			return m.ContextSize
-		}
-	}
+	return b.nativeContextSize()
+}
 
-	// Fallback: use 32K as default for unknown models
+// nativeContextSize returns the model's context length from the catalog,
+// else from the GGUF header, else 32K. Callers hold mu.
+func (b *Backend) nativeContextSize() int {
+	if m, ok := b.modelManager.FindModel(b.modelName); ok && m.ContextSize > 0 {
+		return m.ContextSize
+	}
+	if meta := b.metadata(); meta != nil && meta.ContextLength > 0 {
+		return meta.ContextLength
+	}
 	return 32768
 }
 
+// metadata returns the GGUF header of the current model, read once per
+// file. Nil until the model is on disk. Callers hold mu.
+func (b *Backend) metadata() *GGUFMetadata {
+	path := b.modelPath
+	if path == "" {
+		path, _ = b.modelManager.LocalPath(b.modelName)
+	}
+	if path == "" {
+		return nil
+	}
+
+	if path != b.ggufPath {
+		meta, err := ReadGGUFMetadata(path)
+		if err != nil && os.Getenv("SCMD_DEBUG") != "" {
+			fmt.Fprintf(os.Stderr, "[DEBUG] %v\n", err)
+		}
+		b.gguf, b.ggufPath = meta, path
+	}
+	return b.gguf
+}
+
 // Initialize initializes the backend
 func (b *Backend) Initialize(ctx context.Context) error {
 	b.mu.Lock()
@@ -368,18 +468,9 @@ func (b *Backend) Initialize(ctx context.Context) error {
 
 	// Set context size from model metadata if not explicitly set
 	if !b.contextSizeSet || b.contextSize == 0 {
-		for _, m := range DefaultModels {
-			if m.Name == b.modelName {
-				b.contextSize = m.ContextSize
-				if debug {
-					fmt.Fprintf(os.Stderr, "[DEBUG] Using model's native context size: %d\n", b.contextSize)
-				}
-				break
-			}
-		}
-		// Fallback
-		if b.contextSize == 0 {
-			b.contextSize = 32768
+		b.contextSize = b.nativeContextSize()
+		if debug {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Using model's native context size: %d\n", b.contextSize)
 		}
 	} else if debug {
 		fmt.Fprintf(os.Stderr, "[DEBUG] Using custom context size: %d\n", b.contextSize)
@@ -387,7 +478,7 @@ func (b *Backend) Initialize(ctx context.Context) error {
 
 	// Auto-start llama-server if not already running
 	// This is the key fix from the evaluation feedback
-	if !IsServerRunning(8089) {
+	if _, ok := defaultPool.Resident(modelPath); !ok {
 		if os.Getenv("SCMD_NO_AUTOSTART") == "" {
 			// Show helpful startup message (unless in quiet mode)
 			quiet := os.Getenv("SCMD_QUIET") != ""
@@ -465,12 +556,54 @@ func (b *Backend) IsAvailable(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
-// SetModel sets the model to use
+// forModel returns the backend for a request naming model: b itself, or
+// a sibling for the other model that shares b's model manager. Both keep
+// their own server in the pool. Names that are neither catalog models nor
+// model files fall back to b.
+func (b *Backend) forModel(model string) *Backend {
+	if model == "" || b.serverURL != "" {
+		return b
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if model == b.modelName {
+		return b
+	}
+	if sibling, ok := b.siblings[model]; ok {
+		return sibling
+	}
+	if _, ok := b.modelManager.FindModel(model); !ok {
+		if _, err := os.Stat(model); err != nil {
+			if os.Getenv("SCMD_DEBUG") != "" {
+				fmt.Fprintf(os.Stderr, "[DEBUG] Unknown model %q requested, using %s\n", model, b.modelName)
+			}
+			return b
+		}
+	}
+
+	sibling := &Backend{
+		modelManager: b.modelManager,
+		modelName:    model,
+		httpClient:   b.httpClient,
+		tokenCounts:  make(map[string]int),
+	}
+	if b.siblings == nil {
+		b.siblings = make(map[string]*Backend)
+	}
+	b.siblings[model] = sibling
+	return sibling
+}
+
+// SetModel sets the model to use. The previous model's server stays in
+// the pool until it is evicted.
 func (b *Backend) SetModel(model string) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	b.modelName = model
+	b.modelPath = ""
 	b.initialized = false // Force re-initialization
 	return nil
 }
@@ -487,6 +620,10 @@ func (b *Backend) ListModels(ctx context.Context) ([]string, error) {
 
 // Complete generates a completion
 func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	if m := b.forModel(req.Model); m != b {
+		return m.Complete(ctx, req)
+	}
+
 	debug := os.Getenv("SCMD_DEBUG") != ""
 
 	if err := b.Initialize(ctx); err != nil {
@@ -497,16 +634,28 @@ func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest)
 		fmt.Fprintf(os.Stderr, "[DEBUG] Model path: %s\n", b.modelPath)
 	}
 
-	// Build prompt with system message
-	prompt := b.buildPrompt(req)
-
+	tmpl := b.chatTemplate()
+	req = tmpl.withStop(req)
 	if debug {
-		fmt.Fprintf(os.Stderr, "[DEBUG] Prompt length: %d chars\n", len(prompt))
-		fmt.Fprintf(os.Stderr, "[DEBUG] Prompt preview: %s...\n", truncateStr(prompt, 200))
+		fmt.Fprintf(os.Stderr, "[DEBUG] Chat template: %s\n", tmpl.Name)
 	}
 
-	// Use inference engine
-	response, err := b.runInference(ctx, prompt, req)
+	var result *CompletionResult
+	var err error
+	if tmpl.ServerSide {
+		result, err = b.runChatInference(ctx, tmpl.conversation(req.SystemPrompt, req.ConversationMessages()), req)
+	} else {
+		// Build prompt with system message
+		prompt := buildPrompt(tmpl, req)
+
+		if debug {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Prompt length: %d chars\n", len(prompt))
+			fmt.Fprintf(os.Stderr, "[DEBUG] Prompt preview: %s...\n", truncateStr(prompt, 200))
+		}
+
+		// Use inference engine
+		result, err = b.runInference(ctx, prompt, req)
+	}
 	if err != nil {
 		if debug {
 			fmt.Fprintf(os.Stderr, "[DEBUG] Inference error: %v\n", err)
@@ -515,13 +664,18 @@ func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest)
 	}
 
 	if debug {
-		fmt.Fprintf(os.Stderr, "[DEBUG] Response length: %d chars\n", len(response))
-		fmt.Fprintf(os.Stderr, "[DEBUG] Response: %s\n", truncateStr(response, 500))
+		fmt.Fprintf(os.Stderr, "[DEBUG] Response length: %d chars\n", len(result.Content))
+		fmt.Fprintf(os.Stderr, "[DEBUG] Response: %s\n", truncateStr(result.Content, 500))
+		fmt.Fprintf(os.Stderr, "[DEBUG] Tokens: %d prompt, %d completion\n", result.PromptTokens, result.CompletionTokens)
 	}
 
 	return &backend.CompletionResponse{
-		Content:    response,
-		TokensUsed: 0, // TODO: track tokens
+		Content:          result.Content,
+		TokensUsed:       result.PromptTokens + result.CompletionTokens,
+		PromptTokens:     result.PromptTokens,
+		CompletionTokens: result.CompletionTokens,
+		FinishReason:     result.FinishReason,
+		Timing:           result.Timing,
 	}, nil
 }
 
@@ -534,54 +688,68 @@ func truncateStr(s string, n int) string {
 
 // Stream generates a streaming completion
 func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	if m := b.forModel(req.Model); m != b {
+		return m.Stream(ctx, req)
+	}
+
 	if err := b.Initialize(ctx); err != nil {
 		return nil, err
 	}
 
+	tmpl := b.chatTemplate()
+	req = tmpl.withStop(req)
 	ch := make(chan backend.StreamChunk, 100)
 
 	go func() {
 		defer close(ch)
 
-		// Build prompt
-		prompt := b.buildPrompt(req)
-
-		// For now, non-streaming fallback
-		response, err := b.runInference(ctx, prompt, req)
-		if err != nil {
-			ch <- backend.StreamChunk{Error: err}
+		if tmpl.ServerSide {
+			b.runChatStream(ctx, tmpl.conversation(req.SystemPrompt, req.ConversationMessages()), req, ch)
 			return
 		}
 
-		// Send as single chunk
-		ch <- backend.StreamChunk{Content: response, Done: true}
+		// Build prompt and forward tokens as llama-server produces them
+		prompt := buildPrompt(tmpl, req)
+		b.runServerStream(ctx, prompt, req, ch)
 	}()
 
 	return ch, nil
 }
 
-// buildPrompt constructs the prompt from request
-func (b *Backend) buildPrompt(req *backend.CompletionRequest) string {
-	var sb strings.Builder
+// buildPrompt renders the request in tmpl's prompt format
+func buildPrompt(tmpl *ChatTemplate, req *backend.CompletionRequest) string {
+	return tmpl.Render(req.SystemPrompt, req.ConversationMessages())
+}
 
-	// Qwen chat format
-	if req.SystemPrompt != "" {
-		sb.WriteString("<|im_start|>system\n")
-		sb.WriteString(req.SystemPrompt)
-		sb.WriteString("<|im_end|>\n")
-	}
+// chatTemplate picks the prompt format for the current model: the
+// catalog's chat_template if set, else the layout detected from the GGUF
+// header. Models whose embedded template is not a known layout are left
+// to llama-server; without a header, ChatML is assumed.
+func (b *Backend) chatTemplate() *ChatTemplate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	sb.WriteString("<|im_start|>user\n")
-	sb.WriteString(req.Prompt)
-	sb.WriteString("<|im_end|>\n")
-	sb.WriteString("<|im_start|>assistant\n")
+	if m, ok := b.modelManager.FindModel(b.modelName); ok && m.ChatTemplate != "" {
+		if t, ok := ChatTemplateByName(m.ChatTemplate); ok {
+			return t
+		}
+		if os.Getenv("SCMD_DEBUG") != "" {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Unknown chat template %q for %s, detecting\n", m.ChatTemplate, b.modelName)
+		}
+	}
 
-	return sb.String()
+	if meta := b.metadata(); meta != nil && meta.ChatTemplate != "" {
+		if t := DetectChatTemplate(meta.ChatTemplate); t != nil {
+			return t
+		}
+		return ServerTemplate
+	}
+	return ChatML
 }
 
 // runInference runs the actual inference
 // This is a placeholder - actual implementation depends on CGO bindings
-func (b *Backend) runInference(ctx context.Context, prompt string, req *backend.CompletionRequest) (string, error) {
+func (b *Backend) runInference(ctx context.Context, prompt string, req *backend.CompletionRequest) (*CompletionResult, error) {
 	// Check if we have a local llama-server running
 	if b.serverURL != "" {
 		return b.runServerInference(ctx, prompt, req)
@@ -607,40 +775,59 @@ func (b *Backend) SupportsToolCalling() bool {
 
 // CompleteWithTools generates a completion that may include tool calls
 func (b *Backend) CompleteWithTools(ctx context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	if m := b.forModel(req.Model); m != b {
+		return m.CompleteWithTools(ctx, req)
+	}
+
 	if err := b.Initialize(ctx); err != nil {
 		return nil, err
 	}
 
-	// Build prompt with tool definitions in Qwen format
-	prompt := b.buildToolPrompt(req)
+	tmpl := b.chatTemplate()
+	completionReq := tmpl.withStop(&req.CompletionRequest)
 
-	response, err := b.runInference(ctx, prompt, &req.CompletionRequest)
+	var result *CompletionResult
+	var err error
+	if tmpl.ServerSide {
+		turns := tmpl.conversation(toolSystemPrompt(req), req.ConversationMessages())
+		result, err = b.runChatInference(ctx, turns, completionReq)
+	} else {
+		// Build prompt with tool definitions
+		result, err = b.runInference(ctx, buildToolPrompt(tmpl, req), completionReq)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Parse response for tool calls
-	toolCalls := b.parseToolCalls(response)
+	toolCalls := b.parseToolCalls(result.Content)
 
 	return &backend.ToolResponse{
-		Content:   response,
+		Content:   result.Content,
 		ToolCalls: toolCalls,
+		Usage: &backend.Usage{
+			PromptTokens:     result.PromptTokens,
+			CompletionTokens: result.CompletionTokens,
+		},
 	}, nil
 }
 
 // buildToolPrompt constructs a prompt with tool definitions
-func (b *Backend) buildToolPrompt(req *backend.ToolRequest) string {
+func buildToolPrompt(tmpl *ChatTemplate, req *backend.ToolRequest) string {
+	return tmpl.Render(toolSystemPrompt(req), req.ConversationMessages())
+}
+
+// toolSystemPrompt appends the tool definitions to the system prompt
+func toolSystemPrompt(req *backend.ToolRequest) string {
 	var sb strings.Builder
 
-	// System message with tool definitions
-	sb.WriteString("<|im_start|>system\n")
 	if req.SystemPrompt != "" {
 		sb.WriteString(req.SystemPrompt)
 		sb.WriteString("\n\n")
 	}
 
-	// Add tool definitions if any
-	if len(req.Tools) > 0 {
+	// Add tool definitions if any; "none" means answer without them
+	if len(req.Tools) > 0 && req.ToolChoice != "none" {
 		sb.WriteString("You have access to the following tools:\n\n")
 		for _, tool := range req.Tools {
 			sb.WriteString(fmt.Sprintf("### %s\n", tool.Name))
@@ -660,12 +847,6 @@ func (b *Backend) buildToolPrompt(req *backend.ToolRequest) string {
 		sb.WriteString("To use a tool, respond with:\n")
 		sb.WriteString("<tool_call>{\"name\": \"tool_name\", \"parameters\": {...}}</tool_call>\n")
 	}
-	sb.WriteString("<|im_end|>\n")
-
-	sb.WriteString("<|im_start|>user\n")
-	sb.WriteString(req.Prompt)
-	sb.WriteString("<|im_end|>\n")
-	sb.WriteString("<|im_start|>assistant\n")
 
 	return sb.String()
 }
@@ -708,35 +889,106 @@ func (b *Backend) parseToolCalls(response string) []backend.ToolCall {
 	return calls
 }
 
-// ModelInfo returns information about the current model
+// ModelInfo returns information about the current model. Catalog
+// entries take precedence; anything they leave out comes from the GGUF
+// header once the model file is on disk.
 func (b *Backend) ModelInfo() *backend.ModelInfo {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Find model in defaults
-	for _, m := range DefaultModels {
-		if m.Name == b.modelName {
-			return &backend.ModelInfo{
-				Name:          m.Name,
-				Size:          formatBytes(m.Size),
-				Quantization:  m.Variant,
-				ContextLength: m.ContextSize,
-				Capabilities:  []string{"chat", "tool_calling"},
+	info := &backend.ModelInfo{
+		Name:          b.modelName,
+		ContextLength: b.contextSize,
+	}
+	toolCalling := false
+
+	// Find model in the catalog
+	m, known := b.modelManager.FindModel(b.modelName)
+	if known {
+		info.Size = formatBytes(m.Size)
+		info.Quantization = m.Variant
+		info.ContextLength = m.ContextSize
+		toolCalling = m.ToolCalling
+	}
+
+	if meta := b.metadata(); meta != nil {
+		if info.Quantization == "" {
+			info.Quantization = meta.Quantization()
+		}
+		if info.ContextLength == 0 {
+			info.ContextLength = meta.ContextLength
+		}
+		if !known || m.Size == 0 {
+			if st, err := os.Stat(b.ggufPath); err == nil {
+				info.Size = formatBytes(st.Size())
 			}
 		}
+		if !known {
+			toolCalling = meta.SupportsTools()
+		}
 	}
 
-	// Unknown model
-	return &backend.ModelInfo{
-		Name:          b.modelName,
-		ContextLength: b.contextSize,
-		Capabilities:  []string{"chat"},
+	capabilities := []string{backend.CapabilityChat}
+	if toolCalling {
+		capabilities = append(capabilities, backend.CapabilityToolCalling)
 	}
+	info.Capabilities = b.capabilities(capabilities...)
+	return info
 }
 
-// EstimateTokens provides a rough token count estimate
-// Uses a simple heuristic: ~4 characters per token for English
+// capabilities adds embeddings to base once Embed has succeeded; whether
+// llama-server serves them depends on how it was started. Callers hold mu.
+func (b *Backend) capabilities(base ...string) []string {
+	if b.embeddings != nil && *b.embeddings {
+		base = append(base, backend.CapabilityEmbeddings)
+	}
+	return base
+}
+
+// EstimateTokens counts tokens with the running llama-server's tokenizer.
+// Falls back to a ~4 characters per token heuristic when no server is up;
+// it never starts one just to count. Results are cached per text, so
+// repeated calls for the same content cost one /tokenize round trip.
 func (b *Backend) EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	b.tokenCountsMu.Lock()
+	n, ok := b.tokenCounts[text]
+	b.tokenCountsMu.Unlock()
+	if ok {
+		return n
+	}
+
+	if baseURL := b.runningServerURL(); baseURL != "" {
+		n, err := b.tokenize(context.Background(), baseURL, text)
+		if err == nil {
+			b.tokenCountsMu.Lock()
+			if len(b.tokenCounts) >= maxCachedTokenCounts {
+				b.tokenCounts = make(map[string]int)
+			}
+			b.tokenCounts[text] = n
+			b.tokenCountsMu.Unlock()
+			return n
+		}
+	}
+
 	// Rough estimate: average of 4 chars per token
 	return len(text) / 4
 }
+
+// runningServerURL returns the URL of an already running llama-server, if any
+func (b *Backend) runningServerURL() string {
+	if b.serverURL != "" {
+		return b.serverURL
+	}
+
+	b.mu.Lock()
+	modelPath := b.modelPath
+	b.mu.Unlock()
+	if server, ok := defaultPool.known(modelPath); ok && modelPath != "" {
+		return fmt.Sprintf("http://127.0.0.1:%d", server.Port())
+	}
+	return ""
+}
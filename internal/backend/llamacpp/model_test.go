@@ -0,0 +1,54 @@
+package llamacpp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+func TestRender_ToolCallTurn(t *testing.T) {
+	b := New(t.TempDir())
+
+	// Raw model output as returned by CompleteWithTools
+	content := `Let me check.
+<tool_call>{"name": "read_file", "parameters": {"path": "go.mod"}}</tool_call>`
+	calls := b.parseToolCalls(content)
+	require.Len(t, calls, 1)
+
+	prompt := ChatML.Render("", []backend.Message{
+		{Role: backend.RoleUser, Content: "read go.mod"},
+		{Role: backend.RoleAssistant, Content: content, ToolCalls: calls},
+		{Role: backend.RoleTool, Content: "module x", ToolName: "read_file"},
+	})
+
+	assert.Equal(t, 1, strings.Count(prompt, "<tool_call>"), "tool call must not be rendered twice")
+	assert.Contains(t, prompt, "<tool_response>\nmodule x\n</tool_response>")
+}
+
+func TestRender_StructuredToolCalls(t *testing.T) {
+	prompt := ChatML.Render("", []backend.Message{
+		{Role: backend.RoleAssistant, ToolCalls: []backend.ToolCall{
+			{Name: "read_file", Parameters: map[string]interface{}{"path": "a.go"}},
+		}},
+	})
+
+	assert.Equal(t,
+		"<|im_start|>assistant\n\n<tool_call>{\"name\":\"read_file\",\"parameters\":{\"path\":\"a.go\"}}</tool_call><|im_end|>\n<|im_start|>assistant\n",
+		prompt)
+}
+
+func TestBuildToolPrompt_ChoiceNone(t *testing.T) {
+	req := &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "hi"},
+		Tools:             []backend.ToolDefinition{{Name: "read_file", Description: "Read a file"}},
+	}
+
+	assert.Contains(t, buildToolPrompt(ChatML, req), "### read_file")
+
+	req.ToolChoice = "none"
+	assert.NotContains(t, buildToolPrompt(ChatML, req), "read_file")
+}
@@ -0,0 +1,461 @@
+package llamacpp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPort is where the first llama-server listens
+const defaultPort = 8089
+
+// memoryOverhead is added to a model's file size when estimating the
+// memory its server needs, for the KV cache and compute buffers
+const memoryOverhead = 0.25
+
+// PoolConfig bounds the llama-servers a Pool keeps running
+type PoolConfig struct {
+	BasePort    int   // Servers listen on BasePort up to BasePort+MaxServers-1
+	MaxServers  int   // Models resident at once
+	MemoryLimit int64 // Bytes for all resident models; 0 = available RAM
+}
+
+// DefaultPoolConfig returns the default pool configuration
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{BasePort: defaultPort, MaxServers: 2}
+}
+
+// Pool keeps a llama-server per model running so requests for different
+// models do not restart each other's server. Each server has its own
+// port. When a new model does not fit, by count or by estimated memory,
+// the least recently used idle server is stopped.
+//
+// Servers are found by probing the pool's ports, so servers started by
+// other scmd processes, or by a supervisor, are shared. Supervised
+// servers are never evicted.
+type Pool struct {
+	mu      sync.Mutex
+	config  PoolConfig
+	servers map[int]*pooledServer // by port
+
+	// Replaced in tests
+	launch     func(*ServerConfig) (*Server, error)
+	probe      func(port int) (modelPath string, running bool)
+	busy       func(port int) bool
+	stopPort   func(port int)
+	supervisor func() (*ServerState, bool)
+	memory     func() int64
+	modelSize  func(path string) int64
+	lastActive func(port int) time.Time
+}
+
+// pooledServer is a running server and the requests using it
+type pooledServer struct {
+	server *Server
+	inUse  int
+}
+
+var defaultPool = NewPool(DefaultPoolConfig())
+
+// NewPool creates a pool of llama-servers
+func NewPool(config PoolConfig) *Pool {
+	if config.BasePort == 0 {
+		config.BasePort = defaultPort
+	}
+	if config.MaxServers < 1 {
+		config.MaxServers = 1
+	}
+	return &Pool{
+		config:     config,
+		servers:    make(map[int]*pooledServer),
+		launch:     launchServer,
+		probe:      probeModel,
+		busy:       slotsProcessing,
+		stopPort:   stopServerOnPort,
+		supervisor: ReadSupervisorState,
+		memory:     availableMemory,
+		modelSize:  estimateServerMemory,
+		lastActive: lastActive,
+	}
+}
+
+// SetMaxServers sets how many models stay resident at once (server.max_models)
+func SetMaxServers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	defaultPool.mu.Lock()
+	defaultPool.config.MaxServers = n
+	defaultPool.mu.Unlock()
+}
+
+// Acquire returns a running server for config.ModelPath, starting one on
+// a free port if needed; config.Port is ignored. Call release when the
+// request is done, so the server may be evicted again.
+func (p *Pool) Acquire(config *ServerConfig) (*Server, func(), error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	debug := os.Getenv("SCMD_DEBUG") != ""
+	sup, supervised := p.supervisor()
+	p.scan(config.ModelPath)
+
+	if entry := p.find(config.ModelPath); entry != nil {
+		if debug {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Using llama-server on port %d for %s\n", entry.server.port, modelName(config.ModelPath))
+		}
+		return p.use(entry)
+	}
+
+	// A supervisor that stopped its idle server starts it again on request
+	if supervised && sup.ModelPath == config.ModelPath && p.inRange(sup.Port) {
+		if woken, err := wakeSupervisor(sup.Port, 2*time.Minute); woken {
+			if err != nil {
+				return nil, nil, err
+			}
+			if debug {
+				fmt.Fprintf(os.Stderr, "[DEBUG] Woke supervised llama-server on port %d\n", sup.Port)
+			}
+			entry := &pooledServer{server: &Server{port: sup.Port, modelPath: sup.ModelPath, ready: true}}
+			p.servers[sup.Port] = entry
+			return p.use(entry)
+		}
+	}
+
+	if err := p.makeRoom(config.ModelPath); err != nil {
+		return nil, nil, err
+	}
+
+	port := p.freePort()
+	if port == 0 {
+		return nil, nil, fmt.Errorf("no free llama-server port in %d-%d", p.config.BasePort, p.lastPort())
+	}
+	config.Port = port
+	if debug {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Starting llama-server for %s on port %d\n", modelName(config.ModelPath), port)
+	}
+
+	server, err := p.launch(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := &pooledServer{server: server}
+	p.servers[port] = entry
+	return p.use(entry)
+}
+
+// Resident returns the running server for modelPath without starting one
+func (p *Pool) Resident(modelPath string) (*Server, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.scan(modelPath)
+	if entry := p.find(modelPath); entry != nil {
+		return entry.server, true
+	}
+	return nil, false
+}
+
+// known returns the server for modelPath as last seen, without probing
+func (p *Pool) known(modelPath string) (*Server, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry := p.find(modelPath); entry != nil {
+		return entry.server, true
+	}
+	return nil, false
+}
+
+// StopAll stops the servers this process started and forgets the rest
+func (p *Pool) StopAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for port, entry := range p.servers {
+		if entry.server.cmd != nil {
+			entry.server.Stop()
+		}
+		delete(p.servers, port)
+	}
+}
+
+// Ports returns the ports the pool may use
+func (p *Pool) Ports() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ports := make([]int, 0, p.config.MaxServers)
+	for port := p.config.BasePort; port <= p.lastPort(); port++ {
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// ServerPorts returns the ports llama-servers may run on
+func ServerPorts() []int {
+	return defaultPool.Ports()
+}
+
+// scan brings p.servers in line with what is running on the pool's
+// ports. A server that does not report its model is taken to serve
+// wantPath on the base port, where earlier releases ran their only
+// server. Caller must hold p.mu.
+func (p *Pool) scan(wantPath string) {
+	for port := p.config.BasePort; port <= p.lastPort(); port++ {
+		path, running := p.probe(port)
+		entry := p.servers[port]
+
+		if !running {
+			if entry != nil {
+				// Crashed or stopped by another process
+				if entry.server.cmd != nil {
+					entry.server.Stop()
+				}
+				delete(p.servers, port)
+			}
+			continue
+		}
+
+		if path == "" {
+			switch {
+			case entry != nil:
+				path = entry.server.modelPath
+			case port == p.config.BasePort:
+				path = wantPath
+			}
+		}
+		if entry != nil && entry.server.modelPath == path {
+			continue
+		}
+
+		// Started, or replaced with another model, by another process
+		if entry != nil && entry.server.cmd != nil {
+			entry.server.Stop()
+		}
+		p.servers[port] = &pooledServer{server: &Server{port: port, modelPath: path, ready: true}}
+	}
+}
+
+// find returns the entry serving modelPath. Caller must hold p.mu.
+func (p *Pool) find(modelPath string) *pooledServer {
+	for _, entry := range p.servers {
+		if entry.server.modelPath == modelPath {
+			return entry
+		}
+	}
+	return nil
+}
+
+// use counts a request against entry. Caller must hold p.mu.
+func (p *Pool) use(entry *pooledServer) (*Server, func(), error) {
+	entry.inUse++
+	markActive(entry.server.port)
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			p.mu.Lock()
+			entry.inUse--
+			p.mu.Unlock()
+			markActive(entry.server.port)
+		})
+	}
+	return entry.server, release, nil
+}
+
+// makeRoom evicts idle servers until one more for modelPath fits. Running
+// over the memory estimate is allowed when nothing can be evicted; the
+// estimate is rough and the model may still fit. Caller must hold p.mu.
+func (p *Pool) makeRoom(modelPath string) error {
+	debug := os.Getenv("SCMD_DEBUG") != ""
+	need := p.modelSize(modelPath)
+	limit := p.config.MemoryLimit
+	if limit == 0 {
+		limit = p.memory()
+	}
+	sup, supervised := p.supervisor()
+
+	for {
+		count := len(p.servers)
+		var used int64
+		for _, entry := range p.servers {
+			used += p.modelSize(entry.server.modelPath)
+		}
+		// An idle supervised server keeps its port
+		if supervised && p.inRange(sup.Port) && p.servers[sup.Port] == nil {
+			count++
+			used += p.modelSize(sup.ModelPath)
+		}
+
+		full := count >= p.config.MaxServers
+		short := limit > 0 && count > 0 && used+need > limit
+		if !full && !short {
+			return nil
+		}
+
+		port := p.victim()
+		if port == 0 {
+			if full {
+				return fmt.Errorf("all %d llama-servers are busy; wait or raise server.max_models", p.config.MaxServers)
+			}
+			if debug {
+				fmt.Fprintf(os.Stderr, "[DEBUG] %s may not fit in memory (%s needed, %s in use, %s limit)\n",
+					modelName(modelPath), FormatBytes(need), FormatBytes(used), FormatBytes(limit))
+			}
+			return nil
+		}
+
+		entry := p.servers[port]
+		if debug {
+			reason := "server limit reached"
+			if !full {
+				reason = "low memory"
+			}
+			fmt.Fprintf(os.Stderr, "[DEBUG] Stopping llama-server for %s on port %d (%s)\n",
+				modelName(entry.server.modelPath), port, reason)
+		}
+		if entry.server.cmd != nil {
+			entry.server.Stop()
+		} else {
+			p.stopPort(port)
+		}
+		delete(p.servers, port)
+	}
+}
+
+// victim returns the port of the least recently used server that may be
+// evicted, or 0 if none may. Caller must hold p.mu.
+func (p *Pool) victim() int {
+	sup, supervised := p.supervisor()
+
+	var best int
+	var bestTime time.Time
+	for port, entry := range p.servers {
+		if entry.inUse > 0 || (supervised && sup.Port == port) {
+			continue
+		}
+		last := p.lastActive(port)
+		if best != 0 && !last.Before(bestTime) {
+			continue
+		}
+		// Requests from other processes show up in the slots
+		if p.busy(port) {
+			continue
+		}
+		best, bestTime = port, last
+	}
+	return best
+}
+
+// freePort returns the first pool port without a server, or 0. Caller
+// must hold p.mu.
+func (p *Pool) freePort() int {
+	sup, supervised := p.supervisor()
+	for port := p.config.BasePort; port <= p.lastPort(); port++ {
+		if p.servers[port] == nil && !(supervised && sup.Port == port) {
+			return port
+		}
+	}
+	return 0
+}
+
+func (p *Pool) lastPort() int {
+	return p.config.BasePort + p.config.MaxServers - 1
+}
+
+func (p *Pool) inRange(port int) bool {
+	return port >= p.config.BasePort && port <= p.lastPort()
+}
+
+// serverFileName names a per-port llama-server file. The default port
+// keeps the names earlier releases used.
+func serverFileName(port int, ext string) string {
+	if port == defaultPort {
+		return "llama-server" + ext
+	}
+	return fmt.Sprintf("llama-server-%d%s", port, ext)
+}
+
+// pidFilePath is where the PID of the llama-server on port is recorded
+func pidFilePath(dataDir string, port int) string {
+	return filepath.Join(dataDir, serverFileName(port, ".pid"))
+}
+
+// probeModel reports whether a llama-server runs on port and which model
+// it loaded, if it says
+func probeModel(port int) (string, bool) {
+	if path := serverModelPath(port); path != "" {
+		return path, true
+	}
+	return "", IsServerRunning(port)
+}
+
+// slotsProcessing reports whether the server on port is generating
+func slotsProcessing(port int) bool {
+	slots, err := GetSlots(port)
+	if err != nil {
+		return false
+	}
+	for _, slot := range slots {
+		if slot.IsProcessing {
+			return true
+		}
+	}
+	return false
+}
+
+// stopServerOnPort stops a llama-server started by another process,
+// through its PID file, and waits for the port to close
+func stopServerOnPort(port int) {
+	stopPIDFile(pidFilePath(getDataDir(), port))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for IsServerRunning(port) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// stopPIDFile signals the process recorded in pidPath and removes the file
+func stopPIDFile(pidPath string) {
+	data, err := os.ReadFile(pidPath)
+	if err != nil {
+		return
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && processAlive(pid) {
+		signalStop(pid)
+	}
+	os.Remove(pidPath)
+}
+
+// lastActive is when a request last used the server on port
+func lastActive(port int) time.Time {
+	info, err := os.Stat(activityFilePath(getDataDir(), port))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// availableMemory is the RAM llama-servers may use, or 0 if unknown
+func availableMemory() int64 {
+	resources, err := DetectSystemResources()
+	if err != nil {
+		return 0
+	}
+	return resources.AvailableRAMBytes
+}
+
+// estimateServerMemory guesses the memory a server for modelPath needs
+// from the model's file size
+func estimateServerMemory(modelPath string) int64 {
+	info, err := os.Stat(modelPath)
+	if err != nil {
+		return 0
+	}
+	return info.Size() + int64(float64(info.Size())*memoryOverhead)
+}
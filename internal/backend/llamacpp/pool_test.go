@@ -0,0 +1,164 @@
+package llamacpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePool returns a pool whose servers are simulated
+func fakePool(t *testing.T, config PoolConfig) (*Pool, *fakeServers) {
+	t.Helper()
+	t.Setenv("SCMD_DATA_DIR", t.TempDir())
+
+	fs := &fakeServers{models: make(map[int]string), busy: make(map[int]bool), active: make(map[int]time.Time)}
+	p := NewPool(config)
+	p.launch = func(c *ServerConfig) (*Server, error) {
+		fs.models[c.Port] = c.ModelPath
+		fs.launched = append(fs.launched, c.Port)
+		return &Server{port: c.Port, modelPath: c.ModelPath, ready: true}, nil
+	}
+	p.probe = func(port int) (string, bool) {
+		path, ok := fs.models[port]
+		return path, ok
+	}
+	p.busy = func(port int) bool { return fs.busy[port] }
+	p.stopPort = func(port int) {
+		delete(fs.models, port)
+		fs.stopped = append(fs.stopped, port)
+	}
+	p.supervisor = func() (*ServerState, bool) { return fs.supervised, fs.supervised != nil }
+	p.memory = func() int64 { return fs.memory }
+	p.modelSize = func(string) int64 { return 4 << 30 }
+	p.lastActive = func(port int) time.Time { return fs.active[port] }
+	return p, fs
+}
+
+type fakeServers struct {
+	models     map[int]string // running servers by port
+	busy       map[int]bool
+	active     map[int]time.Time
+	supervised *ServerState
+	memory     int64
+	launched   []int
+	stopped    []int
+}
+
+func acquire(t *testing.T, p *Pool, modelPath string) (*Server, func()) {
+	t.Helper()
+	server, release, err := p.Acquire(&ServerConfig{ModelPath: modelPath})
+	require.NoError(t, err)
+	return server, release
+}
+
+func TestPool_KeepsModelsOnSeparatePorts(t *testing.T) {
+	p, fs := fakePool(t, PoolConfig{BasePort: 18089, MaxServers: 2})
+
+	a, release := acquire(t, p, "/models/a.gguf")
+	release()
+	b, release := acquire(t, p, "/models/b.gguf")
+	release()
+	assert.Equal(t, 18089, a.Port())
+	assert.Equal(t, 18090, b.Port())
+
+	// Switching back reuses the running server
+	again, release := acquire(t, p, "/models/a.gguf")
+	release()
+	assert.Equal(t, 18089, again.Port())
+	assert.Equal(t, []int{18089, 18090}, fs.launched)
+}
+
+func TestPool_EvictsLeastRecentlyUsed(t *testing.T) {
+	p, fs := fakePool(t, PoolConfig{BasePort: 18089, MaxServers: 2})
+	now := time.Now()
+
+	// Both servers run in another process; b was used more recently
+	fs.models[18089] = "/models/a.gguf"
+	fs.models[18090] = "/models/b.gguf"
+	fs.active[18089] = now.Add(-time.Hour)
+	fs.active[18090] = now.Add(-time.Minute)
+
+	c, release := acquire(t, p, "/models/c.gguf")
+	release()
+	assert.Equal(t, 18089, c.Port())
+	assert.Equal(t, []int{18089}, fs.stopped)
+	assert.Equal(t, "/models/b.gguf", fs.models[18090])
+}
+
+func TestPool_SkipsBusyServers(t *testing.T) {
+	p, fs := fakePool(t, PoolConfig{BasePort: 18089, MaxServers: 2})
+
+	_, releaseA := acquire(t, p, "/models/a.gguf")
+	_, release := acquire(t, p, "/models/b.gguf")
+	release()
+
+	// a has a request in flight here, b one from another process
+	fs.busy[18090] = true
+	_, _, err := p.Acquire(&ServerConfig{ModelPath: "/models/c.gguf"})
+	assert.ErrorContains(t, err, "busy")
+	assert.Empty(t, fs.stopped)
+
+	fs.busy[18090] = false
+	c, release := acquire(t, p, "/models/c.gguf")
+	release()
+	assert.Equal(t, 18090, c.Port())
+	releaseA()
+}
+
+func TestPool_EvictsWhenMemoryIsShort(t *testing.T) {
+	p, fs := fakePool(t, PoolConfig{BasePort: 18089, MaxServers: 3})
+	fs.memory = 10 << 30 // room for two 4GB models
+
+	_, release := acquire(t, p, "/models/a.gguf")
+	release()
+	_, release = acquire(t, p, "/models/b.gguf")
+	release()
+	assert.Empty(t, fs.stopped)
+	fs.active[18089] = time.Now().Add(-time.Hour)
+	fs.active[18090] = time.Now()
+
+	// A third server is allowed by count but not by memory
+	c, release := acquire(t, p, "/models/c.gguf")
+	release()
+	assert.Equal(t, []int{18089}, fs.stopped)
+	assert.Equal(t, 18089, c.Port())
+	assert.Len(t, p.servers, 2)
+}
+
+func TestPool_AdoptsUnlabelledServerOnBasePort(t *testing.T) {
+	p, fs := fakePool(t, PoolConfig{BasePort: 18089, MaxServers: 2})
+	fs.models[18089] = "" // an older llama-server without model_path in /props
+
+	server, release := acquire(t, p, "/models/a.gguf")
+	release()
+	assert.Equal(t, 18089, server.Port())
+	assert.Empty(t, fs.launched)
+}
+
+func TestPool_LeavesSupervisedServerAlone(t *testing.T) {
+	p, fs := fakePool(t, PoolConfig{BasePort: 18089, MaxServers: 2})
+	fs.supervised = &ServerState{Port: 18089, ModelPath: "/models/a.gguf"}
+	fs.models[18089] = "/models/a.gguf"
+
+	_, release := acquire(t, p, "/models/b.gguf")
+	release()
+
+	// Only b may make room for c
+	c, release := acquire(t, p, "/models/c.gguf")
+	release()
+	assert.Equal(t, 18090, c.Port())
+	assert.Equal(t, "/models/a.gguf", fs.models[18089])
+
+	// An idle supervised server keeps its port
+	delete(fs.models, 18089)
+	d, release := acquire(t, p, "/models/d.gguf")
+	release()
+	assert.Equal(t, 18090, d.Port())
+}
+
+func TestServerFileName(t *testing.T) {
+	assert.Equal(t, "llama-server.pid", serverFileName(defaultPort, ".pid"))
+	assert.Equal(t, "llama-server-8090.log", serverFileName(8090, ".log"))
+}
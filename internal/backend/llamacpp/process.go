@@ -0,0 +1,57 @@
+//go:build !windows
+// +build !windows
+
+package llamacpp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processAlive reports whether a process with pid exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// signalStop asks the process with pid to shut down gracefully
+func signalStop(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// processRSS returns the peak resident set size of pid in bytes where the
+// kernel tracks it (Linux), else its current size from ps
+func processRSS(pid int) (int64, error) {
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid)); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if rest, ok := strings.CutPrefix(scanner.Text(), "VmHWM:"); ok {
+				kb, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "kB")), 10, 64)
+				if err != nil {
+					return 0, fmt.Errorf("parse VmHWM: %w", err)
+				}
+				return kb * 1024, nil
+			}
+		}
+	}
+
+	out, err := exec.Command("ps", "-o", "rss=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ps: %w", err)
+	}
+	kb, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse ps output: %w", err)
+	}
+	return kb * 1024, nil
+}
@@ -104,6 +104,10 @@ func detectGPU() (bool, string) {
 	}
 }
 
+// systemReserveBytes is the memory left to the rest of the system when
+// sizing a model
+const systemReserveBytes = 2 * 1024 * 1024 * 1024 // 2GB
+
 // CalculateOptimalConfig calculates optimal server configuration based on resources
 // Note: This function NO LONGER sets ContextSize - that's handled by the backend
 // based on model metadata. This only calculates GPU layers.
@@ -116,7 +120,6 @@ func CalculateOptimalConfig(res *SystemResources, modelSizeBytes int64) *ServerC
 	debug := os.Getenv("SCMD_DEBUG") != ""
 
 	// Calculate available memory for inference (total - 2GB for system)
-	systemReserveBytes := int64(2 * 1024 * 1024 * 1024) // 2GB
 	availableForModel := res.AvailableRAMBytes - systemReserveBytes
 
 	if debug {
@@ -174,6 +177,28 @@ func CalculateOptimalConfig(res *SystemResources, modelSizeBytes int64) *ServerC
 	return config
 }
 
+// offloadedThreads is the generation thread count for a model that runs
+// entirely on the GPU, where more CPU threads only add contention
+const offloadedThreads = 4
+
+// TuneForModel fills in settings that need the model's own metadata:
+// the native context length when ContextSize is unset, and fewer
+// generation threads when every layer fits on the GPU. Values already
+// set are kept.
+func (c *ServerConfig) TuneForModel(meta *GGUFMetadata) {
+	if c.ContextSize == 0 && meta.ContextLength > 0 {
+		c.ContextSize = meta.ContextLength
+	}
+
+	// llama.cpp offloads the output layer too, hence LayerCount+1
+	if c.Threads == 0 && meta.LayerCount > 0 && c.GPULayers >= meta.LayerCount+1 {
+		c.Threads = offloadedThreads
+		if n := runtime.NumCPU(); n < c.Threads {
+			c.Threads = n
+		}
+	}
+}
+
 // roundDownToPowerOf2 rounds down to the nearest power of 2
 func roundDownToPowerOf2(n int) int {
 	if n <= 0 {
@@ -0,0 +1,586 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supervisor states recorded in ServerState.State
+const (
+	StateStarting   = "starting"
+	StateRunning    = "running"
+	StateIdle       = "idle" // Stopped after the idle timeout; restarted on the next request
+	StateRestarting = "restarting"
+)
+
+// SupervisorConfig controls a supervised llama-server
+type SupervisorConfig struct {
+	HealthInterval  time.Duration // Between /health checks
+	IdleTimeout     time.Duration // Stop the server after this long without requests; 0 = never
+	MaxRestarts     int           // Consecutive crashes before giving up; 0 = unlimited
+	RestartDelay    time.Duration // Wait before the first restart, doubled per consecutive crash
+	MaxRestartDelay time.Duration // Cap on the restart wait
+	LogMaxBytes     int64         // Rotate llama-server.log past this size; 0 = never
+	LogBackups      int           // Rotated logs to keep
+	Log             io.Writer     // Supervisor events; default os.Stderr
+}
+
+// DefaultSupervisorConfig returns the supervisor defaults
+func DefaultSupervisorConfig() SupervisorConfig {
+	return SupervisorConfig{
+		HealthInterval:  10 * time.Second,
+		IdleTimeout:     30 * time.Minute,
+		MaxRestarts:     5,
+		RestartDelay:    time.Second,
+		MaxRestartDelay: time.Minute,
+		LogMaxBytes:     10 << 20,
+		LogBackups:      3,
+	}
+}
+
+// ServerState is what a running supervisor reports in
+// llama-server.state.json, for scmd server status and other scmd processes
+type ServerState struct {
+	SupervisorPID int           `json:"supervisor_pid"`
+	PID           int           `json:"pid,omitempty"` // 0 while the server is stopped
+	Port          int           `json:"port"`
+	ModelPath     string        `json:"model_path"`
+	State         string        `json:"state"`
+	StartedAt     time.Time     `json:"started_at"` // Start of the current server process
+	Restarts      int           `json:"restarts"`
+	LastActive    time.Time     `json:"last_active"`
+	IdleTimeout   time.Duration `json:"idle_timeout"`
+}
+
+// failedChecksBeforeRestart is how many /health checks in a row must fail
+// before a live-looking server is treated as crashed
+const failedChecksBeforeRestart = 3
+
+// wakePollInterval is how often an idle supervisor looks for new requests
+const wakePollInterval = 500 * time.Millisecond
+
+// Supervisor keeps one llama-server running: it restarts it with backoff
+// after crashes, rotates its log, and stops it when idle. Other scmd
+// processes find it through the state file and wake it by marking the
+// server active.
+type Supervisor struct {
+	config  *ServerConfig
+	opts    SupervisorConfig
+	dataDir string
+
+	server       *Server
+	state        ServerState
+	failedChecks int         // Consecutive failed health checks
+	crashes      int         // Consecutive crashes, reset once the server stays up
+	slotTasks    map[int]int // Last seen task ID per slot, to notice requests
+
+	// Overridden in tests
+	start   func(*ServerConfig) (*Server, error)
+	stop    func(*Server)
+	healthy func(port int) bool
+	slots   func(port int) ([]Slot, error)
+	now     func() time.Time
+}
+
+// NewSupervisor returns a supervisor for the server described by config
+func NewSupervisor(config *ServerConfig, opts SupervisorConfig) *Supervisor {
+	if opts.Log == nil {
+		opts.Log = os.Stderr
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = DefaultSupervisorConfig().HealthInterval
+	}
+	return &Supervisor{
+		config:  config,
+		opts:    opts,
+		dataDir: getDataDir(),
+		state: ServerState{
+			SupervisorPID: os.Getpid(),
+			Port:          config.Port,
+			ModelPath:     config.ModelPath,
+			IdleTimeout:   opts.IdleTimeout,
+		},
+		start:   launchServer,
+		stop:    func(s *Server) { s.Stop() },
+		healthy: func(port int) bool { return probeHealth(port, 2*time.Second) },
+		slots:   GetSlots,
+		now:     time.Now,
+	}
+}
+
+// Run starts the server and supervises it until ctx is cancelled or it
+// crashes more than MaxRestarts times in a row. The server is stopped and
+// the state file removed on return.
+func (s *Supervisor) Run(ctx context.Context) error {
+	if IsServerRunning(s.config.Port) {
+		return fmt.Errorf("llama-server is already running on port %d; stop it first with: scmd server stop", s.config.Port)
+	}
+
+	defer func() {
+		s.stopServer()
+		os.Remove(stateFilePath(s.dataDir))
+	}()
+
+	if err := s.startServer(); err != nil {
+		return err
+	}
+
+	for {
+		delay := s.opts.HealthInterval
+		if s.state.State == StateIdle {
+			delay = wakePollInterval
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		if err := s.tick(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// tick runs one supervision step
+func (s *Supervisor) tick(ctx context.Context) error {
+	if s.state.State == StateIdle {
+		if s.activeSince(s.state.LastActive) {
+			s.logf("request received, starting llama-server")
+			return s.startServer()
+		}
+		return nil
+	}
+
+	if !s.healthy(s.config.Port) {
+		s.failedChecks++
+		if s.failedChecks < failedChecksBeforeRestart && s.serverAlive() {
+			return nil
+		}
+		return s.restart(ctx)
+	}
+	s.failedChecks = 0
+
+	now := s.now()
+	if s.crashes > 0 && now.Sub(s.state.StartedAt) > s.opts.MaxRestartDelay {
+		s.crashes = 0
+	}
+
+	if s.activeSince(s.state.LastActive) || s.slotsBusy() {
+		s.state.LastActive = now
+	}
+	if s.opts.IdleTimeout > 0 && now.Sub(s.state.LastActive) >= s.opts.IdleTimeout {
+		s.logf("idle for %s, stopping llama-server", s.opts.IdleTimeout)
+		s.stopServer()
+		s.state.State = StateIdle
+		s.state.PID = 0
+		s.state.LastActive = now
+	}
+
+	if s.opts.LogMaxBytes > 0 {
+		if err := rotateLog(serverLogPath(s.dataDir, s.config.Port), s.opts.LogMaxBytes, s.opts.LogBackups); err != nil {
+			s.logf("rotate log: %v", err)
+		}
+	}
+	s.writeState()
+	return nil
+}
+
+// restart replaces a crashed server, waiting longer after each crash in a row
+func (s *Supervisor) restart(ctx context.Context) error {
+	s.crashes++
+	if s.opts.MaxRestarts > 0 && s.crashes > s.opts.MaxRestarts {
+		return fmt.Errorf("llama-server crashed %d times in a row, giving up; see %s", s.crashes, serverLogPath(s.dataDir, s.config.Port))
+	}
+
+	s.stopServer()
+	s.state.State = StateRestarting
+	s.state.PID = 0
+	s.writeState()
+
+	delay := s.opts.RestartDelay
+	for i := 1; i < s.crashes && delay < s.opts.MaxRestartDelay; i++ {
+		delay *= 2
+	}
+	if s.opts.MaxRestartDelay > 0 && delay > s.opts.MaxRestartDelay {
+		delay = s.opts.MaxRestartDelay
+	}
+	s.logf("llama-server is not responding, restarting in %s", delay)
+
+	timer := time.NewTimer(delay)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return nil
+	case <-timer.C:
+	}
+
+	s.state.Restarts++
+	if err := s.startServer(); err != nil {
+		// Count a failed start as another crash and try again next tick
+		s.logf("restart failed: %v", err)
+		s.server = nil
+	}
+	return nil
+}
+
+// startServer starts llama-server and records it in the state file
+func (s *Supervisor) startServer() error {
+	s.state.State = StateStarting
+	s.writeState()
+
+	server, err := s.start(s.config)
+	if err != nil {
+		return err
+	}
+	s.server = server
+	s.failedChecks = 0
+	s.slotTasks = nil
+
+	now := s.now()
+	s.state.State = StateRunning
+	s.state.StartedAt = now
+	s.state.LastActive = now
+	s.state.PID = 0
+	if server.cmd != nil && server.cmd.Process != nil {
+		s.state.PID = server.cmd.Process.Pid
+	}
+	s.writeState()
+	return nil
+}
+
+// stopServer stops the supervised server, if any
+func (s *Supervisor) stopServer() {
+	if s.server != nil {
+		s.stop(s.server)
+		s.server = nil
+	}
+}
+
+// serverAlive reports whether the server process still exists
+func (s *Supervisor) serverAlive() bool {
+	return s.server != nil && (s.state.PID == 0 || processAlive(s.state.PID))
+}
+
+// activeSince reports whether an scmd process marked the server active
+// after t
+func (s *Supervisor) activeSince(t time.Time) bool {
+	info, err := os.Stat(activityFilePath(s.dataDir, s.config.Port))
+	return err == nil && info.ModTime().After(t)
+}
+
+// slotsBusy reports whether any slot is processing or has taken a new
+// task since the last check, which catches clients other than scmd
+func (s *Supervisor) slotsBusy() bool {
+	slots, err := s.slots(s.config.Port)
+	if err != nil {
+		return false
+	}
+
+	busy := false
+	tasks := make(map[int]int, len(slots))
+	for _, slot := range slots {
+		tasks[slot.ID] = slot.TaskID
+		if prev, ok := s.slotTasks[slot.ID]; slot.IsProcessing || (ok && prev != slot.TaskID) {
+			busy = true
+		}
+	}
+	s.slotTasks = tasks
+	return busy
+}
+
+// writeState saves the state file; failures only cost status reporting
+func (s *Supervisor) writeState() {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return
+	}
+	path := stateFilePath(s.dataDir)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		os.Rename(tmp, path)
+	}
+}
+
+// logf prints a timestamped supervisor event
+func (s *Supervisor) logf(format string, args ...interface{}) {
+	fmt.Fprintf(s.opts.Log, "%s %s\n", s.now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// stateFilePath is where a supervisor publishes its ServerState
+func stateFilePath(dataDir string) string {
+	return filepath.Join(dataDir, "llama-server.state.json")
+}
+
+// activityFilePath is touched on every request to the server on port so a
+// supervisor or server pool in another process can tell it is in use
+func activityFilePath(dataDir string, port int) string {
+	return filepath.Join(dataDir, serverFileName(port, ".active"))
+}
+
+// serverLogPath is the log file of the llama-server on port
+func serverLogPath(dataDir string, port int) string {
+	return filepath.Join(dataDir, "logs", serverFileName(port, ".log"))
+}
+
+// markActive records a request to the llama-server on port for the
+// supervisor's idle timer and the pool's eviction order
+func markActive(port int) {
+	path := activityFilePath(getDataDir(), port)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); errors.Is(err, os.ErrNotExist) {
+		if f, err := os.Create(path); err == nil {
+			f.Close()
+		}
+	}
+}
+
+// ReadSupervisorState returns the state of a running supervisor. It
+// reports false when none is running, including when a crashed supervisor
+// left its state file behind.
+func ReadSupervisorState() (*ServerState, bool) {
+	data, err := os.ReadFile(stateFilePath(getDataDir()))
+	if err != nil {
+		return nil, false
+	}
+	var st ServerState
+	if err := json.Unmarshal(data, &st); err != nil || !processAlive(st.SupervisorPID) {
+		return nil, false
+	}
+	return &st, true
+}
+
+// wakeSupervisor asks a running supervisor in another process to start
+// its server and waits for it to become healthy. It reports false when
+// there is no such supervisor on port.
+func wakeSupervisor(port int, timeout time.Duration) (bool, error) {
+	st, ok := ReadSupervisorState()
+	if !ok || st.SupervisorPID == os.Getpid() || st.Port != port {
+		return false, nil
+	}
+
+	markActive(port)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if IsServerRunning(port) {
+			return true, nil
+		}
+		time.Sleep(wakePollInterval)
+	}
+	return true, fmt.Errorf("supervised llama-server not ready after %v", timeout)
+}
+
+// StopSupervisor stops a running supervisor and, through it, its server.
+// It reports false when no supervisor is running.
+func StopSupervisor(timeout time.Duration) (bool, error) {
+	st, ok := ReadSupervisorState()
+	if !ok {
+		return false, nil
+	}
+	if err := signalStop(st.SupervisorPID); err != nil {
+		return true, fmt.Errorf("stop supervisor (PID %d): %w", st.SupervisorPID, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for processAlive(st.SupervisorPID) {
+		if time.Now().After(deadline) {
+			return true, fmt.Errorf("supervisor (PID %d) did not exit after %v", st.SupervisorPID, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true, nil
+}
+
+// rotateLog keeps path under maxBytes by copying it to path.1 (shifting
+// older copies up to path.<backups>) and truncating it. Copying rather
+// than renaming lets llama-server keep appending to its open file.
+func rotateLog(path string, maxBytes int64, backups int) error {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() < maxBytes {
+		return nil
+	}
+	if backups < 1 {
+		return os.Truncate(path, 0)
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", path, backups))
+	for i := backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path + ".1")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Truncate(path, 0)
+}
+
+// probeHealth checks /health with the given timeout
+func probeHealth(port int, timeout time.Duration) bool {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/health", port))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Slot is one llama-server processing slot, from /slots
+type Slot struct {
+	ID           int  `json:"id"`
+	TaskID       int  `json:"id_task"`
+	IsProcessing bool `json:"is_processing"`
+}
+
+// GetSlots reads llama-server's slots. Servers started with --no-slots
+// return an error.
+func GetSlots(port int) ([]Slot, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/slots", port))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("slots: HTTP %d", resp.StatusCode)
+	}
+
+	var slots []Slot
+	if err := json.NewDecoder(resp.Body).Decode(&slots); err != nil {
+		return nil, fmt.Errorf("slots: %w", err)
+	}
+	return slots, nil
+}
+
+// ServerStatus describes the llama-server on a port, for
+// scmd server status
+type ServerStatus struct {
+	Running       bool       `json:"running"`
+	Port          int        `json:"port"`
+	PID           int        `json:"pid,omitempty"`
+	Model         string     `json:"model,omitempty"`
+	UptimeSeconds int64      `json:"uptime_seconds,omitempty"`
+	Supervised    bool       `json:"supervised"`
+	SupervisorPID int        `json:"supervisor_pid,omitempty"`
+	State         string     `json:"state,omitempty"`
+	Restarts      int        `json:"restarts"`
+	IdleTimeout   string     `json:"idle_timeout,omitempty"`
+	LastActive    *time.Time `json:"last_active,omitempty"`
+	Slots         *SlotUsage `json:"slots,omitempty"`
+	LogBytes      int64      `json:"log_bytes"`
+
+	// Instances are the servers for other models in the pool, set by
+	// scmd server status for the first port
+	Instances []*ServerStatus `json:"instances,omitempty"`
+}
+
+// SlotUsage counts busy slots
+type SlotUsage struct {
+	Total int `json:"total"`
+	Busy  int `json:"busy"`
+}
+
+// GetServerStatus collects the status of the llama-server on port from
+// the supervisor state, the PID file and the server itself
+func GetServerStatus(port int) *ServerStatus {
+	dataDir := getDataDir()
+	status := &ServerStatus{Port: port, Running: IsServerRunning(port)}
+	now := time.Now()
+
+	if st, ok := ReadSupervisorState(); ok && st.Port == port {
+		status.Supervised = true
+		status.SupervisorPID = st.SupervisorPID
+		status.State = st.State
+		status.Restarts = st.Restarts
+		status.PID = st.PID
+		status.Model = modelName(st.ModelPath)
+		if st.IdleTimeout > 0 {
+			status.IdleTimeout = st.IdleTimeout.String()
+		}
+		if !st.LastActive.IsZero() {
+			last := st.LastActive
+			status.LastActive = &last
+		}
+		if status.Running && !st.StartedAt.IsZero() {
+			status.UptimeSeconds = int64(now.Sub(st.StartedAt).Seconds())
+		}
+	} else if status.Running {
+		pidPath := pidFilePath(dataDir, port)
+		if data, err := os.ReadFile(pidPath); err == nil {
+			status.PID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+			if info, err := os.Stat(pidPath); err == nil {
+				status.UptimeSeconds = int64(now.Sub(info.ModTime()).Seconds())
+			}
+		}
+	}
+
+	if status.Running {
+		if status.Model == "" {
+			status.Model = modelName(serverModelPath(port))
+		}
+		if slots, err := GetSlots(port); err == nil {
+			status.Slots = &SlotUsage{Total: len(slots)}
+			for _, slot := range slots {
+				if slot.IsProcessing {
+					status.Slots.Busy++
+				}
+			}
+		}
+	}
+
+	if info, err := os.Stat(serverLogPath(dataDir, port)); err == nil {
+		status.LogBytes = info.Size()
+	}
+	return status
+}
+
+// serverModelPath asks llama-server which model it loaded
+func serverModelPath(port int) string {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/props", port))
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var props struct {
+		ModelPath string `json:"model_path"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&props) != nil {
+		return ""
+	}
+	return props.ModelPath
+}
+
+// modelName shortens a model path to its file name without extension
+func modelName(path string) string {
+	if path == "" {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.Base(path), ".gguf")
+}
@@ -0,0 +1,213 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSupervisor returns a started supervisor whose server is simulated
+func fakeSupervisor(t *testing.T, opts SupervisorConfig) (*Supervisor, *fakeServer) {
+	t.Helper()
+	t.Setenv("SCMD_DATA_DIR", t.TempDir())
+
+	fs := &fakeServer{healthy: true, now: time.Now().Add(-time.Hour)}
+	opts.Log = io.Discard
+	s := NewSupervisor(&ServerConfig{ModelPath: "/models/qwen3-4b.gguf", Port: 18089}, opts)
+	s.start = func(*ServerConfig) (*Server, error) {
+		fs.starts++
+		return &Server{port: 18089, ready: true}, nil
+	}
+	s.stop = func(*Server) { fs.stops++ }
+	s.healthy = func(int) bool { return fs.healthy }
+	s.slots = func(int) ([]Slot, error) {
+		if fs.slots == nil {
+			return nil, fmt.Errorf("no slots")
+		}
+		return fs.slots, nil
+	}
+	s.now = func() time.Time { return fs.now }
+
+	require.NoError(t, s.startServer())
+	return s, fs
+}
+
+type fakeServer struct {
+	healthy bool
+	starts  int
+	stops   int
+	slots   []Slot
+	now     time.Time
+}
+
+func TestSupervisor_RestartsAfterCrash(t *testing.T) {
+	s, fs := fakeSupervisor(t, SupervisorConfig{RestartDelay: time.Millisecond, MaxRestarts: 2})
+	ctx := context.Background()
+
+	fs.healthy = false
+	for i := 1; i < failedChecksBeforeRestart; i++ {
+		require.NoError(t, s.tick(ctx))
+		assert.Equal(t, 1, fs.starts, "a single failed check is not a crash")
+	}
+	require.NoError(t, s.tick(ctx))
+	assert.Equal(t, 2, fs.starts)
+	assert.Equal(t, 1, fs.stops)
+	assert.Equal(t, 1, s.state.Restarts)
+	assert.Equal(t, StateRunning, s.state.State)
+
+	// Crashing more than MaxRestarts times in a row gives up
+	for i := 0; i < 2*failedChecksBeforeRestart-1; i++ {
+		require.NoError(t, s.tick(ctx))
+	}
+	assert.Equal(t, 3, fs.starts)
+	assert.Error(t, s.tick(ctx))
+}
+
+func TestSupervisor_IdleShutdownAndWake(t *testing.T) {
+	s, fs := fakeSupervisor(t, SupervisorConfig{IdleTimeout: time.Minute})
+	ctx := context.Background()
+
+	fs.now = fs.now.Add(30 * time.Second)
+	require.NoError(t, s.tick(ctx))
+	assert.Equal(t, StateRunning, s.state.State)
+
+	fs.now = fs.now.Add(time.Minute)
+	require.NoError(t, s.tick(ctx))
+	assert.Equal(t, StateIdle, s.state.State)
+	assert.Equal(t, 1, fs.stops)
+
+	// Nothing happens until a request marks the server active
+	require.NoError(t, s.tick(ctx))
+	assert.Equal(t, 1, fs.starts)
+
+	markActive(18089)
+	require.NoError(t, s.tick(ctx))
+	assert.Equal(t, 2, fs.starts)
+	assert.Equal(t, StateRunning, s.state.State)
+}
+
+func TestSupervisor_SlotActivityResetsIdleTimer(t *testing.T) {
+	s, fs := fakeSupervisor(t, SupervisorConfig{IdleTimeout: time.Minute})
+	ctx := context.Background()
+
+	fs.slots = []Slot{{ID: 0, TaskID: 7}, {ID: 1, TaskID: 3}}
+	require.NoError(t, s.tick(ctx))
+
+	// A slot took a new task between checks
+	fs.now = fs.now.Add(50 * time.Second)
+	fs.slots = []Slot{{ID: 0, TaskID: 9}, {ID: 1, TaskID: 3}}
+	require.NoError(t, s.tick(ctx))
+	assert.Equal(t, fs.now, s.state.LastActive)
+
+	fs.now = fs.now.Add(50 * time.Second)
+	require.NoError(t, s.tick(ctx))
+	assert.Equal(t, StateRunning, s.state.State)
+}
+
+func TestSupervisor_WritesState(t *testing.T) {
+	s, _ := fakeSupervisor(t, SupervisorConfig{IdleTimeout: time.Minute})
+
+	st, ok := ReadSupervisorState()
+	require.True(t, ok)
+	assert.Equal(t, os.Getpid(), st.SupervisorPID)
+	assert.Equal(t, StateRunning, st.State)
+	assert.Equal(t, "/models/qwen3-4b.gguf", st.ModelPath)
+
+	// A state file left by a dead supervisor is ignored
+	s.state.SupervisorPID = -1
+	s.writeState()
+	_, ok = ReadSupervisorState()
+	assert.False(t, ok)
+}
+
+func TestRotateLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llama-server.log")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	read := func(p string) string {
+		data, _ := os.ReadFile(p)
+		return string(data)
+	}
+
+	write("small")
+	require.NoError(t, rotateLog(path, 10, 2))
+	assert.Equal(t, "small", read(path), "under the limit")
+
+	write("first log line")
+	require.NoError(t, rotateLog(path, 10, 2))
+	assert.Equal(t, "", read(path))
+	assert.Equal(t, "first log line", read(path+".1"))
+
+	write("second log line")
+	require.NoError(t, rotateLog(path, 10, 2))
+	write("third log line")
+	require.NoError(t, rotateLog(path, 10, 2))
+	assert.Equal(t, "third log line", read(path+".1"))
+	assert.Equal(t, "second log line", read(path+".2"))
+	assert.NoFileExists(t, path+".3", "only two backups are kept")
+}
+
+func TestGetServerStatus(t *testing.T) {
+	t.Setenv("SCMD_DATA_DIR", t.TempDir())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case "/slots":
+			fmt.Fprint(w, `[{"id":0,"id_task":4,"is_processing":true},{"id":1,"id_task":-1,"is_processing":false}]`)
+		case "/props":
+			fmt.Fprint(w, `{"model_path":"/models/gemma-3-4b.gguf"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	status := GetServerStatus(port)
+	assert.True(t, status.Running)
+	assert.False(t, status.Supervised)
+	assert.Equal(t, "gemma-3-4b", status.Model)
+	assert.Equal(t, &SlotUsage{Total: 2, Busy: 1}, status.Slots)
+
+	state := ServerState{
+		SupervisorPID: os.Getpid(),
+		PID:           4321,
+		Port:          port,
+		ModelPath:     "/models/qwen3-4b.gguf",
+		State:         StateRunning,
+		StartedAt:     time.Now().Add(-90 * time.Second),
+		Restarts:      2,
+		IdleTimeout:   30 * time.Minute,
+	}
+	data, _ := json.Marshal(state)
+	require.NoError(t, os.WriteFile(stateFilePath(getDataDir()), data, 0644))
+
+	status = GetServerStatus(port)
+	assert.True(t, status.Supervised)
+	assert.Equal(t, 4321, status.PID)
+	assert.Equal(t, 2, status.Restarts)
+	assert.Equal(t, "qwen3-4b", status.Model)
+	assert.Equal(t, "30m0s", status.IdleTimeout)
+	assert.InDelta(t, 90, status.UptimeSeconds, 5)
+
+	out, err := json.Marshal(status)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(out), `"slots":{"total":2,"busy":1}`))
+}
@@ -3,6 +3,9 @@ package mock
 
 import (
 	"context"
+	"hash/fnv"
+	"math"
+	"strings"
 
 	"github.com/scmd/scmd/internal/backend"
 )
@@ -63,9 +66,10 @@ func (b *Backend) Complete(_ context.Context, _ *backend.CompletionRequest) (*ba
 		return nil, b.err
 	}
 	return &backend.CompletionResponse{
-		Content:      b.response,
-		TokensUsed:   len(b.response) / 4,
-		FinishReason: backend.FinishComplete,
+		Content:          b.response,
+		TokensUsed:       len(b.response) / 4,
+		CompletionTokens: len(b.response) / 4,
+		FinishReason:     backend.FinishComplete,
 	}, nil
 }
 
@@ -107,7 +111,7 @@ func (b *Backend) ModelInfo() *backend.ModelInfo {
 		Name:          "mock-model",
 		Size:          "0B",
 		ContextLength: 8192,
-		Capabilities:  []string{"text"},
+		Capabilities:  []string{backend.CapabilityText, backend.CapabilityEmbeddings},
 	}
 }
 
@@ -115,3 +119,37 @@ func (b *Backend) ModelInfo() *backend.ModelInfo {
 func (b *Backend) EstimateTokens(text string) int {
 	return len(text) / 4
 }
+
+// EmbeddingDims is the length of mock embedding vectors
+const EmbeddingDims = 64
+
+// Embed returns deterministic bag-of-words vectors, so texts sharing
+// words score as similar without a real model
+func (b *Backend) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, EmbeddingDims)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			v[h.Sum32()%EmbeddingDims]++
+		}
+
+		var norm float64
+		for _, x := range v {
+			norm += float64(x * x)
+		}
+		if norm > 0 {
+			scale := float32(1 / math.Sqrt(norm))
+			for j := range v {
+				v[j] *= scale
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
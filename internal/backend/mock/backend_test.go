@@ -121,3 +121,15 @@ func TestBackend_EstimateTokens(t *testing.T) {
 	tokens := b.EstimateTokens("hello world")
 	assert.Greater(t, tokens, 0)
 }
+
+func TestBackend_Embed(t *testing.T) {
+	b := New()
+	assert.True(t, b.ModelInfo().HasCapability(backend.CapabilityEmbeddings))
+
+	vectors, err := b.Embed(context.Background(), []string{"read the file", "Read the FILE", "deploy to prod"})
+	require.NoError(t, err)
+	require.Len(t, vectors, 3)
+	assert.Len(t, vectors[0], EmbeddingDims)
+	assert.Equal(t, vectors[0], vectors[1])
+	assert.NotEqual(t, vectors[0], vectors[2])
+}
@@ -6,9 +6,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/scmd/scmd/internal/backend"
@@ -16,9 +19,15 @@ import (
 
 // Backend implements the Ollama backend
 type Backend struct {
-	baseURL    string
-	model      string
-	httpClient *http.Client
+	baseURL        string
+	httpClient     *http.Client
+	embeddingModel string
+
+	// mu guards the active model and the tool support per model,
+	// discovered via /api/show
+	mu          sync.Mutex
+	model       string
+	toolSupport map[string]bool
 }
 
 // Config for Ollama backend
@@ -26,6 +35,9 @@ type Config struct {
 	BaseURL string // Default: http://localhost:11434
 	Model   string // Default: llama3.2 or qwen2.5-coder
 	Timeout time.Duration
+
+	// EmbeddingModel is used by Embed, e.g. nomic-embed-text. Default: Model
+	EmbeddingModel string
 }
 
 // DefaultConfig returns sensible defaults
@@ -56,8 +68,11 @@ func New(cfg *Config) *Backend {
 		baseURL: cfg.BaseURL,
 		model:   cfg.Model,
 		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
+			Timeout:   cfg.Timeout,
+			Transport: backend.NewRetryTransport(backend.DefaultRetryPolicy(), nil),
 		},
+		toolSupport:    make(map[string]bool),
+		embeddingModel: cfg.EmbeddingModel,
 	}
 }
 
@@ -105,22 +120,49 @@ func (b *Backend) Shutdown(_ context.Context) error {
 	return nil
 }
 
-// generateRequest is the Ollama generate API request
-type generateRequest struct {
-	Model   string         `json:"model"`
-	Prompt  string         `json:"prompt"`
-	System  string         `json:"system,omitempty"`
-	Stream  bool           `json:"stream"`
-	Options map[string]any `json:"options,omitempty"`
+// chatMessage is an Ollama chat API message
+type chatMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+// toolCall is a function call requested by an assistant message
+type toolCall struct {
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
 }
 
-// generateResponse is the Ollama generate API response
-type generateResponse struct {
-	Model     string `json:"model"`
-	Response  string `json:"response"`
-	Done      bool   `json:"done"`
-	Context   []int  `json:"context,omitempty"`
-	CreatedAt string `json:"created_at"`
+// chatTool is a function tool definition
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+// chatRequest is the Ollama chat API request
+type chatRequest struct {
+	Model    string         `json:"model"`
+	Messages []chatMessage  `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Tools    []chatTool     `json:"tools,omitempty"`
+	Format   any            `json:"format,omitempty"` // "json" or a JSON Schema
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+// chatResponse is the Ollama chat API response
+type chatResponse struct {
+	Model      string      `json:"model"`
+	Message    chatMessage `json:"message"`
+	Done       bool        `json:"done"`
+	DoneReason string      `json:"done_reason,omitempty"`
+	CreatedAt  string      `json:"created_at"`
 
 	// Timing info (only in final response)
 	TotalDuration      int64 `json:"total_duration,omitempty"`
@@ -131,83 +173,121 @@ type generateResponse struct {
 	EvalDuration       int64 `json:"eval_duration,omitempty"`
 }
 
-// Complete performs a non-streaming completion
-func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
-	ollamaReq := generateRequest{
-		Model:  b.model,
-		Prompt: req.Prompt,
-		System: req.SystemPrompt,
-		Stream: false,
-		Options: map[string]any{
-			"temperature": req.Temperature,
-		},
-	}
-
-	if req.MaxTokens > 0 {
-		ollamaReq.Options["num_predict"] = req.MaxTokens
+// usage returns the token counts of a final response
+func (r *chatResponse) usage() *backend.Usage {
+	return &backend.Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
 	}
+}
 
-	body, err := json.Marshal(ollamaReq)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
+// timing returns the timing information of a final response
+func (r *chatResponse) timing() *backend.Timing {
+	timing := &backend.Timing{
+		PromptMS:     r.PromptEvalDuration / 1_000_000,
+		CompletionMS: r.EvalDuration / 1_000_000,
 	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/api/generate", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+	if r.EvalDuration > 0 {
+		timing.TokensPerSec = float64(r.EvalCount) / (float64(r.EvalDuration) / 1e9)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
+	return timing
+}
 
-	resp, err := b.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("ollama request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// buildMessages converts a completion request into chat messages
+func buildMessages(req *backend.CompletionRequest) []chatMessage {
+	messages := []chatMessage{}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	if req.SystemPrompt != "" {
+		messages = append(messages, chatMessage{
+			Role:    "system",
+			Content: req.SystemPrompt,
+		})
 	}
 
-	var ollamaResp generateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+	for _, msg := range req.ConversationMessages() {
+		chatMsg := chatMessage{
+			Role:     string(msg.Role),
+			Content:  msg.Content,
+			ToolName: msg.ToolName,
+		}
+		for _, call := range msg.ToolCalls {
+			var tc toolCall
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Parameters
+			if tc.Function.Arguments == nil {
+				tc.Function.Arguments = map[string]any{}
+			}
+			chatMsg.ToolCalls = append(chatMsg.ToolCalls, tc)
+		}
+		messages = append(messages, chatMsg)
 	}
 
-	return &backend.CompletionResponse{
-		Content:      ollamaResp.Response,
-		TokensUsed:   ollamaResp.EvalCount + ollamaResp.PromptEvalCount,
-		FinishReason: backend.FinishComplete,
-		Timing: &backend.Timing{
-			PromptMS:     ollamaResp.PromptEvalDuration / 1_000_000,
-			CompletionMS: ollamaResp.EvalDuration / 1_000_000,
-			TokensPerSec: float64(ollamaResp.EvalCount) / (float64(ollamaResp.EvalDuration) / 1e9),
-		},
-	}, nil
+	return messages
 }
 
-// Stream performs a streaming completion
-func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
-	ollamaReq := generateRequest{
-		Model:  b.model,
-		Prompt: req.Prompt,
-		System: req.SystemPrompt,
-		Stream: true,
+// buildChatRequest converts a completion request into a chat API request
+func (b *Backend) buildChatRequest(req *backend.CompletionRequest, stream bool) chatRequest {
+	chatReq := chatRequest{
+		Model:    b.currentModel(),
+		Messages: buildMessages(req),
+		Stream:   stream,
 		Options: map[string]any{
 			"temperature": req.Temperature,
 		},
 	}
 
 	if req.MaxTokens > 0 {
-		ollamaReq.Options["num_predict"] = req.MaxTokens
+		chatReq.Options["num_predict"] = req.MaxTokens
+	}
+	if len(req.StopSequences) > 0 {
+		chatReq.Options["stop"] = req.StopSequences
+	}
+	setSamplingOptions(chatReq.Options, req.Sampling)
+	if f := req.ResponseFormat; f != nil {
+		if f.Type == backend.ResponseJSONSchema && f.Schema != nil {
+			chatReq.Format = f.Schema
+		} else {
+			chatReq.Format = "json"
+		}
 	}
 
-	body, err := json.Marshal(ollamaReq)
+	return chatReq
+}
+
+// setSamplingOptions copies the sampling controls Ollama supports into
+// options; logit bias has no equivalent and is dropped
+func setSamplingOptions(options map[string]any, s backend.Sampling) {
+	if s.TopP > 0 {
+		options["top_p"] = s.TopP
+	}
+	if s.TopK > 0 {
+		options["top_k"] = s.TopK
+	}
+	if s.MinP > 0 {
+		options["min_p"] = s.MinP
+	}
+	if s.Seed != nil {
+		options["seed"] = *s.Seed
+	}
+	if s.RepeatPenalty > 0 {
+		options["repeat_penalty"] = s.RepeatPenalty
+	}
+	if s.FrequencyPenalty != 0 {
+		options["frequency_penalty"] = s.FrequencyPenalty
+	}
+	if s.PresencePenalty != 0 {
+		options["presence_penalty"] = s.PresencePenalty
+	}
+}
+
+// postChat sends a chat API request and checks the status code
+func (b *Backend) postChat(ctx context.Context, chatReq chatRequest) (*http.Response, error) {
+	body, err := json.Marshal(chatReq)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/api/generate", bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/api/chat", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -224,55 +304,290 @@ func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<
 		return nil, fmt.Errorf("ollama error (status %d): %s", resp.StatusCode, string(bodyBytes))
 	}
 
+	return resp, nil
+}
+
+// Complete performs a non-streaming completion
+func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	resp, err := b.postChat(ctx, b.buildChatRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ollamaResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	finishReason := backend.FinishComplete
+	if ollamaResp.DoneReason == "length" {
+		finishReason = backend.FinishLength
+	}
+
+	return &backend.CompletionResponse{
+		Content:          ollamaResp.Message.Content,
+		TokensUsed:       ollamaResp.EvalCount + ollamaResp.PromptEvalCount,
+		PromptTokens:     ollamaResp.PromptEvalCount,
+		CompletionTokens: ollamaResp.EvalCount,
+		FinishReason:     finishReason,
+		Timing:           ollamaResp.timing(),
+	}, nil
+}
+
+// Stream performs a streaming completion
+func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	resp, err := b.postChat(ctx, b.buildChatRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+
 	ch := make(chan backend.StreamChunk)
 
 	go func() {
 		defer close(ch)
 		defer resp.Body.Close()
 
+		// Cancelling ctx closes the response body, which makes Ollama stop
+		// generating; the reader may be gone by then
+		send := func(chunk backend.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
-			var chunk generateResponse
+			var chunk chatResponse
 			if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
-				ch <- backend.StreamChunk{Error: err}
+				send(backend.StreamChunk{Error: err})
 				return
 			}
 
-			ch <- backend.StreamChunk{
-				Content: chunk.Response,
-				Done:    chunk.Done,
+			if chunk.Done {
+				send(backend.StreamChunk{
+					Content: chunk.Message.Content,
+					Timing:  chunk.timing(),
+					Usage:   chunk.usage(),
+					Done:    true,
+				})
+				return
 			}
 
-			if chunk.Done {
+			if !send(backend.StreamChunk{Content: chunk.Message.Content}) {
 				return
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			ch <- backend.StreamChunk{Error: err}
+			send(backend.StreamChunk{Error: err})
 		}
 	}()
 
 	return ch, nil
 }
 
-// SupportsToolCalling returns false (Ollama basic API doesn't support tools)
+// SupportsToolCalling reports whether the current model accepts tools,
+// based on the capabilities Ollama advertises for it in /api/show
 func (b *Backend) SupportsToolCalling() bool {
-	return false
+	b.mu.Lock()
+	model := b.model
+	supported, cached := b.toolSupport[model]
+	b.mu.Unlock()
+
+	if cached {
+		return supported
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	show, err := b.showModel(ctx, model)
+	switch {
+	case errors.Is(err, errModelNotFound):
+		// A model Ollama doesn't have can't call tools; cache that too
+		supported = false
+	case err != nil:
+		// Don't cache other failures; Ollama may simply not be running yet
+		return false
+	default:
+		supported = show.supportsTools()
+	}
+
+	b.mu.Lock()
+	b.toolSupport[model] = supported
+	b.mu.Unlock()
+
+	return supported
+}
+
+// CompleteWithTools performs completion with tool calling via /api/chat
+func (b *Backend) CompleteWithTools(ctx context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	chatReq := b.buildChatRequest(&req.CompletionRequest, false)
+
+	// /api/chat has no tool_choice field; "none" is honoured by
+	// leaving the tools out, other choices fall back to auto
+	tools := req.Tools
+	if req.ToolChoice == "none" {
+		tools = nil
+	}
+	for _, def := range tools {
+		t := chatTool{Type: "function"}
+		t.Function.Name = def.Name
+		t.Function.Description = def.Description
+		t.Function.Parameters = def.JSONSchema()
+		chatReq.Tools = append(chatReq.Tools, t)
+	}
+
+	resp, err := b.postChat(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ollamaResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	toolResp := &backend.ToolResponse{
+		Content: ollamaResp.Message.Content,
+		Usage:   ollamaResp.usage(),
+	}
+	for _, call := range ollamaResp.Message.ToolCalls {
+		params := call.Function.Arguments
+		if params == nil {
+			params = map[string]any{}
+		}
+		toolResp.ToolCalls = append(toolResp.ToolCalls, backend.ToolCall{
+			Name:       call.Function.Name,
+			Parameters: params,
+		})
+	}
+
+	return toolResp, nil
+}
+
+// showResponse is the subset of the /api/show response scmd uses
+type showResponse struct {
+	Template     string   `json:"template"`
+	Capabilities []string `json:"capabilities"`
 }
 
-// CompleteWithTools is not supported
-func (b *Backend) CompleteWithTools(_ context.Context, _ *backend.ToolRequest) (*backend.ToolResponse, error) {
-	return nil, fmt.Errorf("tool calling not supported by Ollama backend")
+// supportsTools reports whether the model advertises tool support.
+// Older Ollama versions have no capabilities list, so fall back to
+// checking whether the chat template renders tools.
+func (s *showResponse) supportsTools() bool {
+	if len(s.Capabilities) > 0 {
+		for _, c := range s.Capabilities {
+			if c == "tools" {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(s.Template, ".Tools")
+}
+
+// errModelNotFound is returned by showModel when Ollama doesn't have the model
+var errModelNotFound = errors.New("model not found")
+
+// showModel fetches model metadata from /api/show
+func (b *Backend) showModel(ctx context.Context, model string) (*showResponse, error) {
+	body, err := json.Marshal(map[string]string{"model": model})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/api/show", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errModelNotFound, model)
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var show showResponse
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return &show, nil
 }
 
 // ModelInfo returns model information
 func (b *Backend) ModelInfo() *backend.ModelInfo {
+	// /api/embed accepts any model, not just dedicated embedding models
+	capabilities := []string{backend.CapabilityText, backend.CapabilityCode, backend.CapabilityEmbeddings}
+
+	// Only report tool support once it has been discovered; ModelInfo
+	// must not block on the network
+	b.mu.Lock()
+	model := b.model
+	if b.toolSupport[model] {
+		capabilities = append(capabilities, backend.CapabilityToolCalling)
+	}
+	b.mu.Unlock()
+
 	return &backend.ModelInfo{
-		Name:          b.model,
+		Name:          model,
 		ContextLength: 8192, // Default, varies by model
-		Capabilities:  []string{"text", "code"},
+		Capabilities:  capabilities,
+	}
+}
+
+// Embed embeds texts with /api/embed
+func (b *Backend) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	model := b.embeddingModel
+	if model == "" {
+		model = b.currentModel()
+	}
+
+	body, err := json.Marshal(map[string]any{"model": model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var embedResp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
 	}
+
+	return embedResp.Embeddings, nil
 }
 
 // EstimateTokens estimates token count (rough approximation)
@@ -281,9 +596,23 @@ func (b *Backend) EstimateTokens(text string) int {
 	return len(text) / 4
 }
 
+// SetRetry sets how failed requests are retried and paced
+func (b *Backend) SetRetry(policy backend.RetryPolicy, limiter *backend.Limiter) {
+	b.httpClient.Transport = backend.NewRetryTransport(policy, limiter)
+}
+
 // SetModel changes the active model
 func (b *Backend) SetModel(model string) {
+	b.mu.Lock()
 	b.model = model
+	b.mu.Unlock()
+}
+
+// currentModel returns the active model
+func (b *Backend) currentModel() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.model
 }
 
 // ListModels returns available models from Ollama
@@ -1,9 +1,16 @@
 package ollama
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/scmd/scmd/internal/backend"
 )
@@ -51,6 +58,293 @@ func TestBackend_SetModel(t *testing.T) {
 }
 
 func TestBackend_SupportsToolCalling(t *testing.T) {
-	b := New(nil)
+	shows := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/show", r.URL.Path)
+		shows++
+
+		var req struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		switch req.Model {
+		case "qwen3":
+			fmt.Fprint(w, `{"capabilities":["completion","tools"]}`)
+		case "gemma":
+			fmt.Fprint(w, `{"capabilities":["completion"],"template":"{{ .Tools }}"}`)
+		case "legacy":
+			fmt.Fprint(w, `{"template":"{{- if .Tools }}tools{{ end }}"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, Model: "qwen3"})
+	assert.True(t, b.SupportsToolCalling())
+	assert.True(t, b.SupportsToolCalling())
+	assert.Equal(t, 1, shows, "capability lookups should be cached per model")
+	assert.Contains(t, b.ModelInfo().Capabilities, "tool_calling")
+
+	b.SetModel("gemma")
+	assert.False(t, b.SupportsToolCalling())
+	assert.NotContains(t, b.ModelInfo().Capabilities, "tool_calling")
+
+	b.SetModel("legacy")
+	assert.True(t, b.SupportsToolCalling())
+
+	b.SetModel("missing")
+	assert.False(t, b.SupportsToolCalling())
+	assert.False(t, b.SupportsToolCalling())
+	assert.Equal(t, 4, shows, "a 404 should be cached as unsupported")
+}
+
+func TestBackend_SetModel_Concurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"capabilities":["completion","tools"]}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, Model: "qwen3"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			b.SetModel("qwen3")
+		}()
+		go func() {
+			defer wg.Done()
+			b.SupportsToolCalling()
+			b.ModelInfo()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBackend_SupportsToolCalling_Unreachable(t *testing.T) {
+	b := New(&Config{BaseURL: "http://127.0.0.1:1", Model: "qwen3"})
 	assert.False(t, b.SupportsToolCalling())
 }
+
+func TestBackend_CompleteWithTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Len(t, req.Tools, 1)
+		assert.Equal(t, "function", req.Tools[0].Type)
+		assert.Equal(t, "read_file", req.Tools[0].Function.Name)
+		assert.Equal(t, "object", req.Tools[0].Function.Parameters["type"])
+
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"","tool_calls":[
+			{"function":{"name":"read_file","arguments":{"path":"a.go"}}},
+			{"function":{"name":"read_file","arguments":{"path":"b.go"}}}
+		]},"done":true}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, Model: "qwen3"})
+	resp, err := b.CompleteWithTools(context.Background(), &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "read both"},
+		Tools: []backend.ToolDefinition{{
+			Name: "read_file",
+			Parameters: map[string]backend.ToolParameter{
+				"path": {Type: "string", Required: true},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.ToolCalls, 2)
+	assert.Equal(t, "read_file", resp.ToolCalls[0].Name)
+	assert.Equal(t, "a.go", resp.ToolCalls[0].Parameters["path"])
+	assert.Equal(t, "b.go", resp.ToolCalls[1].Parameters["path"])
+}
+
+func TestBackend_CompleteWithTools_ChoiceNone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Empty(t, req.Tools)
+
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"done"},"done":true}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, Model: "qwen3"})
+	resp, err := b.CompleteWithTools(context.Background(), &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "answer"},
+		Tools:             []backend.ToolDefinition{{Name: "read_file"}},
+		ToolChoice:        "none",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "done", resp.Content)
+}
+
+func TestBuildMessages_NilArguments(t *testing.T) {
+	messages := buildMessages(&backend.CompletionRequest{
+		Messages: []backend.Message{
+			{Role: backend.RoleAssistant, ToolCalls: []backend.ToolCall{{Name: "list_files"}}},
+		},
+	})
+
+	require.Len(t, messages, 1)
+	require.Len(t, messages[0].ToolCalls, 1)
+
+	body, err := json.Marshal(messages[0].ToolCalls[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"function":{"name":"list_files","arguments":{}}}`, string(body))
+}
+
+func TestBackend_Complete_Messages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Len(t, req.Messages, 4)
+		assert.Equal(t, "system", req.Messages[0].Role)
+		assert.Equal(t, "user", req.Messages[1].Role)
+		assert.Equal(t, "assistant", req.Messages[2].Role)
+		assert.Equal(t, "user", req.Messages[3].Role)
+		assert.Equal(t, "and now?", req.Messages[3].Content)
+		assert.False(t, req.Stream)
+
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"hello"},"done":true,"prompt_eval_count":10,"eval_count":2,"eval_duration":1000000}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, Model: "test"})
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{
+		SystemPrompt: "be brief",
+		Messages: []backend.Message{
+			{Role: backend.RoleUser, Content: "hi"},
+			{Role: backend.RoleAssistant, Content: "hey"},
+			{Role: backend.RoleUser, Content: "and now?"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Content)
+	assert.Equal(t, 12, resp.TokensUsed)
+	assert.Equal(t, 10, resp.PromptTokens)
+	assert.Equal(t, 2, resp.CompletionTokens)
+}
+
+func TestBackend_Stream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":7,"eval_count":2}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, Model: "test"})
+	ch, err := b.Stream(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	var content string
+	var final backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		content += chunk.Content
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	assert.Equal(t, "Hello", content)
+	require.NotNil(t, final.Usage)
+	assert.Equal(t, 7, final.Usage.PromptTokens)
+	assert.Equal(t, 2, final.Usage.CompletionTokens)
+}
+
+func TestChatResponse_UsageAndTiming(t *testing.T) {
+	resp := &chatResponse{
+		PromptEvalCount:    20,
+		EvalCount:          50,
+		PromptEvalDuration: 40_000_000,
+		EvalDuration:       2_000_000_000,
+	}
+
+	assert.Equal(t, &backend.Usage{PromptTokens: 20, CompletionTokens: 50}, resp.usage())
+
+	timing := resp.timing()
+	assert.Equal(t, int64(40), timing.PromptMS)
+	assert.Equal(t, int64(2000), timing.CompletionMS)
+	assert.InDelta(t, 25.0, timing.TokensPerSec, 0.001)
+
+	// No eval duration must not divide by zero
+	assert.Zero(t, (&chatResponse{EvalCount: 3}).timing().TokensPerSec)
+}
+
+func TestBuildChatRequest_Format(t *testing.T) {
+	b := New(&Config{BaseURL: "http://localhost", Model: "qwen3"})
+	schema := map[string]interface{}{"type": "object"}
+
+	req := b.buildChatRequest(&backend.CompletionRequest{Prompt: "hi"}, false)
+	assert.Nil(t, req.Format)
+
+	req = b.buildChatRequest(&backend.CompletionRequest{
+		Prompt:         "hi",
+		ResponseFormat: &backend.ResponseFormat{Type: backend.ResponseJSON},
+	}, false)
+	assert.Equal(t, "json", req.Format)
+
+	req = b.buildChatRequest(&backend.CompletionRequest{
+		Prompt:         "hi",
+		ResponseFormat: &backend.ResponseFormat{Type: backend.ResponseJSONSchema, Schema: schema},
+	}, false)
+	assert.Equal(t, schema, req.Format)
+}
+
+func TestBackend_Embed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+		assert.Equal(t, []string{"a", "b"}, req.Input)
+		fmt.Fprint(w, `{"model":"nomic-embed-text","embeddings":[[0.1,0.2],[0.3,0.4]]}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, EmbeddingModel: "nomic-embed-text"})
+	assert.True(t, b.ModelInfo().HasCapability(backend.CapabilityEmbeddings))
+
+	vectors, err := b.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, vectors)
+}
+
+func TestBuildChatRequest_Sampling(t *testing.T) {
+	b := New(&Config{BaseURL: "http://localhost", Model: "qwen3"})
+
+	req := b.buildChatRequest(&backend.CompletionRequest{Prompt: "hi"}, false)
+	assert.NotContains(t, req.Options, "seed")
+	assert.NotContains(t, req.Options, "top_p")
+
+	seed := int64(0)
+	req = b.buildChatRequest(&backend.CompletionRequest{
+		Prompt: "hi",
+		Sampling: backend.Sampling{
+			TopP:          0.9,
+			TopK:          40,
+			MinP:          0.05,
+			Seed:          &seed,
+			RepeatPenalty: 1.1,
+			LogitBias:     map[string]float64{"a": 1},
+		},
+	}, false)
+	assert.Equal(t, 0.9, req.Options["top_p"])
+	assert.Equal(t, 40, req.Options["top_k"])
+	assert.Equal(t, 0.05, req.Options["min_p"])
+	assert.Equal(t, int64(0), req.Options["seed"], "zero is a valid seed")
+	assert.Equal(t, 1.1, req.Options["repeat_penalty"])
+	assert.NotContains(t, req.Options, "logit_bias")
+}
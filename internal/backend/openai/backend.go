@@ -23,6 +23,17 @@ type Backend struct {
 	apiKey     string
 	model      string
 	httpClient *http.Client
+	tools      bool
+
+	// Set for provider profiles
+	name      string
+	apiKeyEnv string
+	headers   map[string]string
+	maxTokens int
+	keyless   bool
+
+	// embeddingModel is used by Embed; empty means the chat model
+	embeddingModel string
 }
 
 // Config for OpenAI-compatible backend
@@ -31,13 +42,35 @@ type Config struct {
 	APIKey  string // API key (or from env)
 	Model   string // Model name
 	Timeout time.Duration
+
+	// EmbeddingModel is the model used for embeddings. When empty, Embed
+	// sends Model, which suits local servers that load a single model.
+	EmbeddingModel string
+
+	// ToolCalling overrides whether the tools request field is sent.
+	// When nil, tools are assumed for OpenAI, Groq and Together and
+	// disabled for other OpenAI-compatible servers.
+	ToolCalling *bool
+
+	// Name overrides the name derived from BaseURL. Named backends read
+	// their key only from APIKeyEnv, so keys meant for the built-in
+	// providers are never sent elsewhere, and need no key without one.
+	Name      string
+	APIKeyEnv string
+
+	// Headers are added to every request, e.g. Azure's api-key
+	Headers map[string]string
+
+	// MaxTokens is the default when a request sets none (2048 if zero)
+	MaxTokens int
 }
 
 // Presets for popular providers
 var (
 	OpenAIConfig = &Config{
-		BaseURL: "https://api.openai.com/v1",
-		Model:   "gpt-4o-mini",
+		BaseURL:        "https://api.openai.com/v1",
+		Model:          "gpt-4o-mini",
+		EmbeddingModel: "text-embedding-3-small",
 	}
 	TogetherConfig = &Config{
 		BaseURL: "https://api.together.xyz/v1",
@@ -60,7 +93,9 @@ func New(cfg *Config) *Backend {
 
 	// Try to get API key from environment if not provided
 	apiKey := cfg.APIKey
-	if apiKey == "" {
+	if apiKey == "" && cfg.APIKeyEnv != "" {
+		apiKey = os.Getenv(cfg.APIKeyEnv)
+	} else if apiKey == "" && cfg.Name == "" {
 		// Check various environment variables
 		for _, env := range []string{"OPENAI_API_KEY", "TOGETHER_API_KEY", "GROQ_API_KEY", "LLM_API_KEY"} {
 			if key := os.Getenv(env); key != "" {
@@ -70,14 +105,28 @@ func New(cfg *Config) *Backend {
 		}
 	}
 
-	return &Backend{
+	b := &Backend{
 		baseURL: cfg.BaseURL,
 		apiKey:  apiKey,
 		model:   cfg.Model,
 		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
+			Timeout:   cfg.Timeout,
+			Transport: backend.NewRetryTransport(backend.DefaultRetryPolicy(), nil),
 		},
+		embeddingModel: cfg.EmbeddingModel,
+		name:           cfg.Name,
+		apiKeyEnv:      cfg.APIKeyEnv,
+		headers:        cfg.Headers,
+		maxTokens:      cfg.MaxTokens,
+		keyless:        cfg.Name != "" && cfg.APIKeyEnv == "",
 	}
+	if cfg.ToolCalling != nil {
+		b.tools = *cfg.ToolCalling
+	} else {
+		b.tools = b.provider() != "openai-compatible"
+	}
+
+	return b
 }
 
 // NewOpenAI creates an OpenAI backend
@@ -103,7 +152,15 @@ func NewGroq(apiKey string) *Backend {
 
 // Name returns the backend name
 func (b *Backend) Name() string {
-	// Derive name from baseURL
+	if b.name != "" {
+		return b.name
+	}
+	return b.provider()
+}
+
+// provider identifies the service behind baseURL, whatever the backend
+// is named
+func (b *Backend) provider() string {
 	if strings.Contains(b.baseURL, "openai.com") {
 		return "openai"
 	}
@@ -123,7 +180,10 @@ func (b *Backend) Type() backend.Type {
 
 // Initialize initializes the backend
 func (b *Backend) Initialize(_ context.Context) error {
-	if b.apiKey == "" {
+	if b.apiKey == "" && b.apiKeyEnv != "" {
+		return fmt.Errorf("%s: API key required (set %s)", b.Name(), b.apiKeyEnv)
+	}
+	if b.apiKey == "" && !b.keyless {
 		return fmt.Errorf("API key required (set OPENAI_API_KEY, TOGETHER_API_KEY, or GROQ_API_KEY)")
 	}
 	return nil
@@ -131,7 +191,7 @@ func (b *Backend) Initialize(_ context.Context) error {
 
 // IsAvailable checks if the backend is configured
 func (b *Backend) IsAvailable(_ context.Context) (bool, error) {
-	return b.apiKey != "", nil
+	return b.apiKey != "" || b.keyless, nil
 }
 
 // Shutdown shuts down the backend
@@ -141,8 +201,20 @@ func (b *Backend) Shutdown(_ context.Context) error {
 
 // ChatMessage represents a chat message
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// toolCall is a function call requested by an assistant message
+type toolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 // chatRequest is the OpenAI chat completion request
@@ -153,6 +225,68 @@ type chatRequest struct {
 	Temperature float64       `json:"temperature,omitempty"`
 	Stream      bool          `json:"stream"`
 	Stop        []string      `json:"stop,omitempty"`
+	Tools       []chatTool    `json:"tools,omitempty"`
+	ToolChoice  interface{}   `json:"tool_choice,omitempty"`
+
+	TopP             float64            `json:"top_p,omitempty"`
+	Seed             *int64             `json:"seed,omitempty"`
+	FrequencyPenalty float64            `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64            `json:"presence_penalty,omitempty"`
+	LogitBias        map[string]float64 `json:"logit_bias,omitempty"`
+
+	// Not part of the OpenAI API, but accepted by Together and most
+	// self-hosted OpenAI-compatible servers
+	TopK              int     `json:"top_k,omitempty"`
+	MinP              float64 `json:"min_p,omitempty"`
+	RepetitionPenalty float64 `json:"repetition_penalty,omitempty"`
+
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	StreamOptions  *streamOptions  `json:"stream_options,omitempty"`
+}
+
+// responseFormat is the response_format request field
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+// jsonSchema names the schema a json_schema response must match
+type jsonSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// toResponseFormat maps a backend.ResponseFormat to response_format
+func toResponseFormat(f *backend.ResponseFormat) *responseFormat {
+	if f == nil {
+		return nil
+	}
+	if f.Type == backend.ResponseJSONSchema && f.Schema != nil {
+		name := f.Name
+		if name == "" {
+			name = "response"
+		}
+		return &responseFormat{
+			Type:       "json_schema",
+			JSONSchema: &jsonSchema{Name: name, Schema: f.Schema},
+		}
+	}
+	return &responseFormat{Type: "json_object"}
+}
+
+// streamOptions asks the API to report usage in a final streamed chunk
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatTool is a function tool definition
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	} `json:"function"`
 }
 
 // chatResponse is the OpenAI chat completion response
@@ -166,11 +300,25 @@ type chatResponse struct {
 		Message      ChatMessage `json:"message"`
 		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage chatUsage `json:"usage"`
+}
+
+// chatUsage is the token usage block of a chat completion
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// toUsage converts API usage into backend usage
+func (u *chatUsage) toUsage() *backend.Usage {
+	if u == nil {
+		return nil
+	}
+	return &backend.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+	}
 }
 
 // streamChunk is a streaming response chunk
@@ -182,15 +330,28 @@ type streamChunk struct {
 	Choices []struct {
 		Index int `json:"index"`
 		Delta struct {
-			Role    string `json:"role,omitempty"`
-			Content string `json:"content,omitempty"`
+			Role      string          `json:"role,omitempty"`
+			Content   string          `json:"content,omitempty"`
+			ToolCalls []toolCallDelta `json:"tool_calls,omitempty"`
 		} `json:"delta"`
 		FinishReason string `json:"finish_reason,omitempty"`
 	} `json:"choices"`
+	Usage *chatUsage `json:"usage,omitempty"` // Set on a trailing chunk with no choices
 }
 
-// Complete performs a non-streaming completion
-func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+// toolCallDelta is a streamed fragment of a tool call. The first fragment
+// for an index carries the ID and name; later ones append to the arguments.
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// buildMessages converts a completion request into chat messages
+func buildMessages(req *backend.CompletionRequest) []ChatMessage {
 	messages := []ChatMessage{}
 
 	if req.SystemPrompt != "" {
@@ -200,46 +361,115 @@ func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest)
 		})
 	}
 
-	messages = append(messages, ChatMessage{
-		Role:    "user",
-		Content: req.Prompt,
-	})
+	for _, msg := range req.ConversationMessages() {
+		chatMsg := ChatMessage{
+			Role:       string(msg.Role),
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			tc := toolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			args, _ := json.Marshal(call.Parameters)
+			tc.Function.Arguments = string(args)
+			chatMsg.ToolCalls = append(chatMsg.ToolCalls, tc)
+		}
+		messages = append(messages, chatMsg)
+	}
+
+	return messages
+}
 
+// buildChatRequest converts a completion request into a chat completion request
+func (b *Backend) buildChatRequest(req *backend.CompletionRequest, stream bool) chatRequest {
 	chatReq := chatRequest{
 		Model:       b.model,
-		Messages:    messages,
+		Messages:    buildMessages(req),
 		MaxTokens:   req.MaxTokens,
 		Temperature: req.Temperature,
-		Stream:      false,
+		Stream:      stream,
 		Stop:        req.StopSequences,
+
+		TopP:             req.TopP,
+		Seed:             req.Seed,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		LogitBias:        req.LogitBias,
+
+		ResponseFormat: toResponseFormat(req.ResponseFormat),
+	}
+
+	// OpenAI and Groq reject parameters they don't know
+	if name := b.provider(); name != "openai" && name != "groq" {
+		chatReq.TopK = req.TopK
+		chatReq.MinP = req.MinP
+		chatReq.RepetitionPenalty = req.RepeatPenalty
 	}
 
+	if chatReq.MaxTokens == 0 {
+		chatReq.MaxTokens = b.maxTokens
+	}
 	if chatReq.MaxTokens == 0 {
 		chatReq.MaxTokens = 2048
 	}
+	if stream {
+		chatReq.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
 
-	body, err := json.Marshal(chatReq)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
+	return chatReq
+}
+
+// buildToolRequest converts a tool request into a chat completion request
+func (b *Backend) buildToolRequest(req *backend.ToolRequest, stream bool) chatRequest {
+	chatReq := b.buildChatRequest(&req.CompletionRequest, stream)
+
+	for _, def := range req.Tools {
+		t := chatTool{Type: "function"}
+		t.Function.Name = def.Name
+		t.Function.Description = def.Description
+		t.Function.Parameters = def.JSONSchema()
+		chatReq.Tools = append(chatReq.Tools, t)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+	if len(chatReq.Tools) > 0 {
+		switch req.ToolChoice {
+		case "":
+		case "auto", "none", "required":
+			chatReq.ToolChoice = req.ToolChoice
+		default:
+			chatReq.ToolChoice = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]string{"name": req.ToolChoice},
+			}
+		}
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+b.apiKey)
 
-	resp, err := b.httpClient.Do(httpReq)
+	return chatReq
+}
+
+// Complete performs a non-streaming completion
+func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	chatResp, err := b.complete(ctx, b.buildChatRequest(req, false))
 	if err != nil {
-		return nil, fmt.Errorf("API request failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	return &backend.CompletionResponse{
+		Content:          chatResp.Choices[0].Message.Content,
+		TokensUsed:       chatResp.Usage.TotalTokens,
+		PromptTokens:     chatResp.Usage.PromptTokens,
+		CompletionTokens: chatResp.Usage.CompletionTokens,
+		FinishReason:     finishReason(chatResp.Choices[0].FinishReason),
+	}, nil
+}
+
+// complete sends a non-streaming chat completion request
+func (b *Backend) complete(ctx context.Context, chatReq chatRequest) (*chatResponse, error) {
+	resp, err := b.post(ctx, chatReq)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	var chatResp chatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
@@ -250,61 +480,32 @@ func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest)
 		return nil, fmt.Errorf("no response from API")
 	}
 
-	finishReason := backend.FinishComplete
-	switch chatResp.Choices[0].FinishReason {
-	case "length":
-		finishReason = backend.FinishLength
-	case "stop":
-		finishReason = backend.FinishStop
-	}
-
-	return &backend.CompletionResponse{
-		Content:      chatResp.Choices[0].Message.Content,
-		TokensUsed:   chatResp.Usage.TotalTokens,
-		FinishReason: finishReason,
-	}, nil
+	return &chatResp, nil
 }
 
-// Stream performs a streaming completion
-func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
-	messages := []ChatMessage{}
-
-	if req.SystemPrompt != "" {
-		messages = append(messages, ChatMessage{
-			Role:    "system",
-			Content: req.SystemPrompt,
-		})
-	}
-
-	messages = append(messages, ChatMessage{
-		Role:    "user",
-		Content: req.Prompt,
-	})
-
-	chatReq := chatRequest{
-		Model:       b.model,
-		Messages:    messages,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Stream:      true,
-		Stop:        req.StopSequences,
-	}
-
-	if chatReq.MaxTokens == 0 {
-		chatReq.MaxTokens = 2048
-	}
+// post sends a chat completion request and checks the status code
+func (b *Backend) post(ctx context.Context, chatReq chatRequest) (*http.Response, error) {
+	return b.postJSON(ctx, "/chat/completions", chatReq)
+}
 
-	body, err := json.Marshal(chatReq)
+// postJSON sends an authenticated API request and checks the status code
+func (b *Backend) postJSON(ctx context.Context, path string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/chat/completions", bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.endpoint(path), bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+b.apiKey)
+	if b.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+	for k, v := range b.headers {
+		httpReq.Header.Set(k, v)
+	}
 
 	resp, err := b.httpClient.Do(httpReq)
 	if err != nil {
@@ -317,12 +518,60 @@ func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
 	}
 
+	return resp, nil
+}
+
+// endpoint appends path to the base URL, keeping any query string such
+// as Azure's api-version after it
+func (b *Backend) endpoint(path string) string {
+	base, query, ok := strings.Cut(b.baseURL, "?")
+	if !ok {
+		return b.baseURL + path
+	}
+	return base + path + "?" + query
+}
+
+// Stream performs a streaming completion
+func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	return b.stream(ctx, b.buildChatRequest(req, true))
+}
+
+// StreamWithTools performs a streaming completion with tool calling.
+// Tool call deltas are accumulated and delivered on the final chunk.
+func (b *Backend) StreamWithTools(ctx context.Context, req *backend.ToolRequest) (<-chan backend.StreamChunk, error) {
+	return b.stream(ctx, b.buildToolRequest(req, true))
+}
+
+// stream sends a streaming chat completion request
+func (b *Backend) stream(ctx context.Context, chatReq chatRequest) (<-chan backend.StreamChunk, error) {
+	resp, err := b.post(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
 	ch := make(chan backend.StreamChunk)
 
 	go func() {
 		defer close(ch)
 		defer resp.Body.Close()
 
+		send := func(chunk backend.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var (
+			calls    toolCallAccumulator
+			usage    *backend.Usage
+			finished bool
+		)
+
+		// With include_usage the finish_reason chunk is followed by a
+		// usage-only chunk with no choices, then [DONE]
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -340,47 +589,154 @@ func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<
 
 			// Check for stream end
 			if data == "[DONE]" {
-				ch <- backend.StreamChunk{Done: true}
-				return
+				finished = true
+				break
 			}
 
 			var chunk streamChunk
 			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-				ch <- backend.StreamChunk{Error: err}
+				send(backend.StreamChunk{Error: err})
 				return
 			}
 
-			if len(chunk.Choices) > 0 {
-				content := chunk.Choices[0].Delta.Content
-				done := chunk.Choices[0].FinishReason != ""
+			if chunk.Usage != nil {
+				usage = chunk.Usage.toUsage()
+			}
 
-				ch <- backend.StreamChunk{
-					Content: content,
-					Done:    done,
+			if len(chunk.Choices) == 0 {
+				if finished && usage != nil {
+					break
 				}
+				continue
+			}
+
+			delta := chunk.Choices[0].Delta
+			if err := calls.Add(delta.ToolCalls); err != nil {
+				send(backend.StreamChunk{Error: err})
+				return
+			}
+
+			if delta.Content != "" && !send(backend.StreamChunk{Content: delta.Content}) {
+				return
+			}
 
-				if done {
-					return
+			if chunk.Choices[0].FinishReason != "" {
+				finished = true
+				if usage != nil {
+					break
 				}
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			ch <- backend.StreamChunk{Error: err}
+			send(backend.StreamChunk{Error: err})
+			return
+		}
+		if !finished {
+			if ctx.Err() == nil {
+				send(backend.StreamChunk{Error: fmt.Errorf("stream ended early: %w", io.ErrUnexpectedEOF)})
+			}
+			return
 		}
+
+		toolCalls, err := calls.ToolCalls()
+		send(backend.StreamChunk{ToolCalls: toolCalls, Usage: usage, Done: true, Error: err})
 	}()
 
 	return ch, nil
 }
 
-// SupportsToolCalling returns true for OpenAI
+// maxToolCalls bounds how many parallel tool calls a stream may open
+const maxToolCalls = 64
+
+// toolCallAccumulator reassembles streamed tool call deltas by index
+type toolCallAccumulator struct {
+	calls []toolCall
+}
+
+// Add merges a batch of deltas into the accumulated calls. Indexes come
+// from the server, so anything outside [0, maxToolCalls) is rejected
+// rather than used to size the slice.
+func (a *toolCallAccumulator) Add(deltas []toolCallDelta) error {
+	for _, d := range deltas {
+		if d.Index < 0 || d.Index >= maxToolCalls {
+			return fmt.Errorf("invalid tool call index %d", d.Index)
+		}
+		for len(a.calls) <= d.Index {
+			a.calls = append(a.calls, toolCall{Type: "function"})
+		}
+		call := &a.calls[d.Index]
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Function.Name != "" {
+			call.Function.Name = d.Function.Name
+		}
+		call.Function.Arguments += d.Function.Arguments
+	}
+	return nil
+}
+
+// ToolCalls returns the accumulated calls with parsed arguments
+func (a *toolCallAccumulator) ToolCalls() ([]backend.ToolCall, error) {
+	return parseToolCalls(a.calls)
+}
+
+// SupportsToolCalling reports whether the tools request field is sent.
+// See Config.ToolCalling for the per-provider default.
 func (b *Backend) SupportsToolCalling() bool {
-	return strings.Contains(b.baseURL, "openai.com")
+	return b.tools
+}
+
+// CompleteWithTools performs completion with tool calling
+func (b *Backend) CompleteWithTools(ctx context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	chatResp, err := b.complete(ctx, b.buildToolRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+
+	msg := chatResp.Choices[0].Message
+	toolCalls, err := parseToolCalls(msg.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.ToolResponse{
+		Content:   msg.Content,
+		ToolCalls: toolCalls,
+		Usage:     chatResp.Usage.toUsage(),
+	}, nil
+}
+
+// parseToolCalls converts API tool calls into backend tool calls
+func parseToolCalls(calls []toolCall) ([]backend.ToolCall, error) {
+	var result []backend.ToolCall
+	for _, call := range calls {
+		params := map[string]interface{}{}
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &params); err != nil {
+				return nil, fmt.Errorf("decode %s arguments: %w", call.Function.Name, err)
+			}
+		}
+		result = append(result, backend.ToolCall{
+			ID:         call.ID,
+			Name:       call.Function.Name,
+			Parameters: params,
+		})
+	}
+	return result, nil
 }
 
-// CompleteWithTools performs completion with tool calling (OpenAI only)
-func (b *Backend) CompleteWithTools(_ context.Context, _ *backend.ToolRequest) (*backend.ToolResponse, error) {
-	return nil, fmt.Errorf("tool calling not yet implemented")
+// finishReason maps an API finish_reason to a backend.FinishReason
+func finishReason(reason string) backend.FinishReason {
+	switch reason {
+	case "length":
+		return backend.FinishLength
+	case "stop", "tool_calls":
+		return backend.FinishStop
+	default:
+		return backend.FinishComplete
+	}
 }
 
 // ModelInfo returns model information
@@ -388,8 +744,68 @@ func (b *Backend) ModelInfo() *backend.ModelInfo {
 	return &backend.ModelInfo{
 		Name:          b.model,
 		ContextLength: 128000, // Varies by model
-		Capabilities:  []string{"text", "code", "chat"},
+		Capabilities:  b.capabilities(),
+	}
+}
+
+// capabilities lists embeddings only when an embedding model is known
+func (b *Backend) capabilities() []string {
+	capabilities := []string{backend.CapabilityText, backend.CapabilityCode, backend.CapabilityChat}
+	if b.tools {
+		capabilities = append(capabilities, backend.CapabilityToolCalling)
+	}
+	if b.embeddingModel != "" {
+		capabilities = append(capabilities, backend.CapabilityEmbeddings)
+	}
+	return capabilities
+}
+
+// embeddingRequest is the /embeddings request body
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingResponse is the /embeddings response body
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed embeds texts with the /embeddings endpoint
+func (b *Backend) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	model := b.embeddingModel
+	if model == "" {
+		model = b.model
+	}
+
+	resp, err := b.postJSON(ctx, "/embeddings", embeddingRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embedResp embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	// Entries carry their input index and aren't guaranteed to be in order
+	vectors := make([][]float32, len(texts))
+	for _, d := range embedResp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("invalid embedding index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("missing embedding for input %d", i)
+		}
 	}
+	return vectors, nil
 }
 
 // EstimateTokens estimates token count
@@ -398,6 +814,11 @@ func (b *Backend) EstimateTokens(text string) int {
 	return len(text) / 4
 }
 
+// SetRetry sets how failed requests are retried and paced
+func (b *Backend) SetRetry(policy backend.RetryPolicy, limiter *backend.Limiter) {
+	b.httpClient.Transport = backend.NewRetryTransport(policy, limiter)
+}
+
 // SetModel changes the active model
 func (b *Backend) SetModel(model string) {
 	b.model = model
@@ -1,9 +1,15 @@
 package openai
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/scmd/scmd/internal/backend"
 )
@@ -77,5 +83,432 @@ func TestBackend_SupportsToolCalling(t *testing.T) {
 	assert.True(t, openai.SupportsToolCalling())
 
 	groq := NewGroq("test")
-	assert.False(t, groq.SupportsToolCalling())
+	assert.True(t, groq.SupportsToolCalling())
+
+	together := NewTogether("test")
+	assert.True(t, together.SupportsToolCalling())
+
+	// Unknown compatible servers only get tools when configured to
+	local := New(&Config{BaseURL: "http://localhost:1234/v1"})
+	assert.False(t, local.SupportsToolCalling())
+
+	enabled := true
+	local = New(&Config{BaseURL: "http://localhost:1234/v1", ToolCalling: &enabled})
+	assert.True(t, local.SupportsToolCalling())
+
+	disabled := false
+	together = New(&Config{BaseURL: TogetherConfig.BaseURL, ToolCalling: &disabled})
+	assert.False(t, together.SupportsToolCalling())
+}
+
+func TestToolCallAccumulator_InvalidIndex(t *testing.T) {
+	for _, index := range []int{-1, maxToolCalls, 1 << 30} {
+		var calls toolCallAccumulator
+		err := calls.Add([]toolCallDelta{{Index: index}})
+		assert.Error(t, err, "index %d", index)
+		assert.Empty(t, calls.calls)
+	}
+}
+
+var readFileTool = backend.ToolDefinition{
+	Name:        "read_file",
+	Description: "Read a file",
+	Parameters: map[string]backend.ToolParameter{
+		"path": {Type: "string", Description: "File path", Required: true},
+	},
+}
+
+func TestBackend_CompleteWithTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Len(t, req.Tools, 1)
+		assert.Equal(t, "function", req.Tools[0].Type)
+		assert.Equal(t, "read_file", req.Tools[0].Function.Name)
+		assert.Equal(t, []interface{}{"path"}, req.Tools[0].Function.Parameters["required"])
+		assert.Equal(t, "required", req.ToolChoice)
+
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[
+			{"id":"call_a","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"a.go\"}"}},
+			{"id":"call_b","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"b.go\"}"}}
+		]},"finish_reason":"tool_calls"}]}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	resp, err := b.CompleteWithTools(context.Background(), &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "read both files"},
+		Tools:             []backend.ToolDefinition{readFileTool},
+		ToolChoice:        "required",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.ToolCalls, 2)
+	assert.Equal(t, "call_a", resp.ToolCalls[0].ID)
+	assert.Equal(t, "a.go", resp.ToolCalls[0].Parameters["path"])
+	assert.Equal(t, "call_b", resp.ToolCalls[1].ID)
+	assert.Equal(t, "b.go", resp.ToolCalls[1].Parameters["path"])
+}
+
+func TestBackend_CompleteWithTools_NamedChoice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": "read_file"},
+		}, req.ToolChoice)
+
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"no tools needed"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	resp, err := b.CompleteWithTools(context.Background(), &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "hi"},
+		Tools:             []backend.ToolDefinition{readFileTool},
+		ToolChoice:        "read_file",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "no tools needed", resp.Content)
+	assert.Empty(t, resp.ToolCalls)
+}
+
+func TestBackend_StreamWithTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+
+		chunks := []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Reading"}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"b.go\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.go\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		}
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	var streamer backend.ToolStreamer = b
+	ch, err := streamer.StreamWithTools(context.Background(), &backend.ToolRequest{
+		CompletionRequest: backend.CompletionRequest{Prompt: "read both files"},
+		Tools:             []backend.ToolDefinition{readFileTool},
+	})
+	require.NoError(t, err)
+
+	var content string
+	var final backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		content += chunk.Content
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	assert.Equal(t, "Reading", content)
+	require.Len(t, final.ToolCalls, 2)
+	assert.Equal(t, "call_a", final.ToolCalls[0].ID)
+	assert.Equal(t, "a.go", final.ToolCalls[0].Parameters["path"])
+	assert.Equal(t, "call_b", final.ToolCalls[1].ID)
+	assert.Equal(t, "b.go", final.ToolCalls[1].Parameters["path"])
+}
+
+func TestBackend_Complete_Messages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Len(t, req.Messages, 5)
+		assert.Equal(t, "system", req.Messages[0].Role)
+		assert.Equal(t, "user", req.Messages[1].Role)
+		assert.Equal(t, "assistant", req.Messages[2].Role)
+		require.Len(t, req.Messages[2].ToolCalls, 1)
+		assert.Equal(t, "call_1", req.Messages[2].ToolCalls[0].ID)
+		assert.JSONEq(t, `{"path":"go.mod"}`, req.Messages[2].ToolCalls[0].Function.Arguments)
+		assert.Equal(t, "tool", req.Messages[3].Role)
+		assert.Equal(t, "call_1", req.Messages[3].ToolCallID)
+		assert.Equal(t, "user", req.Messages[4].Role)
+
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"done"},"finish_reason":"stop"}],"usage":{"total_tokens":9}}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{
+		SystemPrompt: "be brief",
+		Messages: []backend.Message{
+			{Role: backend.RoleUser, Content: "read go.mod"},
+			{Role: backend.RoleAssistant, ToolCalls: []backend.ToolCall{
+				{ID: "call_1", Name: "read_file", Parameters: map[string]interface{}{"path": "go.mod"}},
+			}},
+			{Role: backend.RoleTool, Content: "module x", ToolCallID: "call_1", ToolName: "read_file"},
+		},
+		Prompt: "summarise it",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "done", resp.Content)
+}
+
+func TestBackend_Complete_Usage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":11,"completion_tokens":4,"total_tokens":15}}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, 15, resp.TokensUsed)
+	assert.Equal(t, 11, resp.PromptTokens)
+	assert.Equal(t, 4, resp.CompletionTokens)
+}
+
+func TestChatUsage_ToUsage(t *testing.T) {
+	var nilUsage *chatUsage
+	assert.Nil(t, nilUsage.toUsage())
+
+	usage := (&chatUsage{PromptTokens: 3, CompletionTokens: 5, TotalTokens: 8}).toUsage()
+	assert.Equal(t, &backend.Usage{PromptTokens: 3, CompletionTokens: 5}, usage)
+	assert.Equal(t, 8, usage.Total())
+}
+
+func TestBackend_Stream_Usage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.NotNil(t, req.StreamOptions)
+		assert.True(t, req.StreamOptions.IncludeUsage)
+
+		chunks := []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":" there"},"finish_reason":"stop"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`,
+		}
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	ch, err := b.Stream(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	var content string
+	var final backend.StreamChunk
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		content += chunk.Content
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	assert.Equal(t, "Hi there", content)
+	assert.True(t, final.Done)
+	require.NotNil(t, final.Usage)
+	assert.Equal(t, 9, final.Usage.PromptTokens)
+	assert.Equal(t, 2, final.Usage.CompletionTokens)
+}
+
+func TestBackend_Stream_Truncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	ch, err := b.Stream(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	var last backend.StreamChunk
+	for chunk := range ch {
+		last = chunk
+	}
+	assert.Error(t, last.Error)
+	assert.False(t, last.Done)
+}
+
+func TestBuildChatRequest_ResponseFormat(t *testing.T) {
+	b := New(&Config{BaseURL: "http://localhost", Model: "gpt-4o-mini"})
+	schema := map[string]interface{}{"type": "object"}
+
+	tests := []struct {
+		name     string
+		format   *backend.ResponseFormat
+		expected string
+	}{
+		{"none", nil, `null`},
+		{"json object", &backend.ResponseFormat{Type: backend.ResponseJSON}, `{"type":"json_object"}`},
+		{"schema", &backend.ResponseFormat{Type: backend.ResponseJSONSchema, Name: "review", Schema: schema},
+			`{"type":"json_schema","json_schema":{"name":"review","schema":{"type":"object"}}}`},
+		{"unnamed schema", &backend.ResponseFormat{Type: backend.ResponseJSONSchema, Schema: schema},
+			`{"type":"json_schema","json_schema":{"name":"response","schema":{"type":"object"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatReq := b.buildChatRequest(&backend.CompletionRequest{Prompt: "hi", ResponseFormat: tt.format}, false)
+			body, err := json.Marshal(chatReq.ResponseFormat)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestBackend_Embed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		var req embeddingRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "embed-small", req.Model)
+		assert.Equal(t, []string{"one", "two"}, req.Input)
+
+		// Out of order on purpose; index decides placement
+		fmt.Fprint(w, `{"data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}]}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test", Model: "chat", EmbeddingModel: "embed-small"})
+	assert.True(t, b.ModelInfo().HasCapability(backend.CapabilityEmbeddings))
+
+	vectors, err := b.Embed(context.Background(), []string{"one", "two"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, vectors)
+}
+
+func TestBackend_Embed_MissingIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "local", req.Model, "falls back to the chat model")
+		fmt.Fprint(w, `{"data":[{"index":0,"embedding":[0.1]}]}`)
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test", Model: "local"})
+	assert.False(t, b.ModelInfo().HasCapability(backend.CapabilityEmbeddings))
+
+	_, err := b.Embed(context.Background(), []string{"one", "two"})
+	assert.ErrorContains(t, err, "missing embedding for input 1")
+}
+
+func TestBuildChatRequest_Sampling(t *testing.T) {
+	seed := int64(42)
+	req := &backend.CompletionRequest{
+		Prompt: "hi",
+		Sampling: backend.Sampling{
+			TopP:             0.9,
+			TopK:             40,
+			MinP:             0.05,
+			Seed:             &seed,
+			RepeatPenalty:    1.1,
+			FrequencyPenalty: 0.5,
+			PresencePenalty:  -0.5,
+			LogitBias:        map[string]float64{"50256": -100},
+		},
+	}
+
+	chatReq := NewOpenAI("test").buildChatRequest(req, false)
+	assert.Equal(t, 0.9, chatReq.TopP)
+	require.NotNil(t, chatReq.Seed)
+	assert.Equal(t, int64(42), *chatReq.Seed)
+	assert.Equal(t, 0.5, chatReq.FrequencyPenalty)
+	assert.Equal(t, -0.5, chatReq.PresencePenalty)
+	assert.Equal(t, map[string]float64{"50256": -100}, chatReq.LogitBias)
+	assert.Zero(t, chatReq.TopK, "OpenAI rejects top_k")
+	assert.Zero(t, chatReq.RepetitionPenalty)
+
+	chatReq = New(&Config{BaseURL: "http://localhost:8000/v1", APIKey: "test"}).buildChatRequest(req, false)
+	assert.Equal(t, 40, chatReq.TopK)
+	assert.Equal(t, 0.05, chatReq.MinP)
+	assert.Equal(t, 1.1, chatReq.RepetitionPenalty)
+
+	data, err := json.Marshal(NewOpenAI("test").buildChatRequest(&backend.CompletionRequest{Prompt: "hi"}, false))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "seed")
+	assert.NotContains(t, string(data), "top_p")
+}
+
+func TestBackend_NamedProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/openai/deployments/gpt-4o/chat/completions", r.URL.Path)
+		assert.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+		assert.Equal(t, "secret", r.Header.Get("Api-Key"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		var body chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, 4096, body.MaxTokens)
+
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	// Keys for the built-in providers must not leak to named backends
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	b := New(&Config{
+		Name:      "azure",
+		BaseURL:   srv.URL + "/openai/deployments/gpt-4o?api-version=2024-10-21",
+		Headers:   map[string]string{"api-key": "secret"},
+		MaxTokens: 4096,
+	})
+	assert.Equal(t, "azure", b.Name())
+	assert.False(t, b.SupportsToolCalling())
+
+	avail, err := b.IsAvailable(context.Background())
+	require.NoError(t, err)
+	assert.True(t, avail, "no api_key_env means no key is needed")
+
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Content)
+}
+
+func TestBackend_NamedProvider_APIKeyEnv(t *testing.T) {
+	t.Setenv("GATEWAY_KEY", "")
+	b := New(&Config{Name: "gateway", BaseURL: "https://llm.internal/v1", APIKeyEnv: "GATEWAY_KEY"})
+	assert.ErrorContains(t, b.Initialize(context.Background()), "GATEWAY_KEY")
+
+	t.Setenv("GATEWAY_KEY", "k")
+	b = New(&Config{Name: "gateway", BaseURL: "https://llm.internal/v1", APIKeyEnv: "GATEWAY_KEY"})
+	assert.NoError(t, b.Initialize(context.Background()))
+	assert.Equal(t, "k", b.apiKey)
+}
+
+func TestBackend_Stream_RetriesBeforeFirstChunk(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	b := New(&Config{BaseURL: srv.URL, APIKey: "test"})
+	b.SetRetry(backend.RetryPolicy{MaxAttempts: 2}, nil)
+
+	ch, err := b.Stream(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	var content string
+	for chunk := range ch {
+		require.NoError(t, chunk.Error)
+		content += chunk.Content
+	}
+	assert.Equal(t, "hi", content)
+	assert.Equal(t, 2, attempts)
 }
@@ -3,6 +3,7 @@ package backend
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -12,6 +13,39 @@ type Registry struct {
 	backends map[string]Backend
 	order    []string // Preserve registration order
 	default_ string
+	fallback []string // Ordered chain tried after the default
+	rules    []Rule
+}
+
+// Rule routes requests to a backend by command category or prompt size.
+// Empty or zero fields match anything.
+type Rule struct {
+	Category  string
+	MinTokens int
+	MaxTokens int
+	Backend   string
+	Model     string // Model to use on Backend; empty keeps its default
+}
+
+// Hint describes a request for routing purposes
+type Hint struct {
+	Category     string
+	PromptTokens int
+	Command      string // Command name, recorded with usage; not used for routing
+}
+
+// matches reports whether the rule applies to the hint
+func (r Rule) matches(h Hint) bool {
+	if r.Category != "" && !strings.EqualFold(r.Category, h.Category) {
+		return false
+	}
+	if r.MinTokens > 0 && h.PromptTokens < r.MinTokens {
+		return false
+	}
+	if r.MaxTokens > 0 && h.PromptTokens > r.MaxTokens {
+		return false
+	}
+	return true
 }
 
 // NewRegistry creates a new backend registry
@@ -94,28 +128,111 @@ func (r *Registry) List() []Backend {
 	return backends
 }
 
-// GetAvailable returns the first available backend in registration order
+// SetFallback sets the ordered chain of backends tried after the default.
+// Names that aren't registered (e.g. a cloud backend without an API key)
+// are skipped when resolving.
+func (r *Registry) SetFallback(names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallback = append([]string(nil), names...)
+}
+
+// AddRule appends a routing rule; rules are checked in the order added
+func (r *Registry) AddRule(rule Rule) error {
+	if rule.Backend == "" {
+		return fmt.Errorf("routing rule has no backend")
+	}
+	if rule.MaxTokens > 0 && rule.MinTokens > rule.MaxTokens {
+		return fmt.Errorf("routing rule for %s: min_tokens %d exceeds max_tokens %d",
+			rule.Backend, rule.MinTokens, rule.MaxTokens)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// GetAvailable returns the first available backend, trying the default,
+// then the fallback chain, then (without a chain) registration order
 func (r *Registry) GetAvailable(ctx context.Context) (Backend, error) {
+	return r.Resolve(ctx, Hint{})
+}
+
+// Resolve returns the first available backend for a request. Backends
+// named by matching routing rules are tried first, then the same order
+// as GetAvailable. Availability is probed with IsAvailable.
+func (r *Registry) Resolve(ctx context.Context, hint Hint) (Backend, error) {
+	r.mu.RLock()
+	candidates := r.candidates(hint)
+	backends := make([]Backend, 0, len(candidates))
+	for _, name := range candidates {
+		if b, ok := r.backends[name]; ok {
+			backends = append(backends, b)
+		}
+	}
+	r.mu.RUnlock()
+
+	// Probe without the lock; remote checks may take a while
+	tried := make([]string, 0, len(backends))
+	for _, b := range backends {
+		if avail, _ := b.IsAvailable(ctx); avail {
+			return b, nil
+		}
+		tried = append(tried, b.Name())
+	}
+
+	if len(tried) == 0 {
+		return nil, fmt.Errorf("no available backends")
+	}
+	return nil, fmt.Errorf("no available backends (tried %s)", strings.Join(tried, ", "))
+}
+
+// RouteModel returns the model named by the first matching rule that
+// routes to backendName, or "" if none does
+func (r *Registry) RouteModel(hint Hint, backendName string) string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	// Try default first
-	if r.default_ != "" {
-		if b, ok := r.backends[r.default_]; ok {
-			if avail, _ := b.IsAvailable(ctx); avail {
-				return b, nil
-			}
+	for _, rule := range r.rules {
+		if rule.Backend == backendName && rule.Model != "" && rule.matches(hint) {
+			return rule.Model
 		}
 	}
+	return ""
+}
 
-	// Try others in registration order
-	for _, name := range r.order {
-		if b, ok := r.backends[name]; ok {
-			if avail, _ := b.IsAvailable(ctx); avail {
-				return b, nil
-			}
+// candidates returns backend names in the order they should be tried,
+// without duplicates. Caller must hold r.mu.
+func (r *Registry) candidates(hint Hint) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, rule := range r.rules {
+		if rule.matches(hint) {
+			add(rule.Backend)
 		}
 	}
 
-	return nil, fmt.Errorf("no available backends")
+	add(r.default_)
+
+	if len(r.fallback) > 0 {
+		for _, name := range r.fallback {
+			add(name)
+		}
+		return names
+	}
+
+	for _, name := range r.order {
+		add(name)
+	}
+	return names
 }
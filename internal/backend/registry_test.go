@@ -132,3 +132,85 @@ func TestRegistry_GetAvailable_None(t *testing.T) {
 	_, err := r.GetAvailable(context.Background())
 	assert.Error(t, err)
 }
+
+func TestRegistry_GetAvailable_FallbackChain(t *testing.T) {
+	r := NewRegistry()
+
+	r.Register(&testBackend{name: "llamacpp", available: false})
+	r.Register(&testBackend{name: "ollama", available: false})
+	r.Register(&testBackend{name: "mock", available: true})
+	r.Register(&testBackend{name: "groq", available: true})
+	require.NoError(t, r.SetDefault("llamacpp"))
+
+	// Unregistered names in the chain are skipped
+	r.SetFallback([]string{"ollama", "together", "groq"})
+
+	b, err := r.GetAvailable(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "groq", b.Name(), "chain order wins over registration order")
+}
+
+func TestRegistry_GetAvailable_FallbackExhausted(t *testing.T) {
+	r := NewRegistry()
+
+	r.Register(&testBackend{name: "llamacpp", available: false})
+	r.Register(&testBackend{name: "ollama", available: false})
+	r.Register(&testBackend{name: "mock", available: true})
+	r.SetFallback([]string{"llamacpp", "ollama"})
+
+	_, err := r.GetAvailable(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tried llamacpp, ollama")
+}
+
+func TestRegistry_Resolve_Rules(t *testing.T) {
+	r := NewRegistry()
+
+	r.Register(&testBackend{name: "llamacpp", available: true})
+	r.Register(&testBackend{name: "groq", available: true})
+	r.Register(&testBackend{name: "claude", available: false})
+	require.NoError(t, r.SetDefault("llamacpp"))
+
+	require.NoError(t, r.AddRule(Rule{Category: "git", Backend: "groq"}))
+	require.NoError(t, r.AddRule(Rule{MinTokens: 4000, Backend: "claude"}))
+	require.NoError(t, r.AddRule(Rule{MinTokens: 4000, Backend: "groq"}))
+
+	tests := []struct {
+		name     string
+		hint     Hint
+		expected string
+	}{
+		{"no match uses default", Hint{Category: "code", PromptTokens: 100}, "llamacpp"},
+		{"category match", Hint{Category: "git"}, "groq"},
+		{"category is case-insensitive", Hint{Category: "GIT"}, "groq"},
+		{"unavailable rule target falls through", Hint{PromptTokens: 8000}, "groq"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := r.Resolve(context.Background(), tt.hint)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, b.Name())
+		})
+	}
+}
+
+func TestRegistry_RouteModel(t *testing.T) {
+	r := NewRegistry()
+
+	require.NoError(t, r.AddRule(Rule{Category: "git", Backend: "groq"}))
+	require.NoError(t, r.AddRule(Rule{Category: "code", Backend: "llamacpp", Model: "qwen2.5-coder-7b"}))
+	require.NoError(t, r.AddRule(Rule{MinTokens: 4000, Backend: "llamacpp", Model: "qwen3-8b"}))
+
+	assert.Equal(t, "qwen2.5-coder-7b", r.RouteModel(Hint{Category: "code", PromptTokens: 9000}, "llamacpp"))
+	assert.Equal(t, "qwen3-8b", r.RouteModel(Hint{Category: "git", PromptTokens: 9000}, "llamacpp"))
+	assert.Equal(t, "", r.RouteModel(Hint{Category: "code"}, "groq"), "rules for other backends are ignored")
+	assert.Equal(t, "", r.RouteModel(Hint{Category: "shell"}, "llamacpp"))
+}
+
+func TestRegistry_AddRule_Invalid(t *testing.T) {
+	r := NewRegistry()
+
+	assert.Error(t, r.AddRule(Rule{Category: "git"}))
+	assert.Error(t, r.AddRule(Rule{MinTokens: 100, MaxTokens: 10, Backend: "groq"}))
+}
@@ -0,0 +1,319 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how remote backends retry failed requests.
+// Requests are retried on connection resets, 408, 429 and 5xx responses
+// other than 501. Retries happen before a response is returned, so a
+// stream is never restarted once its first chunk has arrived.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // Wait before the first retry, doubled for each one after
+	MaxDelay    time.Duration // Cap on a single backoff wait
+	Deadline    time.Duration // Total time for all attempts; 0 means no limit
+}
+
+// DefaultRetryPolicy returns the policy remote backends start with
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Deadline:    2 * time.Minute,
+	}
+}
+
+// backoff returns the wait after the given failed attempt: exponential,
+// capped at MaxDelay, with the upper half randomized so parallel clients
+// spread out
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// RetryConfigurer is implemented by backends that talk to a server over
+// HTTP. SetRetry replaces their retry policy and rate limiter; limiter
+// may be nil.
+type RetryConfigurer interface {
+	SetRetry(policy RetryPolicy, limiter *Limiter)
+}
+
+// RetryTransport is an http.RoundTripper that paces requests with a
+// Limiter and retries them according to a RetryPolicy
+type RetryTransport struct {
+	Base    http.RoundTripper // Default: http.DefaultTransport
+	Policy  RetryPolicy
+	Limiter *Limiter
+}
+
+// NewRetryTransport returns a transport with policy and limiter over
+// http.DefaultTransport
+func NewRetryTransport(policy RetryPolicy, limiter *Limiter) *RetryTransport {
+	return &RetryTransport{Policy: policy, Limiter: limiter}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.Limiter != nil {
+		if err := t.Limiter.Wait(ctx, requestTokens(req)); err != nil {
+			return nil, err
+		}
+	}
+
+	// A body that can't be replayed can't be retried
+	maxAttempts := t.Policy.MaxAttempts
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		maxAttempts = 1
+	}
+
+	var deadline time.Time
+	if t.Policy.Deadline > 0 {
+		deadline = time.Now().Add(t.Policy.Deadline)
+	}
+
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		resp, err := base.RoundTrip(r)
+		if attempt >= maxAttempts || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := t.Policy.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp.Header, time.Now()); ok {
+				delay = d
+			}
+		}
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+
+		reason := fmt.Sprint(err)
+		if resp != nil {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if os.Getenv("SCMD_DEBUG") != "" {
+			fmt.Fprintf(os.Stderr, "[DEBUG] %s %s: %s, retrying in %s (attempt %d/%d)\n",
+				req.Method, req.URL.Host, reason, delay.Round(time.Millisecond), attempt+1, maxAttempts)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry reports whether a failed attempt is worth repeating
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNABORTED) ||
+			errors.Is(err, syscall.EPIPE) ||
+			errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented:
+		return false
+	}
+	return resp.StatusCode >= 500
+}
+
+// retryAfter reads how long the server asked us to wait, from
+// retry-after-ms (OpenAI) or Retry-After in seconds or as an HTTP date
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// requestTokens estimates how many tokens a request counts against a
+// tokens-per-minute limit: about four bytes per prompt token, plus the
+// completion budget, which providers count up front
+func requestTokens(req *http.Request) int {
+	if req.GetBody == nil {
+		return 0
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return 0
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return 0
+	}
+
+	var limits struct {
+		MaxTokens int `json:"max_tokens"`
+		Options   struct {
+			NumPredict int `json:"num_predict"` // Ollama
+		} `json:"options"`
+	}
+	_ = json.Unmarshal(data, &limits)
+	return len(data)/4 + max(limits.MaxTokens, 0) + max(limits.Options.NumPredict, 0)
+}
+
+// RateLimit is a client-side budget per minute. Zero fields are unlimited.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// Limiter paces requests with a token bucket for requests and one for
+// tokens. Each bucket holds a minute's budget and refills continuously.
+// Limits apply within one scmd process.
+type Limiter struct {
+	mu       sync.Mutex
+	requests *bucket
+	tokens   *bucket
+	now      func() time.Time
+}
+
+// NewLimiter returns a limiter for limit, or nil if limit is unlimited
+func NewLimiter(limit RateLimit) *Limiter {
+	if limit.RequestsPerMinute <= 0 && limit.TokensPerMinute <= 0 {
+		return nil
+	}
+	l := &Limiter{now: time.Now}
+	start := l.now()
+	if limit.RequestsPerMinute > 0 {
+		l.requests = newBucket(limit.RequestsPerMinute, start)
+	}
+	if limit.TokensPerMinute > 0 {
+		l.tokens = newBucket(limit.TokensPerMinute, start)
+	}
+	return l
+}
+
+// Reserve takes one request and the given tokens from the budget and
+// returns how long the caller must wait before sending
+func (l *Limiter) Reserve(tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	if l.requests != nil {
+		wait = l.requests.take(1, now)
+	}
+	if l.tokens != nil {
+		wait = max(wait, l.tokens.take(float64(tokens), now))
+	}
+	return wait
+}
+
+// Wait blocks until a request with the given tokens fits the budget. The
+// reservation is kept if ctx is cancelled while waiting.
+func (l *Limiter) Wait(ctx context.Context, tokens int) error {
+	wait := l.Reserve(tokens)
+	if wait <= 0 {
+		return nil
+	}
+
+	if os.Getenv("SCMD_DEBUG") != "" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Rate limit: waiting %s\n", wait.Round(time.Millisecond))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// bucket is a token bucket that may go into debt: a reservation always
+// succeeds and the debt is the time the caller has to wait
+type bucket struct {
+	capacity float64
+	perSec   float64
+	level    float64
+	last     time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	return &bucket{
+		capacity: float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		level:    float64(perMinute),
+		last:     now,
+	}
+}
+
+// take removes n, clamped to the capacity so oversized requests still go
+// through, and returns the wait until the level is back at zero
+func (b *bucket) take(n float64, now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.level = min(b.capacity, b.level+elapsed*b.perSec)
+		b.last = now
+	}
+
+	b.level -= min(n, b.capacity)
+	if b.level >= 0 {
+		return 0
+	}
+	return time.Duration(-b.level / b.perSec * float64(time.Second))
+}
@@ -0,0 +1,213 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func retryClient(policy RetryPolicy) *http.Client {
+	return &http.Client{Transport: NewRetryTransport(policy, nil)}
+}
+
+func TestRetryTransport_RetriesRateLimits(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"prompt":"hi"}`, string(body), "body must be replayed")
+
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	resp, err := retryClient(fastRetries).Post(srv.URL, "application/json", strings.NewReader(`{"prompt":"hi"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestRetryTransport_GivesUp(t *testing.T) {
+	tests := map[string]struct {
+		status   int
+		policy   RetryPolicy
+		header   string
+		attempts int32
+	}{
+		"attempts exhausted": {http.StatusServiceUnavailable, fastRetries, "", 3},
+		"client error":       {http.StatusBadRequest, fastRetries, "", 1},
+		"not implemented":    {http.StatusNotImplemented, fastRetries, "", 1},
+		"disabled":           {http.StatusBadGateway, RetryPolicy{MaxAttempts: 1}, "", 1},
+		"past deadline": {http.StatusTooManyRequests,
+			RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, Deadline: time.Second}, "30", 1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, "error body")
+			}))
+			defer srv.Close()
+
+			resp, err := retryClient(tt.policy).Post(srv.URL, "application/json", strings.NewReader("{}"))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			// The last response is returned intact for the caller's error
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, "error body", string(body))
+			assert.Equal(t, tt.attempts, attempts.Load())
+		})
+	}
+}
+
+func TestRetryTransport_ConnectionReset(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	resp, err := retryClient(fastRetries).Post(srv.URL, "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestRetryTransport_Cancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", srv.URL, strings.NewReader("{}"))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = retryClient(RetryPolicy{MaxAttempts: 3}).Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		header, value string
+		want          time.Duration
+		ok            bool
+	}{
+		{"Retry-After", "7", 7 * time.Second, true},
+		{"Retry-After", "1.5", 1500 * time.Millisecond, true},
+		{"Retry-After", now.Add(20 * time.Second).Format(http.TimeFormat), 20 * time.Second, true},
+		{"Retry-After", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"Retry-After-Ms", "250", 250 * time.Millisecond, true},
+		{"Retry-After", "soon", 0, false},
+		{"Retry-After", "-3", 0, false},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set(tt.header, tt.value)
+		got, ok := retryAfter(h, now)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for i := 0; i < 20; i++ {
+		d := p.backoff(1)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 100*time.Millisecond)
+
+		d = p.backoff(3)
+		assert.GreaterOrEqual(t, d, 200*time.Millisecond)
+		assert.LessOrEqual(t, d, 400*time.Millisecond)
+
+		d = p.backoff(10)
+		assert.LessOrEqual(t, d, time.Second)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	assert.Nil(t, NewLimiter(RateLimit{}))
+
+	now := time.Unix(0, 0)
+	clock := func() time.Time { return now }
+
+	requests := NewLimiter(RateLimit{RequestsPerMinute: 2})
+	requests.now = clock
+	requests.requests.last = now
+	assert.Zero(t, requests.Reserve(0))
+	assert.Zero(t, requests.Reserve(0))
+	assert.Equal(t, 30*time.Second, requests.Reserve(0), "third request waits for a refill")
+
+	tokens := NewLimiter(RateLimit{TokensPerMinute: 600})
+	tokens.now = clock
+	tokens.tokens.last = now
+	assert.Zero(t, tokens.Reserve(500))
+	assert.Equal(t, 20*time.Second, tokens.Reserve(300), "200 tokens short at 10 per second")
+
+	// Oversized requests are clamped to one minute's budget
+	now = now.Add(10 * time.Minute)
+	assert.Zero(t, tokens.Reserve(10_000))
+	assert.Equal(t, time.Minute, tokens.Reserve(10_000))
+}
+
+func TestLimiter_WaitCancelled(t *testing.T) {
+	l := NewLimiter(RateLimit{RequestsPerMinute: 1})
+	require.NoError(t, l.Wait(context.Background(), 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, l.Wait(ctx, 0), context.Canceled)
+}
+
+func TestRequestTokens(t *testing.T) {
+	body := `{"messages":[{"role":"user","content":"hello there"}],"max_tokens":1000}`
+	req, err := http.NewRequest("POST", "http://example.com", strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, len(body)/4+1000, requestTokens(req))
+
+	body = `{"options":{"num_predict":-1}}`
+	req, err = http.NewRequest("POST", "http://example.com", strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, len(body)/4, requestTokens(req))
+
+	req, err = http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	assert.Zero(t, requestTokens(req))
+}
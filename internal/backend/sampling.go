@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sampling holds optional sampling controls. Zero values, and a nil Seed,
+// leave the backend's default in place. Backends drop controls their API
+// has no equivalent for.
+type Sampling struct {
+	TopP             float64 `yaml:"top_p,omitempty" json:"top_p,omitempty"`
+	TopK             int     `yaml:"top_k,omitempty" json:"top_k,omitempty"`
+	MinP             float64 `yaml:"min_p,omitempty" json:"min_p,omitempty"`
+	Seed             *int64  `yaml:"seed,omitempty" json:"seed,omitempty"` // Fixed seed for reproducible output
+	RepeatPenalty    float64 `yaml:"repeat_penalty,omitempty" json:"repeat_penalty,omitempty"`
+	FrequencyPenalty float64 `yaml:"frequency_penalty,omitempty" json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64 `yaml:"presence_penalty,omitempty" json:"presence_penalty,omitempty"`
+
+	// LogitBias maps a token to a bias. OpenAI needs token IDs; llama.cpp
+	// also accepts token text.
+	LogitBias map[string]float64 `yaml:"logit_bias,omitempty" json:"logit_bias,omitempty"`
+}
+
+// IsZero reports whether no control is set
+func (s Sampling) IsZero() bool {
+	return s.TopP == 0 && s.TopK == 0 && s.MinP == 0 && s.Seed == nil &&
+		s.RepeatPenalty == 0 && s.FrequencyPenalty == 0 && s.PresencePenalty == 0 &&
+		len(s.LogitBias) == 0
+}
+
+// Merge returns s with every control set in over taking precedence
+func (s Sampling) Merge(over Sampling) Sampling {
+	if over.TopP != 0 {
+		s.TopP = over.TopP
+	}
+	if over.TopK != 0 {
+		s.TopK = over.TopK
+	}
+	if over.MinP != 0 {
+		s.MinP = over.MinP
+	}
+	if over.Seed != nil {
+		seed := *over.Seed
+		s.Seed = &seed
+	}
+	if over.RepeatPenalty != 0 {
+		s.RepeatPenalty = over.RepeatPenalty
+	}
+	if over.FrequencyPenalty != 0 {
+		s.FrequencyPenalty = over.FrequencyPenalty
+	}
+	if over.PresencePenalty != 0 {
+		s.PresencePenalty = over.PresencePenalty
+	}
+	if len(over.LogitBias) > 0 {
+		bias := make(map[string]float64, len(s.LogitBias)+len(over.LogitBias))
+		for token, v := range s.LogitBias {
+			bias[token] = v
+		}
+		for token, v := range over.LogitBias {
+			bias[token] = v
+		}
+		s.LogitBias = bias
+	}
+	return s
+}
+
+// Validate checks that every control is in range
+func (s Sampling) Validate() error {
+	if s.TopP < 0 || s.TopP > 1 {
+		return fmt.Errorf("top_p must be between 0 and 1, got %g", s.TopP)
+	}
+	if s.MinP < 0 || s.MinP > 1 {
+		return fmt.Errorf("min_p must be between 0 and 1, got %g", s.MinP)
+	}
+	if s.TopK < 0 {
+		return fmt.Errorf("top_k must not be negative, got %d", s.TopK)
+	}
+	if s.RepeatPenalty < 0 {
+		return fmt.Errorf("repeat_penalty must not be negative, got %g", s.RepeatPenalty)
+	}
+	if s.FrequencyPenalty < -2 || s.FrequencyPenalty > 2 {
+		return fmt.Errorf("frequency_penalty must be between -2 and 2, got %g", s.FrequencyPenalty)
+	}
+	if s.PresencePenalty < -2 || s.PresencePenalty > 2 {
+		return fmt.Errorf("presence_penalty must be between -2 and 2, got %g", s.PresencePenalty)
+	}
+	for token, v := range s.LogitBias {
+		if v < -100 || v > 100 {
+			return fmt.Errorf("logit_bias for %q must be between -100 and 100, got %g", token, v)
+		}
+	}
+	return nil
+}
+
+// WithSampling returns b with s applied on top of every request's own
+// sampling controls, e.g. for command-line overrides. b is returned
+// unchanged when s is zero.
+func WithSampling(b Backend, s Sampling) Backend {
+	if s.IsZero() {
+		return b
+	}
+	return &samplingBackend{Backend: b, sampling: s}
+}
+
+// samplingBackend overrides the sampling controls of each request
+type samplingBackend struct {
+	Backend
+	sampling Sampling
+}
+
+// Unwrap returns the wrapped backend
+func (b *samplingBackend) Unwrap() Backend {
+	return b.Backend
+}
+
+// Complete runs the completion with the overrides applied
+func (b *samplingBackend) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	r := *req
+	r.Sampling = r.Sampling.Merge(b.sampling)
+	return b.Backend.Complete(ctx, &r)
+}
+
+// Stream runs the completion with the overrides applied
+func (b *samplingBackend) Stream(ctx context.Context, req *CompletionRequest) (<-chan StreamChunk, error) {
+	r := *req
+	r.Sampling = r.Sampling.Merge(b.sampling)
+	return b.Backend.Stream(ctx, &r)
+}
+
+// CompleteWithTools runs the tool completion with the overrides applied
+func (b *samplingBackend) CompleteWithTools(ctx context.Context, req *ToolRequest) (*ToolResponse, error) {
+	r := *req
+	r.Sampling = r.Sampling.Merge(b.sampling)
+	return b.Backend.CompleteWithTools(ctx, &r)
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampling_Merge(t *testing.T) {
+	seed := int64(1)
+	base := Sampling{TopP: 0.9, TopK: 40, LogitBias: map[string]float64{"a": 1, "b": 2}}
+	over := Sampling{TopK: 10, Seed: &seed, LogitBias: map[string]float64{"b": -2}}
+
+	merged := base.Merge(over)
+	assert.Equal(t, 0.9, merged.TopP)
+	assert.Equal(t, 10, merged.TopK)
+	require.NotNil(t, merged.Seed)
+	assert.Equal(t, int64(1), *merged.Seed)
+	assert.Equal(t, map[string]float64{"a": 1, "b": -2}, merged.LogitBias)
+	assert.Equal(t, map[string]float64{"a": 1, "b": 2}, base.LogitBias, "base is not modified")
+
+	assert.True(t, Sampling{}.IsZero())
+	assert.False(t, over.IsZero())
+}
+
+func TestSampling_Validate(t *testing.T) {
+	assert.NoError(t, Sampling{TopP: 1, MinP: 0.1, TopK: 50, FrequencyPenalty: -2}.Validate())
+	assert.ErrorContains(t, Sampling{TopP: 1.5}.Validate(), "top_p")
+	assert.ErrorContains(t, Sampling{TopK: -1}.Validate(), "top_k")
+	assert.ErrorContains(t, Sampling{PresencePenalty: 3}.Validate(), "presence_penalty")
+	assert.ErrorContains(t, Sampling{LogitBias: map[string]float64{"x": 200}}.Validate(), "logit_bias")
+}
+
+type recordingBackend struct {
+	testBackend
+	last *CompletionRequest
+}
+
+func (b *recordingBackend) Complete(_ context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	b.last = req
+	return &CompletionResponse{}, nil
+}
+
+func TestWithSampling(t *testing.T) {
+	inner := &recordingBackend{testBackend: testBackend{name: "inner"}}
+	assert.Same(t, Backend(inner), WithSampling(inner, Sampling{}))
+
+	seed := int64(3)
+	b := WithSampling(inner, Sampling{Seed: &seed})
+	req := &CompletionRequest{Prompt: "hi", Sampling: Sampling{TopP: 0.5}}
+	_, err := b.Complete(context.Background(), req)
+	require.NoError(t, err)
+
+	require.NotNil(t, inner.last.Seed)
+	assert.Equal(t, int64(3), *inner.last.Seed)
+	assert.Equal(t, 0.5, inner.last.TopP)
+	assert.Nil(t, req.Seed, "caller's request is not modified")
+	assert.Same(t, Backend(inner), b.(Unwrapper).Unwrap())
+}
@@ -0,0 +1,205 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResponseFormatType selects how a response is constrained
+type ResponseFormatType string
+
+const (
+	// ResponseJSON asks for any JSON object
+	ResponseJSON ResponseFormatType = "json_object"
+	// ResponseJSONSchema asks for a JSON object matching Schema
+	ResponseJSONSchema ResponseFormatType = "json_schema"
+)
+
+// ResponseFormat constrains a completion to JSON. OpenAI maps it to
+// response_format, Ollama to format and llama-server to a grammar or
+// json_schema; other backends only get the validate-and-retry loop in
+// CompleteStructured.
+type ResponseFormat struct {
+	Type   ResponseFormatType
+	Name   string                 // Schema name, required by OpenAI for json_schema
+	Schema map[string]interface{} // JSON Schema for ResponseJSONSchema
+}
+
+// Validate checks that content is JSON satisfying the format and returns
+// the decoded value
+func (f *ResponseFormat) Validate(content string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(ExtractJSON(content)), &value); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("response is not a JSON object")
+	}
+	if f.Type == ResponseJSONSchema && f.Schema != nil {
+		if err := validateSchema(f.Schema, value, "$"); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// Instructions describes the format in words, for backends that have no
+// native way to enforce it
+func (f *ResponseFormat) Instructions() string {
+	if f.Type == ResponseJSONSchema && f.Schema != nil {
+		schema, _ := json.Marshal(f.Schema)
+		return "Respond with only a JSON object matching this JSON Schema, with no other text:\n" + string(schema)
+	}
+	return "Respond with only a JSON object, with no other text."
+}
+
+// ExtractJSON strips Markdown code fences and surrounding prose that
+// models often wrap around JSON output
+func ExtractJSON(content string) string {
+	s := strings.TrimSpace(content)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+		s = strings.TrimSpace(s)
+	}
+	if start, end := strings.Index(s, "{"), strings.LastIndex(s, "}"); start >= 0 && end > start {
+		s = s[start : end+1]
+	}
+	return s
+}
+
+// validateSchema checks value against the subset of JSON Schema scmd
+// uses: type, properties, required, items and enum
+func validateSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v not in enum", path, value)
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sub, ok := props[name].(map[string]interface{})
+			v, present := obj[name]
+			if !ok || !present {
+				continue
+			}
+			if err := validateSchema(sub, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, v := range arr {
+				if err := validateSchema(items, v, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "null":
+		if value != nil {
+			return fmt.Errorf("%s: expected null", path)
+		}
+	}
+	return nil
+}
+
+// stringList converts a decoded or literal JSON array of strings
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// CompleteStructured runs a completion with req.ResponseFormat set and
+// validates the result, retrying up to retries times with the validation
+// error fed back to the model. The returned content is the extracted JSON.
+func CompleteStructured(ctx context.Context, b Backend, req *CompletionRequest, retries int) (*CompletionResponse, error) {
+	if req.ResponseFormat == nil {
+		return b.Complete(ctx, req)
+	}
+
+	attempt := *req
+	attempt.Messages = req.ConversationMessages()
+	attempt.Prompt = ""
+
+	var lastErr error
+	for i := 0; i <= retries; i++ {
+		resp, err := b.Complete(ctx, &attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, lastErr = req.ResponseFormat.Validate(resp.Content); lastErr == nil {
+			resp.Content = ExtractJSON(resp.Content)
+			return resp, nil
+		}
+
+		attempt.Messages = append(attempt.Messages,
+			Message{Role: RoleAssistant, Content: resp.Content},
+			Message{Role: RoleUser, Content: fmt.Sprintf(
+				"That response was rejected: %v. %s", lastErr, req.ResponseFormat.Instructions())},
+		)
+	}
+
+	return nil, fmt.Errorf("structured output failed after %d attempts: %w", retries+1, lastErr)
+}
@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var reviewSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"summary": map[string]interface{}{"type": "string"},
+		"score":   map[string]interface{}{"type": "integer"},
+		"severity": map[string]interface{}{
+			"type": "string",
+			"enum": []interface{}{"low", "high"},
+		},
+		"issues": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		},
+	},
+	"required": []interface{}{"summary", "score"},
+}
+
+func TestResponseFormat_Validate(t *testing.T) {
+	format := &ResponseFormat{Type: ResponseJSONSchema, Schema: reviewSchema}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", `{"summary":"ok","score":3,"issues":["a"]}`, ""},
+		{"code fence", "```json\n{\"summary\":\"ok\",\"score\":3}\n```", ""},
+		{"surrounding prose", "Here you go: {\"summary\":\"ok\",\"score\":3} Hope that helps", ""},
+		{"not json", "looks fine to me", "not valid JSON"},
+		{"not an object", `["a"]`, "not a JSON object"},
+		{"missing required", `{"summary":"ok"}`, `missing required property "score"`},
+		{"wrong type", `{"summary":"ok","score":"high"}`, "$.score: expected integer"},
+		{"fractional integer", `{"summary":"ok","score":2.5}`, "$.score: expected integer"},
+		{"bad item", `{"summary":"ok","score":1,"issues":[1]}`, "$.issues[0]: expected string"},
+		{"enum", `{"summary":"ok","score":1,"severity":"medium"}`, "not in enum"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := format.Validate(tt.content)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestResponseFormat_Validate_AnyObject(t *testing.T) {
+	format := &ResponseFormat{Type: ResponseJSON}
+
+	_, err := format.Validate(`{"anything":true}`)
+	assert.NoError(t, err)
+	_, err = format.Validate(`plain text`)
+	assert.Error(t, err)
+}
+
+// scriptedBackend returns canned responses in order and records requests
+type scriptedBackend struct {
+	testBackend
+	responses []string
+	requests  []*CompletionRequest
+}
+
+func (b *scriptedBackend) Complete(_ context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	b.requests = append(b.requests, req)
+	resp := b.responses[0]
+	b.responses = b.responses[1:]
+	return &CompletionResponse{Content: resp}, nil
+}
+
+func TestCompleteStructured_Retry(t *testing.T) {
+	b := &scriptedBackend{responses: []string{
+		`{"summary":"ok"}`,
+		"```json\n{\"summary\":\"ok\",\"score\":4}\n```",
+	}}
+
+	req := &CompletionRequest{
+		Prompt:         "review this",
+		ResponseFormat: &ResponseFormat{Type: ResponseJSONSchema, Schema: reviewSchema},
+	}
+	resp, err := CompleteStructured(context.Background(), b, req, 2)
+	require.NoError(t, err)
+	assert.Equal(t, `{"summary":"ok","score":4}`, resp.Content)
+
+	require.Len(t, b.requests, 2)
+	retry := b.requests[1].Messages
+	require.Len(t, retry, 3)
+	assert.Equal(t, RoleAssistant, retry[1].Role)
+	assert.Contains(t, retry[2].Content, `missing required property "score"`)
+	assert.Same(t, req.ResponseFormat, b.requests[1].ResponseFormat)
+}
+
+func TestCompleteStructured_GivesUp(t *testing.T) {
+	b := &scriptedBackend{responses: []string{"no", "still no"}}
+
+	req := &CompletionRequest{Prompt: "hi", ResponseFormat: &ResponseFormat{Type: ResponseJSON}}
+	_, err := CompleteStructured(context.Background(), b, req, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "after 2 attempts")
+}
+
+func TestCompleteStructured_NoFormat(t *testing.T) {
+	b := &scriptedBackend{responses: []string{"free text"}}
+
+	resp, err := CompleteStructured(context.Background(), b, &CompletionRequest{Prompt: "hi"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "free text", resp.Content)
+}
@@ -0,0 +1,198 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+)
+
+// defaultCompletionEstimate stands in for the completion length when a
+// request sets no MaxTokens
+const defaultCompletionEstimate = 1024
+
+// Options configures a usage-tracking backend
+type Options struct {
+	Command string     // Recorded with each entry, e.g. "explain"
+	Prices  PriceTable // Prices for computing cost
+	Budget  Budget     // Checked before priced requests
+	Warn    io.Writer  // Budget warnings; default os.Stderr
+}
+
+// Backend wraps another backend and logs the tokens and cost of every
+// successful completion to a Store. Requests to priced backends are
+// checked against the budget first.
+type Backend struct {
+	backend.Backend
+	store *Store
+	opts  Options
+}
+
+// Wrap returns b with usage logged to store
+func Wrap(b backend.Backend, store *Store, opts Options) *Backend {
+	if opts.Warn == nil {
+		opts.Warn = os.Stderr
+	}
+	return &Backend{Backend: b, store: store, opts: opts}
+}
+
+// Unwrap returns the wrapped backend
+func (b *Backend) Unwrap() backend.Backend {
+	return b.Backend
+}
+
+// model returns the name of the model currently in use
+func (b *Backend) model() string {
+	if info := b.Backend.ModelInfo(); info != nil {
+		return info.Name
+	}
+	return ""
+}
+
+// price looks up the price of the current model
+func (b *Backend) price() (Price, bool) {
+	return b.opts.Prices.Lookup(b.Backend.Name(), b.model())
+}
+
+// promptTokens estimates the tokens a request sends
+func (b *Backend) promptTokens(req *backend.CompletionRequest) int {
+	var text strings.Builder
+	text.WriteString(req.SystemPrompt)
+	if len(req.Messages) > 0 {
+		for _, m := range req.Messages {
+			text.WriteString(m.Content)
+		}
+	} else {
+		text.WriteString(req.Prompt)
+	}
+	return b.Backend.EstimateTokens(text.String())
+}
+
+// checkBudget estimates the cost of req and compares it with the budget.
+// Unpriced backends are never limited.
+func (b *Backend) checkBudget(req *backend.CompletionRequest) error {
+	if b.opts.Budget.IsZero() {
+		return nil
+	}
+	price, ok := b.price()
+	if !ok {
+		return nil
+	}
+
+	completion := req.MaxTokens
+	if completion <= 0 {
+		completion = defaultCompletionEstimate
+	}
+	err := b.opts.Budget.Check(b.store, price.Cost(b.promptTokens(req), completion))
+	if err == nil {
+		return nil
+	}
+	if b.opts.Budget.Refuse {
+		return err
+	}
+	fmt.Fprintf(b.opts.Warn, "Warning: %v\n", err)
+	return nil
+}
+
+// record logs a finished request; logging failures never fail the request
+func (b *Backend) record(promptTokens, completionTokens int) {
+	e := Entry{
+		Backend:          b.Backend.Name(),
+		Model:            b.model(),
+		Command:          b.opts.Command,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+	}
+	if price, ok := b.price(); ok {
+		e.Cost = price.Cost(promptTokens, completionTokens)
+		e.Priced = true
+	}
+	if err := b.store.Record(e); err != nil && os.Getenv("SCMD_DEBUG") != "" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] usage: %v\n", err)
+	}
+}
+
+// Complete checks the budget, runs the completion and logs its usage
+func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	if err := b.checkBudget(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := b.Backend.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, completion := resp.PromptTokens, resp.CompletionTokens
+	if prompt == 0 && completion == 0 {
+		prompt = b.promptTokens(req)
+		completion = b.Backend.EstimateTokens(resp.Content)
+	}
+	b.record(prompt, completion)
+	return resp, nil
+}
+
+// Stream checks the budget and logs usage from the final chunk, falling
+// back to an estimate when the backend doesn't report it. Streams that
+// end in an error or are cancelled are not logged.
+func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<-chan backend.StreamChunk, error) {
+	if err := b.checkBudget(req); err != nil {
+		return nil, err
+	}
+
+	upstream, err := b.Backend.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan backend.StreamChunk)
+	go func() {
+		defer close(ch)
+
+		var content strings.Builder
+		for chunk := range upstream {
+			content.WriteString(chunk.Content)
+
+			if chunk.Done && chunk.Error == nil {
+				if chunk.Usage != nil {
+					b.record(chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens)
+				} else {
+					b.record(b.promptTokens(req), b.Backend.EstimateTokens(content.String()))
+				}
+			}
+
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				// Drain so the upstream goroutine can exit
+				for range upstream {
+				}
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// CompleteWithTools checks the budget, runs the tool turn and logs its usage
+func (b *Backend) CompleteWithTools(ctx context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	if err := b.checkBudget(&req.CompletionRequest); err != nil {
+		return nil, err
+	}
+
+	resp, err := b.Backend.CompleteWithTools(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Usage != nil {
+		b.record(resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	} else {
+		b.record(b.promptTokens(&req.CompletionRequest), b.Backend.EstimateTokens(resp.Content))
+	}
+	return resp, nil
+}
@@ -0,0 +1,77 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+)
+
+var mockPrices = PriceTable{{Backend: "mock", Price: Price{Input: 1000, Output: 1000}}}
+
+func TestBackend_RecordsUsage(t *testing.T) {
+	s := openTestStore(t)
+	m := mock.New()
+	m.SetResponse("0123456789012345678901234567890123456789")
+	b := Wrap(m, s, Options{Command: "explain", Prices: mockPrices})
+
+	_, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+
+	ch, err := b.Stream(context.Background(), &backend.CompletionRequest{Prompt: "explain this please"})
+	require.NoError(t, err)
+	for range ch {
+	}
+
+	rows, err := s.Report(time.Time{}, ByCommand)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "explain", rows[0].Key)
+	assert.Equal(t, 2, rows[0].Requests)
+	assert.Equal(t, 4, rows[0].PromptTokens, "stream without usage falls back to the estimate")
+	assert.Equal(t, 20, rows[0].CompletionTokens)
+	assert.InDelta(t, 0.024, rows[0].Cost, 1e-9)
+}
+
+func TestBackend_SkipsFailedRequests(t *testing.T) {
+	s := openTestStore(t)
+	m := mock.New()
+	m.SetError(assert.AnError)
+	b := Wrap(m, s, Options{Prices: mockPrices})
+
+	_, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.Error(t, err)
+
+	rows, err := s.Report(time.Time{}, ByBackend)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestBackend_Budget(t *testing.T) {
+	s := openTestStore(t)
+	require.NoError(t, s.Record(Entry{Backend: "mock", Cost: 0.99, Priced: true}))
+	req := &backend.CompletionRequest{Prompt: "hi", MaxTokens: 100}
+
+	var warn bytes.Buffer
+	b := Wrap(mock.New(), s, Options{Prices: mockPrices, Budget: Budget{Daily: 1}, Warn: &warn})
+	_, err := b.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Contains(t, warn.String(), "daily budget")
+
+	b = Wrap(mock.New(), s, Options{Prices: mockPrices, Budget: Budget{Daily: 1, Refuse: true}})
+	_, err = b.Complete(context.Background(), req)
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	_, err = b.Stream(context.Background(), req)
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+
+	// Unpriced backends are never refused
+	b = Wrap(mock.New(), s, Options{Budget: Budget{Daily: 1, Refuse: true}})
+	_, err = b.Complete(context.Background(), req)
+	assert.NoError(t, err)
+}
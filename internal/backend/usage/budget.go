@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the cost of a completion in USD
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
+
+// PriceEntry prices one model of a backend. An empty or "*" model
+// matches every model of the backend.
+type PriceEntry struct {
+	Backend string
+	Model   string
+	Price
+}
+
+// PriceTable finds prices by backend and model
+type PriceTable []PriceEntry
+
+// Lookup returns the price for model on backend. An exact model match
+// wins over a wildcard; names are compared case-insensitively.
+func (t PriceTable) Lookup(backendName, model string) (Price, bool) {
+	var wildcard *Price
+	for i := range t {
+		e := &t[i]
+		if !strings.EqualFold(e.Backend, backendName) {
+			continue
+		}
+		if strings.EqualFold(e.Model, model) && e.Model != "" {
+			return e.Price, true
+		}
+		if (e.Model == "" || e.Model == "*") && wildcard == nil {
+			wildcard = &e.Price
+		}
+	}
+	if wildcard != nil {
+		return *wildcard, true
+	}
+	return Price{}, false
+}
+
+// ErrBudgetExceeded is returned for requests refused by a budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Budget limits spending per calendar day and month, in local time.
+// Zero limits are off.
+type Budget struct {
+	Daily   float64
+	Monthly float64
+	Refuse  bool // Refuse requests over budget instead of warning
+}
+
+// IsZero reports whether no limit is set
+func (b Budget) IsZero() bool {
+	return b.Daily <= 0 && b.Monthly <= 0
+}
+
+// periods returns the budget periods that have a limit, with their start
+func (b Budget) periods(now time.Time) []period {
+	var ps []period
+	if b.Daily > 0 {
+		y, m, d := now.Date()
+		ps = append(ps, period{"daily", b.Daily, time.Date(y, m, d, 0, 0, 0, 0, now.Location())})
+	}
+	if b.Monthly > 0 {
+		y, m, _ := now.Date()
+		ps = append(ps, period{"monthly", b.Monthly, time.Date(y, m, 1, 0, 0, 0, 0, now.Location())})
+	}
+	return ps
+}
+
+type period struct {
+	name  string
+	limit float64
+	start time.Time
+}
+
+// Check returns an error describing the first period whose limit the
+// estimated cost would push spending past
+func (b Budget) Check(s *Store, estimate float64) error {
+	for _, p := range b.periods(s.now()) {
+		spent, err := s.Spent(p.start)
+		if err != nil {
+			return err
+		}
+		if spent+estimate > p.limit {
+			return fmt.Errorf("%w: %s budget $%.2f, spent $%.4f, this request est. $%.4f",
+				ErrBudgetExceeded, p.name, p.limit, spent, estimate)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package usage logs token usage and cost per completion and enforces
+// spending budgets
+package usage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Entry is one logged completion
+type Entry struct {
+	Time             time.Time
+	Backend          string
+	Model            string
+	Command          string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	Priced           bool // False when no price was known; Cost is then zero
+}
+
+// Store keeps the usage log in the scmd SQLite database
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// Open opens the usage table in dataDir/conversations.db, next to chat
+// history and the completion cache
+func Open(dataDir string) (*Store, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(dataDir, "conversations.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS usage_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at INTEGER NOT NULL,
+		backend TEXT NOT NULL,
+		model TEXT,
+		command TEXT,
+		prompt_tokens INTEGER NOT NULL,
+		completion_tokens INTEGER NOT NULL,
+		cost REAL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_usage_log_created ON usage_log(created_at);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize usage schema: %w", err)
+	}
+
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record appends an entry. A zero Time means now.
+func (s *Store) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = s.now()
+	}
+
+	// Unpriced entries store NULL so reports can tell them from free ones
+	var cost interface{}
+	if e.Priced {
+		cost = e.Cost
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO usage_log (created_at, backend, model, command, prompt_tokens, completion_tokens, cost)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.Time.Unix(), e.Backend, e.Model, e.Command, e.PromptTokens, e.CompletionTokens, cost)
+	if err != nil {
+		return fmt.Errorf("record usage: %w", err)
+	}
+	return nil
+}
+
+// Spent returns the total cost logged since t
+func (s *Store) Spent(since time.Time) (float64, error) {
+	var total float64
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(cost), 0) FROM usage_log WHERE created_at >= ?`, since.Unix()).
+		Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("query usage: %w", err)
+	}
+	return total, nil
+}
+
+// GroupBy selects the column a report is grouped by
+type GroupBy string
+
+const (
+	ByDay     GroupBy = "day"
+	ByCommand GroupBy = "command"
+	ByBackend GroupBy = "backend"
+	ByModel   GroupBy = "model"
+)
+
+// groupColumns maps each grouping to its SQL expression. Days are in
+// local time, like the budget periods.
+var groupColumns = map[GroupBy]string{
+	ByDay:     `date(created_at, 'unixepoch', 'localtime')`,
+	ByCommand: `COALESCE(NULLIF(command, ''), '-')`,
+	ByBackend: `backend`,
+	ByModel:   `COALESCE(NULLIF(model, ''), '-')`,
+}
+
+// ParseGroupBy validates a grouping name
+func ParseGroupBy(s string) (GroupBy, error) {
+	g := GroupBy(s)
+	if _, ok := groupColumns[g]; !ok {
+		return "", fmt.Errorf("invalid grouping %q: must be one of day, command, backend, model", s)
+	}
+	return g, nil
+}
+
+// Row is one group in a usage report
+type Row struct {
+	Key              string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	Unpriced         int // Requests logged without a known price
+}
+
+// Report sums usage since t, grouped by g and ordered by key
+func (s *Store) Report(since time.Time, g GroupBy) ([]Row, error) {
+	column, ok := groupColumns[g]
+	if !ok {
+		return nil, fmt.Errorf("invalid grouping %q", g)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+column+` AS k, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens),
+			COALESCE(SUM(cost), 0), COUNT(*) - COUNT(cost)
+		FROM usage_log
+		WHERE created_at >= ?
+		GROUP BY k
+		ORDER BY k
+	`, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var report []Row
+	for rows.Next() {
+		var r Row
+		if err := rows.Scan(&r.Key, &r.Requests, &r.PromptTokens, &r.CompletionTokens, &r.Cost, &r.Unpriced); err != nil {
+			return nil, fmt.Errorf("query usage: %w", err)
+		}
+		report = append(report, r)
+	}
+	return report, rows.Err()
+}
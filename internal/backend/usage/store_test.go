@@ -0,0 +1,107 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_Report(t *testing.T) {
+	s := openTestStore(t)
+	day1 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	for _, e := range []Entry{
+		{Time: day1, Backend: "openai", Model: "gpt-4o-mini", Command: "explain", PromptTokens: 100, CompletionTokens: 50, Cost: 0.01, Priced: true},
+		{Time: day1, Backend: "openai", Model: "gpt-4o-mini", Command: "review", PromptTokens: 200, CompletionTokens: 20, Cost: 0.02, Priced: true},
+		{Time: day2, Backend: "ollama", Model: "qwen3", Command: "explain", PromptTokens: 10, CompletionTokens: 5},
+	} {
+		require.NoError(t, s.Record(e))
+	}
+
+	byBackend, err := s.Report(day1.Add(-time.Hour), ByBackend)
+	require.NoError(t, err)
+	require.Len(t, byBackend, 2)
+	assert.Equal(t, Row{Key: "ollama", Requests: 1, PromptTokens: 10, CompletionTokens: 5, Unpriced: 1}, byBackend[0])
+	assert.Equal(t, "openai", byBackend[1].Key)
+	assert.Equal(t, 2, byBackend[1].Requests)
+	assert.Equal(t, 300, byBackend[1].PromptTokens)
+	assert.InDelta(t, 0.03, byBackend[1].Cost, 1e-9)
+
+	byDay, err := s.Report(day1.Add(-time.Hour), ByDay)
+	require.NoError(t, err)
+	require.Len(t, byDay, 2)
+	assert.Equal(t, "2026-03-01", byDay[0].Key)
+	assert.Equal(t, "2026-03-02", byDay[1].Key)
+
+	byCommand, err := s.Report(day2, ByCommand)
+	require.NoError(t, err)
+	require.Len(t, byCommand, 1, "since excludes older entries")
+	assert.Equal(t, "explain", byCommand[0].Key)
+
+	spent, err := s.Spent(day1.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.03, spent, 1e-9)
+}
+
+func TestParseGroupBy(t *testing.T) {
+	g, err := ParseGroupBy("model")
+	require.NoError(t, err)
+	assert.Equal(t, ByModel, g)
+
+	_, err = ParseGroupBy("week")
+	assert.Error(t, err)
+}
+
+func TestPriceTable_Lookup(t *testing.T) {
+	table := PriceTable{
+		{Backend: "openai", Model: "*", Price: Price{Input: 5, Output: 15}},
+		{Backend: "openai", Model: "gpt-4o-mini", Price: Price{Input: 0.15, Output: 0.6}},
+		{Backend: "claude", Price: Price{Input: 3, Output: 15}},
+	}
+
+	p, ok := table.Lookup("openai", "GPT-4o-mini")
+	require.True(t, ok)
+	assert.Equal(t, 0.15, p.Input, "exact model beats the wildcard")
+
+	p, ok = table.Lookup("openai", "gpt-4.1")
+	require.True(t, ok)
+	assert.Equal(t, 5.0, p.Input)
+
+	_, ok = table.Lookup("claude", "claude-sonnet-4")
+	assert.True(t, ok)
+
+	_, ok = table.Lookup("ollama", "qwen3")
+	assert.False(t, ok)
+
+	assert.InDelta(t, 0.00045, Price{Input: 0.15, Output: 0.6}.Cost(1000, 500), 1e-12)
+}
+
+func TestBudget_Check(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Record(Entry{Time: now.Add(-time.Hour), Backend: "openai", Cost: 0.8, Priced: true}))
+	require.NoError(t, s.Record(Entry{Time: now.AddDate(0, 0, -3), Backend: "openai", Cost: 4, Priced: true}))
+
+	assert.NoError(t, Budget{Daily: 1}.Check(s, 0.1))
+	assert.ErrorIs(t, Budget{Daily: 1}.Check(s, 0.3), ErrBudgetExceeded)
+
+	assert.NoError(t, Budget{Monthly: 5}.Check(s, 0.1))
+	err := Budget{Daily: 10, Monthly: 5}.Check(s, 0.3)
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Contains(t, err.Error(), "monthly")
+
+	assert.True(t, Budget{}.IsZero())
+}
@@ -28,6 +28,10 @@ type Session struct {
 	backend        backend.Backend
 	db             *ConversationStore
 	config         *Config
+
+	// Token totals reported by the backend for this session
+	promptTokens     int
+	completionTokens int
 }
 
 // Config holds chat configuration
@@ -94,10 +98,32 @@ func (s *Session) Run(ctx context.Context) error {
 		fmt.Printf("Resumed with %d previous messages\n\n", len(s.messages))
 	}
 
+	// Read lines in the background so Ctrl-C (ctx) can end the session
+	// while it waits for input
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+
 	for {
 		fmt.Print("You: ")
-		input, err := reader.ReadString('\n')
-		if err != nil {
+		var input string
+		var ok bool
+		select {
+		case input, ok = <-lines:
+		case <-ctx.Done():
+			fmt.Printf("\nConversation saved. Use 'scmd chat --continue %s' to resume.\n",
+				s.conversationID[:8])
+			return ctx.Err()
+		}
+		if !ok {
 			// EOF (Ctrl+D)
 			fmt.Printf("\nConversation saved. Use 'scmd chat --continue %s' to resume.\n",
 				s.conversationID[:8])
@@ -123,14 +149,19 @@ func (s *Session) Run(ctx context.Context) error {
 			continue
 		}
 
-		// Add user message
-		s.addMessage("user", input)
+		// Add user message, counted with the backend's tokenizer
+		s.addMessageWithTokens("user", input, s.backend.EstimateTokens(input))
 
 		// Show thinking indicator
 		fmt.Print("\nAssistant: ")
 
 		// Generate response with full context
 		response, tokens, err := s.generateResponse(ctx)
+		if err != nil && ctx.Err() != nil {
+			fmt.Printf("\n\nConversation saved. Use 'scmd chat --continue %s' to resume.\n",
+				s.conversationID[:8])
+			return ctx.Err()
+		}
 		if err != nil {
 			fmt.Printf("\nError: %v\n\n", err)
 			continue
@@ -210,13 +241,10 @@ Conversation Info:
   Model:        %s
   Backend:      %s
   Messages:     %d
+  Tokens:       %d prompt, %d completion
   Started:      %s
 `, s.conversationID[:8], s.model, s.backend.Name(), len(s.messages),
-		createdAt.Format("2006-01-02 15:04"))
-}
-
-func (s *Session) addMessage(role, content string) {
-	s.addMessageWithTokens(role, content, 0)
+		s.promptTokens, s.completionTokens, createdAt.Format("2006-01-02 15:04"))
 }
 
 func (s *Session) addMessageWithTokens(role, content string, tokens int) {
@@ -230,12 +258,9 @@ func (s *Session) addMessageWithTokens(role, content string, tokens int) {
 }
 
 func (s *Session) generateResponse(ctx context.Context) (string, int, error) {
-	// Build prompt from message history
-	prompt := s.buildPrompt()
-
-	// Call LLM backend
+	// Call LLM backend with the role-structured message history
 	req := &backend.CompletionRequest{
-		Prompt:      prompt,
+		Messages:    s.buildMessages(),
 		MaxTokens:   4096,
 		Temperature: 0.7,
 	}
@@ -245,26 +270,34 @@ func (s *Session) generateResponse(ctx context.Context) (string, int, error) {
 		return "", 0, err
 	}
 
-	return resp.Content, resp.TokensUsed, nil
+	s.promptTokens += resp.PromptTokens
+	s.completionTokens += resp.CompletionTokens
+
+	// The reply's own size; the prompt count covers the whole history
+	tokens := resp.CompletionTokens
+	if tokens == 0 {
+		tokens = s.backend.EstimateTokens(resp.Content)
+	}
+
+	return resp.Content, tokens, nil
 }
 
-func (s *Session) buildPrompt() string {
+func (s *Session) buildMessages() []backend.Message {
 	// Build context from message history
-	var sb strings.Builder
-
-	messages := s.getContextMessages()
-	for _, msg := range messages {
-		role := "User"
+	history := s.getContextMessages()
+	messages := make([]backend.Message, 0, len(history))
+	for _, msg := range history {
+		role := backend.RoleUser
 		if msg.Role == "assistant" {
-			role = "Assistant"
+			role = backend.RoleAssistant
 		}
-		sb.WriteString(fmt.Sprintf("%s: %s\n\n", role, msg.Content))
+		messages = append(messages, backend.Message{
+			Role:    role,
+			Content: msg.Content,
+		})
 	}
 
-	// Add instruction for the next response
-	sb.WriteString("Assistant: ")
-
-	return sb.String()
+	return messages
 }
 
 func (s *Session) getContextMessages() []Message {
@@ -323,6 +356,11 @@ func (s *Session) GetMessages() []Message {
 	return s.messages
 }
 
+// TokenUsage returns the prompt and completion tokens used by this session
+func (s *Session) TokenUsage() (prompt, completion int) {
+	return s.promptTokens, s.completionTokens
+}
+
 // GetModel returns the model name
 func (s *Session) GetModel() string {
 	return s.model
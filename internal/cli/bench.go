@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scmd/scmd/internal/backend/llamacpp"
+)
+
+// modelsBenchCmd measures local model throughput
+var modelsBenchCmd = &cobra.Command{
+	Use:   "bench [model...]",
+	Short: "Benchmark local models",
+	Long: `Run a fixed prompt suite against downloaded models and report prompt
+and generation speed, time to first token and peak memory.
+
+Each model is run with several llama-server configurations: thread counts
+and context sizes are swept around the settings scmd would pick on this
+machine. Without arguments every downloaded model is benchmarked.
+
+Results are saved to ~/.scmd/bench.json together with a description of
+the machine, so runs on different machines can be compared.
+
+Examples:
+  scmd models bench                          # all downloaded models
+  scmd models bench qwen3-4b --threads 4,8   # one model, two thread counts
+  scmd models bench --context 2048,8192 --max-tokens 64
+  scmd models bench --results --json         # stored results as JSON`,
+	RunE: runModelsBench,
+}
+
+func init() {
+	modelsBenchCmd.Flags().IntSlice("threads", nil, "thread counts to try (default: half and all CPUs)")
+	modelsBenchCmd.Flags().IntSlice("context", nil, "context sizes to try (default: 4096 and the model's native size up to 32768)")
+	modelsBenchCmd.Flags().Int("max-tokens", 128, "tokens to generate per prompt")
+	modelsBenchCmd.Flags().Int("port", llamacpp.DefaultBenchPort, "port for the benchmark server")
+	modelsBenchCmd.Flags().Bool("results", false, "show stored results instead of running")
+	modelsBenchCmd.Flags().Bool("json", false, "output results as JSON")
+}
+
+// benchTarget is a downloaded model to benchmark
+type benchTarget struct {
+	name string
+	path string
+}
+
+func runModelsBench(cmd *cobra.Command, args []string) error {
+	dataDir := getDataDir()
+	threads, _ := cmd.Flags().GetIntSlice("threads")
+	contexts, _ := cmd.Flags().GetIntSlice("context")
+	maxTokens, _ := cmd.Flags().GetInt("max-tokens")
+	port, _ := cmd.Flags().GetInt("port")
+	showResults, _ := cmd.Flags().GetBool("results")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	if showResults {
+		results, err := llamacpp.LoadBenchResults(dataDir)
+		if err != nil {
+			return err
+		}
+		return printBenchResults(filterBenchResults(results, args), asJSON)
+	}
+
+	targets, err := benchTargets(llamacpp.NewModelManager(dataDir), args)
+	if err != nil {
+		return err
+	}
+
+	ctx := commandContext()
+
+	if isServerRunning() {
+		fmt.Fprintln(os.Stderr, "Note: llama-server is running and shares the machine; stop it with 'scmd server stop' for steadier numbers")
+	}
+
+	var results []llamacpp.BenchResult
+	for _, target := range targets {
+		for _, config := range llamacpp.BenchConfigs(target.path, threads, contexts) {
+			config.Port = port
+			fmt.Fprintf(os.Stderr, "Benchmarking %s (threads %d, context %d)...\n", target.name, config.Threads, config.ContextSize)
+
+			result, err := llamacpp.Bench(ctx, config, maxTokens)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", target.name, err)
+				continue
+			}
+			result.Model = target.name
+			if err := llamacpp.SaveBenchResult(dataDir, result); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not save result: %v\n", err)
+			}
+			results = append(results, *result)
+		}
+	}
+
+	if len(results) == 0 {
+		return fmt.Errorf("no benchmark completed")
+	}
+	if err := printBenchResults(results, asJSON); err != nil {
+		return err
+	}
+	if !asJSON {
+		fmt.Println()
+		fmt.Println("Saved to ~/.scmd/bench.json; view again with: scmd models bench --results")
+	}
+	return nil
+}
+
+// benchTargets resolves the models to benchmark; without names, every
+// downloaded model. Models are never downloaded for a benchmark.
+func benchTargets(mgr *llamacpp.ModelManager, names []string) ([]benchTarget, error) {
+	if len(names) == 0 {
+		files, err := mgr.ListDownloaded()
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(file, ".gguf")
+			for _, m := range mgr.ListModels() {
+				if m.Path == "" && m.Filename() == file {
+					name = m.Name
+					break
+				}
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no downloaded models; download one with: scmd models pull <name>")
+		}
+	}
+
+	var targets []benchTarget
+	for _, name := range names {
+		path, ok := mgr.LocalPath(name)
+		if !ok {
+			path, ok = mgr.LocalPath(name + ".gguf")
+		}
+		if !ok {
+			return nil, fmt.Errorf("model %s is not downloaded; download it with: scmd models pull %s", name, name)
+		}
+		targets = append(targets, benchTarget{name: name, path: path})
+	}
+	return targets, nil
+}
+
+// filterBenchResults keeps the results for the named models, or all
+// without names
+func filterBenchResults(results []llamacpp.BenchResult, names []string) []llamacpp.BenchResult {
+	if len(names) == 0 {
+		return results
+	}
+	var kept []llamacpp.BenchResult
+	for _, r := range results {
+		for _, name := range names {
+			if r.Model == name {
+				kept = append(kept, r)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// printBenchResults prints results as a table or JSON
+func printBenchResults(results []llamacpp.BenchResult, asJSON bool) error {
+	if asJSON {
+		if results == nil {
+			results = []llamacpp.BenchResult{}
+		}
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No benchmark results. Run: scmd models bench")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tTHREADS\tCONTEXT\tGPU LAYERS\tPROMPT T/S\tGEN T/S\tTTFT\tPEAK RSS\tDATE")
+	for _, r := range results {
+		rss := "-"
+		if r.PeakRSSBytes > 0 {
+			rss = formatSize(r.PeakRSSBytes)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f\t%.1f\t%dms\t%s\t%s\n",
+			r.Model, r.Threads, r.ContextSize, r.GPULayers, r.PromptTPS, r.GenTPS, r.TTFTMillis, rss, r.Time.Format("2006-01-02"))
+	}
+	w.Flush()
+
+	// Machines differ; say which one the numbers are for
+	machines := make(map[string]bool)
+	for _, r := range results {
+		machines[r.Machine] = true
+	}
+	if len(machines) == 1 && results[0].Machine != "" {
+		fmt.Printf("\nMachine: %s\n", results[0].Machine)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend/llamacpp"
+)
+
+func TestBenchTargets(t *testing.T) {
+	dir := t.TempDir()
+	modelsDir := filepath.Join(dir, "models")
+	require.NoError(t, os.MkdirAll(modelsDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modelsDir, "my-finetune.gguf"), []byte("GGUF"), 0644))
+
+	mgr := llamacpp.NewModelManager(dir)
+
+	targets, err := benchTargets(mgr, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []benchTarget{{name: "my-finetune", path: filepath.Join(modelsDir, "my-finetune.gguf")}}, targets)
+
+	targets, err = benchTargets(mgr, []string{"my-finetune"})
+	require.NoError(t, err)
+	assert.Len(t, targets, 1)
+
+	_, err = benchTargets(mgr, []string{"qwen3-4b"})
+	assert.ErrorContains(t, err, "not downloaded")
+}
+
+func TestFilterBenchResults(t *testing.T) {
+	results := []llamacpp.BenchResult{{Model: "a"}, {Model: "b"}, {Model: "a"}}
+
+	assert.Len(t, filterBenchResults(results, nil), 3)
+	assert.Len(t, filterBenchResults(results, []string{"a"}), 2)
+	assert.Empty(t, filterBenchResults(results, []string{"c"}))
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/cassette"
+)
+
+// replayPrefix selects the cassette player, as in --backend replay:session.yaml
+const replayPrefix = "replay:"
+
+// cassetteRecorder is the --record recorder and recordedBackend the
+// backend it was made for. Resolving the same backend again reuses it, so
+// one run never has two writers on the cassette.
+var (
+	cassetteRecorder *cassette.Recorder
+	recordedBackend  backend.Backend
+)
+
+// openReplay returns the cassette player when name is replay:<file>
+func openReplay(name string) (backend.Backend, bool, error) {
+	path, ok := strings.CutPrefix(name, replayPrefix)
+	if !ok {
+		return nil, false, nil
+	}
+	if path == "" {
+		return nil, true, fmt.Errorf("--backend %s needs a cassette file", replayPrefix)
+	}
+
+	player, err := cassette.Open(path)
+	if err != nil {
+		return nil, true, err
+	}
+	// Replay at recorded speed when someone is watching
+	player.SetRealtime(term.IsTerminal(int(os.Stdout.Fd())))
+	return player, true, nil
+}
+
+// withRecording wraps b in the --record recorder, if one was asked for.
+// key is the backend as resolved, before b was wrapped in the cache.
+// Replayed backends are never recorded.
+func withRecording(key, b backend.Backend) (backend.Backend, error) {
+	if recordFlag == "" || b.Type() == backend.TypeReplay {
+		return b, nil
+	}
+	if cassetteRecorder != nil {
+		if recordedBackend != key {
+			return nil, fmt.Errorf("--record: already recording %s", recordedBackend.Name())
+		}
+		return cassetteRecorder, nil
+	}
+
+	r, err := cassette.Record(b, recordFlag)
+	if err != nil {
+		return nil, fmt.Errorf("--record: %w", err)
+	}
+	cassetteRecorder, recordedBackend = r, key
+	return r, nil
+}
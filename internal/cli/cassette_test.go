@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/cassette"
+	"github.com/scmd/scmd/internal/backend/mock"
+)
+
+func TestOpenReplay(t *testing.T) {
+	_, ok, err := openReplay("ollama")
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	_, ok, err = openReplay("replay:")
+	assert.True(t, ok)
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "session.yaml")
+	require.NoError(t, (&cassette.Cassette{}).Save(path))
+
+	b, ok, err := openReplay("replay:" + path)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, backend.TypeReplay, b.Type())
+}
+
+func TestWithRecording(t *testing.T) {
+	defer func() {
+		recordFlag, cassetteRecorder, recordedBackend = "", nil, nil
+	}()
+
+	m := mock.New()
+	b, err := withRecording(m, m)
+	require.NoError(t, err)
+	assert.Same(t, m, b, "no --record leaves the backend alone")
+
+	recordFlag = filepath.Join(t.TempDir(), "session.yaml")
+	b, err = withRecording(m, m)
+	require.NoError(t, err)
+	assert.IsType(t, &cassette.Recorder{}, b)
+
+	again, err := withRecording(m, m)
+	require.NoError(t, err)
+	assert.Same(t, b, again)
+
+	_, err = withRecording(mock.New(), mock.New())
+	assert.Error(t, err)
+}
@@ -1,12 +1,12 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/anthropic"
 	"github.com/scmd/scmd/internal/backend/llamacpp"
 	"github.com/scmd/scmd/internal/backend/ollama"
 	"github.com/scmd/scmd/internal/backend/openai"
@@ -82,7 +82,7 @@ func init() {
 	// Chat command flags (avoiding -c which is used for context globally)
 	chatCmd.Flags().String("continue", "", "Continue a previous conversation by ID")
 	chatCmd.Flags().StringP("model", "m", "", "Model to use")
-	chatCmd.Flags().String("backend", "", "Backend to use (llamacpp, ollama, openai)") // -b is already used globally
+	chatCmd.Flags().String("backend", "", "Backend to use (llamacpp, ollama, openai, a configured provider, replay:<cassette>)") // -b is already used globally
 
 	// History list flags
 	historyListCmd.Flags().IntP("limit", "n", 20, "Number of conversations to show")
@@ -96,7 +96,7 @@ func init() {
 }
 
 func runChat(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+	ctx := commandContext()
 
 	// Get flags
 	continueID, _ := cmd.Flags().GetString("continue")
@@ -117,7 +117,9 @@ func runChat(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if modelName == "" {
+	// Providers default to the model in their profile
+	_, isProvider := cfg.Backends.Providers[backendName]
+	if modelName == "" && !isProvider {
 		modelName = cfg.GetString("backends.local.model")
 		if modelName == "" {
 			modelName = "qwen2.5-1.5b" // Default model
@@ -129,6 +131,11 @@ func runChat(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to initialize backend: %w", err)
 	}
+	configureRetries([]backend.Backend{backendInstance}, cfg.Backends)
+	backendInstance, err = withRecording(backendInstance, withUsage(backendInstance, "chat"))
+	if err != nil {
+		return err
+	}
 
 	// Initialize backend
 	if err := backendInstance.Initialize(ctx); err != nil {
@@ -321,6 +328,21 @@ func runHistoryClear(cmd *cobra.Command, args []string) error {
 func initializeBackend(backendName, modelName string, cfg *config.Config) (backend.Backend, error) {
 	dataDir := config.GetDataDir()
 
+	if player, ok, err := openReplay(backendName); ok {
+		return player, err
+	}
+
+	if p, ok := cfg.Backends.Providers[backendName]; ok {
+		b, err := newProviderBackend(backendName, p)
+		if err != nil {
+			return nil, err
+		}
+		if modelName != "" {
+			b.SetModel(modelName)
+		}
+		return b, nil
+	}
+
 	// Create backend based on type
 	switch backendName {
 	case "llamacpp", "local":
@@ -358,6 +380,16 @@ func initializeBackend(backendName, modelName string, cfg *config.Config) (backe
 		}
 		return openai.New(openaiConfig), nil
 
+	case "claude":
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+		}
+		return anthropic.New(&anthropic.Config{
+			APIKey: apiKey,
+			Model:  modelName,
+		}), nil
+
 	default:
 		return nil, fmt.Errorf("unknown backend: %s", backendName)
 	}
@@ -0,0 +1,330 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/scmd/scmd/internal/config"
+	"github.com/scmd/scmd/internal/daemon"
+	"github.com/scmd/scmd/pkg/version"
+)
+
+// daemonCmd manages the background daemon
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Serve slash commands from a background process",
+	Long: `Keep scmd loaded in a background process so slash commands start at once.
+
+Every scmd invocation loads the config, builds its command and backend
+registries and loads installed repos before it runs anything. The daemon
+does this once and keeps the registries, backend connections and caches
+in memory. While it runs, slash commands such as "scmd /e main.go" are
+sent to it over a Unix socket (~/.scmd/daemon.sock) and their output is
+streamed back. Without a daemon they run as before.
+
+Commands:
+  start   - Run the daemon in the foreground
+  stop    - Stop the daemon
+  status  - Show whether the daemon is running
+
+The daemon reloads when config.yaml, models.yaml, repos or installed
+commands change. It keeps the environment it started with, so restart it
+after changing API keys. Set SCMD_NO_DAEMON=1 to run a command without it.`,
+}
+
+var daemonStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Run the daemon in the foreground",
+	Long: `Run the daemon in the foreground until Ctrl+C or 'scmd daemon stop'.
+Run it in the background or as a login service to keep it on hand.
+
+Examples:
+  scmd daemon start
+  scmd daemon start > ~/.scmd/daemon.log 2>&1 &`,
+	RunE: runDaemonStart,
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the daemon",
+	RunE:  runDaemonStop,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running",
+	RunE:  runDaemonStatus,
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonStartCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	daemonCmd.AddCommand(daemonStatusCmd)
+}
+
+// daemonSocketPath returns the socket the daemon listens on
+func daemonSocketPath() string {
+	return daemon.SocketPath(getDataDir())
+}
+
+// loadedStamp is the runtimeStamp of the daemon's registries
+var loadedStamp time.Time
+
+// runtimeStamp returns the latest change to the files initRuntime reads,
+// so the daemon can tell when to rebuild its registries
+func runtimeStamp() time.Time {
+	dataDir := getDataDir()
+	var latest time.Time
+	for _, path := range []string{
+		config.ConfigPath(),
+		filepath.Join(dataDir, "models.yaml"),
+		filepath.Join(dataDir, "repos.json"),
+		filepath.Join(dataDir, "commands"),
+	} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func runDaemonStart(cmd *cobra.Command, args []string) error {
+	path := daemonSocketPath()
+	l, err := daemon.Listen(path)
+	if err != nil {
+		return err
+	}
+	loadedStamp = runtimeStamp()
+
+	fmt.Printf("scmd daemon listening on %s (PID %d)\n", path, os.Getpid())
+	fmt.Println("   Press Ctrl+C or run 'scmd daemon stop' to stop")
+	fmt.Println()
+
+	srv := daemon.NewServer(serveDaemonRequest, version.Full())
+	if err := srv.Serve(commandContext(), l); err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	fmt.Println("✅ scmd daemon stopped")
+	return nil
+}
+
+func runDaemonStop(cmd *cobra.Command, args []string) error {
+	client := daemon.NewClient(daemonSocketPath())
+	if _, err := client.Status(commandContext()); err != nil {
+		fmt.Println("ℹ️  scmd daemon is not running")
+		return nil
+	}
+	if err := client.Stop(commandContext()); err != nil {
+		return fmt.Errorf("stop daemon: %w", err)
+	}
+	fmt.Println("✅ scmd daemon stopped")
+	return nil
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) error {
+	st, err := daemon.NewClient(daemonSocketPath()).Status(commandContext())
+	if err != nil {
+		fmt.Println("❌ scmd daemon is not running")
+		fmt.Println("   Start it with: scmd daemon start")
+		return nil
+	}
+
+	fmt.Printf("✅ scmd daemon is running (PID %d)\n", st.PID)
+	fmt.Printf("   Socket:   %s\n", daemonSocketPath())
+	fmt.Printf("   Version:  %s\n", st.Version)
+	fmt.Printf("   Uptime:   %s\n", time.Since(st.Started).Round(time.Second))
+	fmt.Printf("   Commands: %d\n", st.Requests)
+	if st.Version != version.Full() {
+		fmt.Printf("   ⚠️  This is scmd %s; commands run without the daemon until it is restarted\n", version.Full())
+	}
+	return nil
+}
+
+// forwardToDaemon runs a slash command on the daemon if one is running.
+// handled is false when the command should run in this process instead:
+// no daemon answers, it runs another scmd version, or the command uses
+// flags the daemon only applies when it starts.
+func forwardToDaemon(ctx context.Context, slashCmd string, args []string) (handled bool, err error) {
+	if os.Getenv("SCMD_NO_DAEMON") != "" || hasFlag(args, "context-size") {
+		return false, nil
+	}
+
+	client := daemon.NewClient(daemonSocketPath())
+	st, err := client.Status(ctx)
+	if err != nil || st.Version != version.Full() {
+		return false, nil
+	}
+
+	mode := DetectIOMode()
+	req := &daemon.Request{
+		Args:      append([]string{slashCmd}, args...),
+		PipeIn:    mode.PipeIn,
+		StdoutTTY: mode.StdoutIsTTY,
+		StderrTTY: mode.StderrIsTTY,
+	}
+	if req.Dir, err = os.Getwd(); err != nil {
+		return false, nil
+	}
+	if mode.PipeIn {
+		if req.Stdin, err = NewStdinReader().Read(ctx); err != nil {
+			return true, fmt.Errorf("read stdin: %w", err)
+		}
+	}
+
+	if os.Getenv("SCMD_DEBUG") != "" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Running %s on daemon PID %d\n", slashCmd, st.PID)
+	}
+	return true, client.Run(ctx, req, os.Stdout, os.Stderr)
+}
+
+// hasFlag reports whether args set the long flag name
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--"+name || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// serveDaemonRequest runs a forwarded slash command in the daemon, as
+// runSlashCommand would in the client's process
+func serveDaemonRequest(ctx context.Context, req *daemon.Request, stdout, stderr io.Writer) error {
+	if len(req.Args) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	if req.Dir != "" {
+		prev, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if err := os.Chdir(req.Dir); err != nil {
+			return fmt.Errorf("change to %s: %w", req.Dir, err)
+		}
+		defer os.Chdir(prev)
+	}
+
+	restore, err := redirectStdio(req.Stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	prevCtx := runContext
+	runContext = ctx
+	clientMode = &IOMode{
+		HasStdin:    req.PipeIn,
+		StdoutIsTTY: req.StdoutTTY,
+		StderrIsTTY: req.StderrTTY,
+		PipeIn:      req.PipeIn,
+		PipeOut:     !req.StdoutTTY,
+	}
+	defer func() {
+		runContext = prevCtx
+		clientMode = nil
+	}()
+
+	if stamp := runtimeStamp(); stamp.After(loadedStamp) {
+		if err := initRuntime(); err != nil {
+			return err
+		}
+		loadedStamp = stamp
+	}
+
+	// Flags keep their values between requests; start from the defaults
+	rootCmd.PersistentFlags().VisitAll(resetFlag)
+	if err := rootCmd.ParseFlags(req.Args[1:]); err != nil {
+		return err
+	}
+	if err := applyFlags(rootCmd); err != nil {
+		return err
+	}
+
+	return execSlashCommand(strings.TrimPrefix(req.Args[0], "/"), rootCmd.Flags().Args())
+}
+
+// resetFlag sets f back to its default value
+func resetFlag(f *pflag.Flag) {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		_ = slice.Replace(nil)
+	} else {
+		_ = f.Value.Set(f.DefValue)
+	}
+	f.Changed = false
+}
+
+// redirectStdio points os.Stdin at a pipe holding stdin and copies what is
+// written to os.Stdout and os.Stderr to stdout and stderr, until restore
+// is called
+func redirectStdio(stdin string, stdout, stderr io.Writer) (restore func(), err error) {
+	var files []*os.File
+	pipe := func() (*os.File, *os.File, error) {
+		r, w, err := os.Pipe()
+		if err == nil {
+			files = append(files, r, w)
+		}
+		return r, w, err
+	}
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	inR, inW, err := pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create pipe: %w", err)
+	}
+	outR, outW, err := pipe()
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("create pipe: %w", err)
+	}
+	errR, errW, err := pipe()
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("create pipe: %w", err)
+	}
+
+	go func() {
+		_, _ = io.WriteString(inW, stdin)
+		inW.Close()
+	}()
+
+	var wg sync.WaitGroup
+	forward := func(w io.Writer, r *os.File) {
+		defer wg.Done()
+		if _, err := io.Copy(w, r); err != nil {
+			// The client hung up; keep the command from blocking on a
+			// full pipe while it winds down
+			_, _ = io.Copy(io.Discard, r)
+		}
+	}
+	wg.Add(2)
+	go forward(stdout, outR)
+	go forward(stderr, errR)
+
+	origIn, origOut, origErr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = inR, outW, errW
+
+	return func() {
+		os.Stdin, os.Stdout, os.Stderr = origIn, origOut, origErr
+		outW.Close()
+		errW.Close()
+		wg.Wait()
+		closeAll()
+	}, nil
+}
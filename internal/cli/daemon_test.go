@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/daemon"
+)
+
+func TestHasFlag(t *testing.T) {
+	assert.True(t, hasFlag([]string{"main.go", "--context-size", "4096"}, "context-size"))
+	assert.True(t, hasFlag([]string{"--context-size=4096"}, "context-size"))
+	assert.False(t, hasFlag([]string{"--context", "a.go"}, "context-size"))
+	assert.False(t, hasFlag([]string{"--", "--context-size"}, "context-size"))
+}
+
+func TestRedirectStdio(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	restore, err := redirectStdio("piped input", &stdout, &stderr)
+	require.NoError(t, err)
+
+	in, err := io.ReadAll(os.Stdin)
+	fmt.Print("to stdout")
+	fmt.Fprint(os.Stderr, "to stderr")
+	restore()
+
+	require.NoError(t, err)
+	assert.Equal(t, "piped input", string(in))
+	assert.Equal(t, "to stdout", stdout.String())
+	assert.Equal(t, "to stderr", stderr.String())
+}
+
+func TestServeDaemonRequest(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SCMD_DATA_DIR", filepath.Join(home, ".scmd"))
+	require.NoError(t, initRuntime())
+	t.Cleanup(func() { rootCmd.PersistentFlags().VisitAll(resetFlag) })
+
+	var stdout bytes.Buffer
+	req := &daemon.Request{
+		Args: []string{"/e", "--backend", "mock", "--format", "plain", "what is a goroutine"},
+		Dir:  t.TempDir(),
+	}
+	require.NoError(t, serveDaemonRequest(context.Background(), req, &stdout, io.Discard))
+	assert.Contains(t, stdout.String(), "mock response")
+	assert.Nil(t, clientMode, "the client's mode is only used during the request")
+
+	// Flags from one request do not leak into the next
+	req = &daemon.Request{Args: []string{"/e", "--format", "bogus", "x"}}
+	assert.ErrorContains(t, serveDaemonRequest(context.Background(), req, io.Discard, io.Discard), "invalid format")
+	assert.Empty(t, backendFlag)
+}
@@ -36,7 +36,7 @@ func init() {
 }
 
 func runDoctor(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+	ctx := commandContext()
 
 	fmt.Println("🏥 scmd Health Check")
 	fmt.Println(strings.Repeat("═", 60))
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scmd/scmd/internal/backend/llamacpp"
+)
+
+// modelsSearchCmd searches Hugging Face for GGUF models
+var modelsSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search Hugging Face for GGUF models",
+	Long: `Search the Hugging Face Hub for repositories with GGUF models, most
+downloaded first. Pull one with: scmd models pull hf:<org>/<repo>
+
+The hub is set by models.huggingface_url in ~/.scmd/config.yaml.`,
+	Args: cobra.MinimumNArgs(1),
+	Example: `  scmd models search qwen2.5 coder
+  scmd models search llama-3.2 --limit 5`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		repos, err := hfClient().Search(commandContext(), strings.Join(args, " "), limit)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			fmt.Println("No GGUF repositories found.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPOSITORY\tDOWNLOADS\tLIKES")
+		for _, r := range repos {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", r.ID, r.Downloads, r.Likes)
+		}
+		w.Flush()
+
+		fmt.Println()
+		fmt.Println("List a repository's files: scmd models pull hf:<repository> --list")
+		return nil
+	},
+}
+
+// hfClient returns a client for the configured Hugging Face hub
+func hfClient() *llamacpp.HFClient {
+	baseURL := ""
+	if cfg != nil {
+		baseURL = cfg.Models.HuggingFaceURL
+	}
+	return llamacpp.NewHFClient(baseURL)
+}
+
+// pullFromHF lists the GGUF files in a Hugging Face repository, picks the
+// one named by quant or the one recommended for this machine, adds it to
+// the catalog and downloads it
+func pullFromHF(ctx context.Context, cmd *cobra.Command, repo, quant string, workers int) error {
+	dataDir := getDataDir()
+	if q, _ := cmd.Flags().GetString("quant"); q != "" {
+		quant = strings.ToUpper(q)
+	}
+	name, _ := cmd.Flags().GetString("name")
+	if name == "" {
+		name = llamacpp.HFModelName(repo)
+	}
+	listOnly, _ := cmd.Flags().GetBool("list")
+
+	files, err := hfClient().ListGGUF(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%s has no single-file GGUF models", repo)
+	}
+
+	recommended, fits := -1, false
+	if resources, err := llamacpp.DetectSystemResources(); err == nil {
+		recommended, fits = llamacpp.RecommendFile(files, resources)
+	}
+	printHFFiles(files, recommended, fits)
+	if listOnly {
+		return nil
+	}
+
+	pick, err := selectHFFile(files, quant, recommended)
+	if err != nil {
+		return err
+	}
+	if pick == recommended && !fits {
+		fmt.Fprintln(os.Stderr, "Warning: no file fits in this machine's memory; using the smallest")
+	}
+	file := files[pick]
+
+	if err := llamacpp.AddToCatalog(dataDir, file.Model(name)); err != nil {
+		return err
+	}
+	fmt.Printf("\nAdded %s as %s to ~/.scmd/models.yaml\n", file.Path, name)
+
+	// A new manager reads the catalog entry just written
+	mgr := llamacpp.NewModelManager(dataDir)
+	mgr.SetDownloadWorkers(workers)
+	path, err := mgr.GetModelPath(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Model ready: %s\n", path)
+	fmt.Printf("Make it the default with: scmd models default %s\n", name)
+	return nil
+}
+
+// selectHFFile returns the index of the file with quantization quant, or
+// the recommended one when quant is empty
+func selectHFFile(files []llamacpp.HFFile, quant string, recommended int) (int, error) {
+	if quant == "" {
+		if recommended < 0 {
+			return 0, fmt.Errorf("could not detect system memory; choose a file with --quant")
+		}
+		return recommended, nil
+	}
+
+	var known []string
+	for i, f := range files {
+		if f.Quant == quant {
+			return i, nil
+		}
+		if f.Quant != "" {
+			known = append(known, f.Quant)
+		}
+	}
+	return 0, fmt.Errorf("no %s file in this repository (available: %s)", quant, strings.Join(known, ", "))
+}
+
+// printHFFiles lists a repository's GGUF files, marking the recommended one
+func printHFFiles(files []llamacpp.HFFile, recommended int, fits bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tQUANT\tSIZE\t")
+	for i, f := range files {
+		quant := f.Quant
+		if quant == "" {
+			quant = "-"
+		}
+		note := ""
+		if i == recommended {
+			note = "← recommended for this machine"
+			if !fits {
+				note = "← smallest; none fits in memory"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Path, quant, formatSize(f.Size), note)
+	}
+	w.Flush()
+}
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend/llamacpp"
+)
+
+func TestSelectHFFile(t *testing.T) {
+	files := []llamacpp.HFFile{{Quant: "Q4_K_M"}, {Quant: "Q8_0"}, {Quant: ""}}
+
+	pick, err := selectHFFile(files, "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pick)
+
+	pick, err = selectHFFile(files, "Q4_K_M", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pick)
+
+	_, err = selectHFFile(files, "Q2_K", 1)
+	assert.ErrorContains(t, err, "available: Q4_K_M, Q8_0")
+
+	_, err = selectHFFile(files, "", -1)
+	assert.ErrorContains(t, err, "--quant")
+}
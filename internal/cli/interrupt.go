@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ExitInterrupted is the exit code after Ctrl-C or SIGTERM, the code
+// shells report for a process stopped by SIGINT
+const ExitInterrupted = 130
+
+// ErrInterrupted is returned by Execute when a signal cancelled the command
+var ErrInterrupted = errors.New("interrupted")
+
+// interruptContext returns a context cancelled by the first Ctrl-C or
+// SIGTERM. The command then winds down: backends abort their requests and
+// streamed output so far stays on screen. A second signal kills the
+// process as usual.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigs:
+			// The command's error is only the cancellation; Execute
+			// reports it. Set before cancel so cobra sees it on return.
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			signal.Stop(sigs)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
+}
+
+// runContext is the context Execute runs commands with
+var runContext = context.Background()
+
+// commandContext returns the context of the running command, which is
+// cancelled on Ctrl-C
+func commandContext() context.Context {
+	return runContext
+}
+
+// interrupted turns a command's error into ErrInterrupted if ctx was
+// cancelled by a signal. Commands that stop cleanly on a signal, like a
+// foreground server, still succeed.
+func interrupted(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ErrInterrupted
+	}
+	return err
+}
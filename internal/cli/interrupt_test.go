@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	failure := errors.New("completion failed")
+
+	assert.Equal(t, failure, interrupted(ctx, failure))
+	assert.NoError(t, interrupted(ctx, nil))
+
+	cancel()
+	assert.ErrorIs(t, interrupted(ctx, failure), ErrInterrupted)
+	assert.NoError(t, interrupted(ctx, nil), "a command that stops cleanly still succeeds")
+}
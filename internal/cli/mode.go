@@ -17,8 +17,17 @@ type IOMode struct {
 	PipeOut     bool // Output is being piped
 }
 
+// clientMode, when set, replaces the detected mode. The daemon sets it to
+// the mode of the client a forwarded command came from.
+var clientMode *IOMode
+
 // DetectIOMode determines how scmd is being invoked
 func DetectIOMode() *IOMode {
+	if clientMode != nil {
+		mode := *clientMode
+		return &mode
+	}
+
 	stdinIsTTY := term.IsTerminal(int(os.Stdin.Fd()))
 	stdoutIsTTY := term.IsTerminal(int(os.Stdout.Fd()))
 	stderrIsTTY := term.IsTerminal(int(os.Stderr.Fd()))
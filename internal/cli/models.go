@@ -1,9 +1,10 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
@@ -47,8 +48,9 @@ var modelsListCmd = &cobra.Command{
 
 		for _, m := range mgr.ListModels() {
 			status := "not downloaded"
-			filename := fmt.Sprintf("%s-%s.gguf", m.Name, m.Variant)
-			if downloadedSet[filename] {
+			if m.Path != "" {
+				status = "✓ local file"
+			} else if downloadedSet[m.Filename()] {
 				status = "✓ ready"
 			}
 
@@ -61,6 +63,7 @@ var modelsListCmd = &cobra.Command{
 		fmt.Println("Default model:", llamacpp.GetDefaultModel())
 		fmt.Println()
 		fmt.Println("Download a model: scmd models pull <name>")
+		fmt.Println("Add your own:     scmd models add <name> <url|path>")
 
 		return nil
 	},
@@ -68,17 +71,33 @@ var modelsListCmd = &cobra.Command{
 
 // modelsPullCmd downloads a model
 var modelsPullCmd = &cobra.Command{
-	Use:   "pull <model>",
+	Use:   "pull <model|hf:org/repo>",
 	Short: "Download a model",
 	Args:  cobra.ExactArgs(1),
+	Long: `Download a model into ~/.scmd/models. Large files are fetched in
+parallel segments; an interrupted download resumes where it stopped, and
+the model's mirrors are tried when its URL fails.
+
+With hf:<org>/<repo>, the GGUF files of a Hugging Face repository are
+listed and the largest quantization that fits in this machine's memory is
+downloaded and added to ~/.scmd/models.yaml. Pick another with --quant or
+hf:<org>/<repo>:<quant>.`,
 	Example: `  scmd models pull qwen3-4b
-  scmd models pull qwen3-1.7b`,
+  scmd models pull qwen3-1.7b --workers 8
+  scmd models pull hf:Qwen/Qwen2.5-3B-Instruct-GGUF
+  scmd models pull hf:Qwen/Qwen2.5-3B-Instruct-GGUF --quant Q8_0 --name qwen-3b-q8
+  scmd models pull hf:bartowski/Llama-3.2-3B-Instruct-GGUF --list`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 		dataDir := getDataDir()
 		mgr := llamacpp.NewModelManager(dataDir)
+		workers, _ := cmd.Flags().GetInt("workers")
+		mgr.SetDownloadWorkers(workers)
 
 		modelName := args[0]
+		if repo, quant, ok := llamacpp.ParseHFRef(modelName); ok {
+			return pullFromHF(ctx, cmd, repo, quant, workers)
+		}
 		fmt.Printf("Pulling model: %s\n", modelName)
 
 		path, err := mgr.GetModelPath(ctx, modelName)
@@ -112,7 +131,7 @@ var modelsRemoveCmd = &cobra.Command{
 
 // modelsInfoCmd shows model information
 var modelsInfoCmd = &cobra.Command{
-	Use:   "info <model>",
+	Use:   "info <model|file.gguf>",
 	Short: "Show model information",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -121,29 +140,85 @@ var modelsInfoCmd = &cobra.Command{
 
 		modelName := args[0]
 
-		for _, m := range mgr.ListModels() {
-			if m.Name == modelName {
-				fmt.Printf("Name:         %s\n", m.Name)
-				fmt.Printf("Variant:      %s\n", m.Variant)
-				fmt.Printf("Size:         %s\n", formatSize(m.Size))
-				fmt.Printf("Context:      %d tokens\n", m.ContextSize)
-				fmt.Printf("Tool Calling: %v\n", m.ToolCalling)
-				fmt.Printf("Description:  %s\n", m.Description)
-				fmt.Printf("URL:          %s\n", m.URL)
-				return nil
+		m, ok := mgr.FindModel(modelName)
+		if !ok {
+			// Any GGUF file can be inspected
+			if path, ok := mgr.LocalPath(modelName); ok {
+				return printGGUFInfo(path)
 			}
+			return fmt.Errorf("model not found: %s", modelName)
 		}
 
-		return fmt.Errorf("model not found: %s", modelName)
+		fmt.Printf("Name:         %s\n", m.Name)
+		fmt.Printf("Variant:      %s\n", m.Variant)
+		fmt.Printf("Size:         %s\n", formatSize(m.Size))
+		fmt.Printf("Context:      %d tokens\n", m.ContextSize)
+		fmt.Printf("Tool Calling: %v\n", m.ToolCalling)
+		if m.ChatTemplate != "" {
+			fmt.Printf("Template:     %s\n", m.ChatTemplate)
+		}
+		fmt.Printf("Description:  %s\n", m.Description)
+		if m.Path != "" {
+			fmt.Printf("Path:         %s\n", m.Path)
+		} else {
+			fmt.Printf("URL:          %s\n", m.URL)
+			for _, mirror := range m.Mirrors {
+				fmt.Printf("Mirror:       %s\n", mirror)
+			}
+		}
+		if m.SHA256 != "" {
+			fmt.Printf("SHA256:       %s\n", m.SHA256)
+		}
+
+		if path, ok := mgr.LocalPath(modelName); ok {
+			fmt.Println()
+			return printGGUFInfo(path)
+		}
+		return nil
 	},
 }
 
+// printGGUFInfo prints the metadata in a model file's GGUF header
+func printGGUFInfo(path string) error {
+	meta, err := llamacpp.ReadGGUFMetadata(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("File:         %s\n", path)
+	if meta.Name != "" {
+		fmt.Printf("Model Name:   %s\n", meta.Name)
+	}
+	fmt.Printf("Architecture: %s\n", meta.Architecture)
+	fmt.Printf("Parameters:   %s\n", meta.Parameters())
+	fmt.Printf("Quantization: %s\n", meta.Quantization())
+	fmt.Printf("Native Ctx:   %d tokens\n", meta.ContextLength)
+	fmt.Printf("Layers:       %d\n", meta.LayerCount)
+	if meta.ChatTemplate != "" {
+		format := "unknown, rendered by llama-server"
+		if t := llamacpp.DetectChatTemplate(meta.ChatTemplate); t != nil {
+			format = t.Name
+		}
+		fmt.Printf("Chat Tmpl:    embedded, %s (%d chars, tools: %v)\n", format, len(meta.ChatTemplate), meta.SupportsTools())
+	} else {
+		fmt.Println("Chat Tmpl:    none")
+	}
+	return nil
+}
+
 // modelsSetDefaultCmd sets the default model
 var modelsSetDefaultCmd = &cobra.Command{
 	Use:   "default <model>",
 	Short: "Set the default model",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr := llamacpp.NewModelManager(getDataDir())
+		if _, ok := mgr.FindModel(args[0]); !ok {
+			if _, err := os.Stat(args[0]); err != nil {
+				return fmt.Errorf("unknown model: %s (see 'scmd models list')", args[0])
+			}
+		}
+
 		// Update config
 		cfg.Backends.Local.Model = args[0]
 		if err := config.Save(cfg); err != nil {
@@ -155,6 +230,69 @@ var modelsSetDefaultCmd = &cobra.Command{
 	},
 }
 
+// modelsAddCmd registers a model in the user catalog
+var modelsAddCmd = &cobra.Command{
+	Use:   "add <name> <url|path>",
+	Short: "Add a model to the catalog",
+	Long: `Add a GGUF model to ~/.scmd/models.yaml so it can be used like the
+built-in models. Give either a download URL or the path to a local file.
+A model with the same name as an existing entry replaces it.`,
+	Args: cobra.ExactArgs(2),
+	Example: `  scmd models add llama3.2-3b https://example.com/Llama-3.2-3B-Instruct-Q4_K_M.gguf \
+      --sha256 <hash> --context-size 131072 --chat-template llama3 \
+      --mirror https://mirror.example.org/Llama-3.2-3B-Instruct-Q4_K_M.gguf
+  scmd models add my-finetune ./out/model.gguf --tool-calling`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir := getDataDir()
+		name, source := args[0], args[1]
+
+		model := llamacpp.Model{Name: name}
+		model.Variant, _ = cmd.Flags().GetString("variant")
+		model.SHA256, _ = cmd.Flags().GetString("sha256")
+		model.Description, _ = cmd.Flags().GetString("description")
+		model.ContextSize, _ = cmd.Flags().GetInt("context-size")
+		model.ToolCalling, _ = cmd.Flags().GetBool("tool-calling")
+		model.ChatTemplate, _ = cmd.Flags().GetString("chat-template")
+		model.Mirrors, _ = cmd.Flags().GetStringSlice("mirror")
+
+		if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+			model.URL = source
+		} else {
+			path, err := filepath.Abs(source)
+			if err != nil {
+				return err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("model file: %w", err)
+			}
+			if len(model.Mirrors) > 0 {
+				return fmt.Errorf("--mirror needs a download URL, not a local file")
+			}
+			model.Path = path
+			model.Size = info.Size()
+
+			// Check a local file now; downloads are checked when pulled
+			if model.SHA256 != "" {
+				fmt.Println("Verifying checksum...")
+				if err := llamacpp.VerifyChecksum(path, model.SHA256); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := llamacpp.AddToCatalog(dataDir, model); err != nil {
+			return err
+		}
+
+		fmt.Printf("Added model: %s\n", name)
+		if model.URL != "" {
+			fmt.Printf("Download it with: scmd models pull %s\n", name)
+		}
+		return nil
+	},
+}
+
 func formatSize(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
@@ -174,4 +312,23 @@ func init() {
 	modelsCmd.AddCommand(modelsRemoveCmd)
 	modelsCmd.AddCommand(modelsInfoCmd)
 	modelsCmd.AddCommand(modelsSetDefaultCmd)
+	modelsCmd.AddCommand(modelsAddCmd)
+	modelsCmd.AddCommand(modelsBenchCmd)
+	modelsCmd.AddCommand(modelsSearchCmd)
+
+	modelsAddCmd.Flags().String("sha256", "", "expected SHA256 of the GGUF file")
+	modelsAddCmd.Flags().Int("context-size", 0, "native context size in tokens")
+	modelsAddCmd.Flags().Bool("tool-calling", false, "model supports tool calling")
+	modelsAddCmd.Flags().String("chat-template", "",
+		"prompt format: "+strings.Join(llamacpp.ChatTemplateNames(), ", ")+" (default: detect from the GGUF file)")
+	modelsAddCmd.Flags().String("variant", "", "quantization, e.g. Q4_K_M")
+	modelsAddCmd.Flags().String("description", "", "short description")
+	modelsAddCmd.Flags().StringSlice("mirror", nil, "alternative download URL, tried in order when the URL fails (repeatable)")
+
+	modelsPullCmd.Flags().Int("workers", 0, "parallel connections per download (default 4)")
+	modelsPullCmd.Flags().String("quant", "", "quantization to pull from a Hugging Face repository, e.g. Q4_K_M")
+	modelsPullCmd.Flags().String("name", "", "catalog name for a Hugging Face model (default: from the repository)")
+	modelsPullCmd.Flags().Bool("list", false, "only list a Hugging Face repository's files")
+
+	modelsSearchCmd.Flags().Int("limit", 20, "maximum number of repositories")
 }
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/openai"
+	"github.com/scmd/scmd/internal/config"
+)
+
+// newProviderBackend builds the backend for a backends.providers entry
+func newProviderBackend(name string, p config.ProviderConfig) (*openai.Backend, error) {
+	if err := p.Validate(name); err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(p.Headers))
+	for k, v := range p.Headers {
+		headers[k] = os.ExpandEnv(v)
+	}
+
+	return openai.New(&openai.Config{
+		Name:        name,
+		BaseURL:     p.BaseURL,
+		Model:       p.Model,
+		APIKeyEnv:   p.APIKeyEnv,
+		Headers:     headers,
+		Timeout:     p.Timeout,
+		MaxTokens:   p.MaxTokens,
+		ToolCalling: p.ToolCalling,
+	}), nil
+}
+
+// registerProviders registers the configured providers in name order.
+// Invalid entries and names already taken are skipped with a warning.
+func registerProviders(reg *backend.Registry, providers map[string]config.ProviderConfig) {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b, err := newProviderBackend(name, providers[name])
+		if err == nil {
+			err = reg.Register(b)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring provider: %v\n", err)
+		}
+	}
+}
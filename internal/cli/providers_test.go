@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/config"
+)
+
+func TestRegisterProviders(t *testing.T) {
+	reg := backend.NewRegistry()
+	require.NoError(t, reg.Register(mock.New()))
+
+	registerProviders(reg, map[string]config.ProviderConfig{
+		"lmstudio": {BaseURL: "http://localhost:1234/v1", Model: "qwen2.5-7b-instruct"},
+		"vllm":     {BaseURL: "http://gpu-box:8000/v1", APIKeyEnv: "VLLM_KEY"},
+		"broken":   {BaseURL: "not a url"},
+		"mock":     {BaseURL: "http://localhost:9999/v1"},
+	})
+
+	lmstudio, ok := reg.Get("lmstudio")
+	require.True(t, ok)
+	assert.Equal(t, "qwen2.5-7b-instruct", lmstudio.ModelInfo().Name)
+	avail, _ := lmstudio.IsAvailable(context.Background())
+	assert.True(t, avail)
+
+	_, ok = reg.Get("vllm")
+	assert.True(t, ok)
+
+	_, ok = reg.Get("broken")
+	assert.False(t, ok)
+
+	m, _ := reg.Get("mock")
+	assert.Equal(t, backend.TypeMock, m.Type(), "built-in backends keep their name")
+}
+
+func TestNewProviderBackend_ExpandsHeaders(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_KEY", "secret")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("Api-Key"))
+		assert.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	b, err := newProviderBackend("azure", config.ProviderConfig{
+		BaseURL: srv.URL + "/openai/deployments/gpt-4o?api-version=2024-10-21",
+		Headers: map[string]string{"api-key": "${AZURE_OPENAI_KEY}"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "azure", b.Name())
+
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Content)
+}
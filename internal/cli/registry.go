@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -42,7 +41,7 @@ var registrySearchCmd = &cobra.Command{
   scmd registry search --category=devops
   scmd registry search --verified --sort=downloads`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 		registry := repos.NewRegistry("")
 
 		query := ""
@@ -101,7 +100,7 @@ var registryFeaturedCmd = &cobra.Command{
 	Short:   "Show featured and trending commands",
 	Aliases: []string{"trending", "popular"},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 		registry := repos.NewRegistry("")
 
 		results, err := registry.GetFeatured(ctx)
@@ -137,7 +136,7 @@ var registryCategoriesCmd = &cobra.Command{
 	Short:   "List available command categories",
 	Aliases: []string{"cats"},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 		registry := repos.NewRegistry("")
 
 		categories, err := registry.GetCategories(ctx)
@@ -169,7 +168,7 @@ var updateCmd = &cobra.Command{
 Use --check to only check without installing.
 Use --all to update all commands at once.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 		checkOnly, _ := cmd.Flags().GetBool("check")
 		updateAll, _ := cmd.Flags().GetBool("all")
 
@@ -316,7 +315,7 @@ var lockInstallCmd = &cobra.Command{
 	Short: "Install commands from a lockfile",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 		input := "scmd.lock"
 		if len(args) > 0 {
 			input = args[0]
@@ -349,7 +348,7 @@ var lockInstallCmd = &cobra.Command{
 // cacheCmd manages the local cache
 var cacheCmd = &cobra.Command{
 	Use:   "cache",
-	Short: "Manage the local command cache",
+	Short: "Manage the local command and completion caches",
 }
 
 // cacheStatsCmd shows cache statistics
@@ -374,6 +373,25 @@ var cacheStatsCmd = &cobra.Command{
 			fmt.Printf("  Last updated:         %s\n", stats.LastUpdated.Format("2006-01-02 15:04:05"))
 		}
 
+		store, err := openCompletionCache()
+		if err != nil {
+			return fmt.Errorf("open completion cache: %w", err)
+		}
+		completions, err := store.Stats()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\nCompletion Cache:\n")
+		fmt.Printf("  Enabled:              %t\n", cfg.Cache.Enabled)
+		fmt.Printf("  Entries:              %d\n", completions.Entries)
+		fmt.Printf("  Size:                 %s / %d MB\n", formatSize(completions.Bytes), cfg.Cache.MaxSizeMB)
+		fmt.Printf("  Hits:                 %d\n", completions.Hits)
+		fmt.Printf("  TTL:                  %s\n", cfg.Cache.TTL)
+		if !completions.Oldest.IsZero() {
+			fmt.Printf("  Oldest entry:         %s\n", completions.Oldest.Format("2006-01-02 15:04:05"))
+		}
+
 		return nil
 	},
 }
@@ -390,6 +408,14 @@ var cacheClearCmd = &cobra.Command{
 			return fmt.Errorf("clear cache: %w", err)
 		}
 
+		store, err := openCompletionCache()
+		if err != nil {
+			return fmt.Errorf("open completion cache: %w", err)
+		}
+		if err := store.Clear(); err != nil {
+			return err
+		}
+
 		fmt.Println("Cache cleared.")
 		return nil
 	},
@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -61,7 +60,7 @@ var repoAddCmd = &cobra.Command{
 		fmt.Printf("Added repository '%s' (%s)\n", name, url)
 
 		// Try to fetch manifest to validate
-		ctx := context.Background()
+		ctx := commandContext()
 		repo, _ := mgr.Get(name)
 		manifest, err := mgr.FetchManifest(ctx, repo)
 		if err != nil {
@@ -140,7 +139,7 @@ var repoUpdateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update repository manifests",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 
 		mgr, err := getRepoManager()
 		if err != nil {
@@ -179,7 +178,7 @@ var repoSearchCmd = &cobra.Command{
   scmd repo search docker
   scmd repo search  # list all`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 
 		query := ""
 		if len(args) > 0 {
@@ -220,7 +219,7 @@ var repoShowCmd = &cobra.Command{
 	Example: `  scmd repo show official/git-commit
   scmd repo show community/docker-compose`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 
 		// Parse repo/command format
 		repoCmd := args[0]
@@ -329,7 +328,7 @@ var repoInstallCmd = &cobra.Command{
 	Example: `  scmd repo install official/git-commit
   scmd repo install community/docker-compose`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 
 		// Parse repo/command format
 		repoCmd := args[0]
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/config"
+)
+
+// configureRetries applies backends.retry and backends.rate_limits to the
+// backends that talk to a server over HTTP. Each backend gets its own
+// limiter, shared by all requests in this process.
+func configureRetries(backends []backend.Backend, cfg config.BackendsConfig) {
+	policy := backend.RetryPolicy{
+		MaxAttempts: cfg.Retry.MaxAttempts,
+		BaseDelay:   cfg.Retry.BaseDelay,
+		MaxDelay:    cfg.Retry.MaxDelay,
+		Deadline:    cfg.Retry.Deadline,
+	}
+
+	for _, b := range backends {
+		r, ok := b.(backend.RetryConfigurer)
+		if !ok {
+			continue
+		}
+		limit := cfg.RateLimits[b.Name()]
+		r.SetRetry(policy, backend.NewLimiter(backend.RateLimit{
+			RequestsPerMinute: limit.RequestsPerMinute,
+			TokensPerMinute:   limit.TokensPerMinute,
+		}))
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/anthropic"
+	"github.com/scmd/scmd/internal/backend/cache"
 	"github.com/scmd/scmd/internal/backend/llamacpp"
 	"github.com/scmd/scmd/internal/backend/mock"
 	"github.com/scmd/scmd/internal/backend/ollama"
@@ -32,10 +35,26 @@ var (
 	backendFlag     string
 	modelFlag       string
 	contextSizeFlag int
+	noCacheFlag     bool
+	recordFlag      string
+
+	// Sampling flags; see samplingFromFlags
+	topPFlag             float64
+	topKFlag             int
+	minPFlag             float64
+	seedFlag             int64
+	repeatPenaltyFlag    float64
+	frequencyPenaltyFlag float64
+	presencePenaltyFlag  float64
+	logitBiasFlags       []string
+	samplingOverrides    backend.Sampling
 
 	// Global registries
 	cmdRegistry     *command.Registry
 	backendRegistry *backend.Registry
+
+	// Opened on first use by withCompletionCache
+	completionCache *cache.Store
 )
 
 var rootCmd = &cobra.Command{
@@ -51,7 +70,7 @@ var rootCmd = &cobra.Command{
 
 Backends (in order of preference):
   - Ollama (local): Runs free open-source models locally
-  - OpenAI/Together/Groq: Set API key via environment variable
+  - OpenAI/Together/Groq/Claude: Set API key via environment variable
 
 Examples:
   scmd                           Start interactive mode
@@ -76,9 +95,21 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 
 	// Backend flags
-	rootCmd.PersistentFlags().StringVarP(&backendFlag, "backend", "b", "", "backend to use: ollama, openai, together, groq")
+	rootCmd.PersistentFlags().StringVarP(&backendFlag, "backend", "b", "", "backend to use: ollama, openai, together, groq, a backends.providers name, or replay:<cassette>")
 	rootCmd.PersistentFlags().StringVarP(&modelFlag, "model", "m", "", "model to use (overrides default)")
 	rootCmd.PersistentFlags().IntVar(&contextSizeFlag, "context-size", 0, "max context size (0 = use model's native max)")
+	rootCmd.PersistentFlags().BoolVar(&noCacheFlag, "no-cache", false, "bypass the completion cache")
+	rootCmd.PersistentFlags().StringVar(&recordFlag, "record", "", "record backend interactions to a cassette file (.yaml or .json)")
+
+	// Sampling flags, overriding command and template settings
+	rootCmd.PersistentFlags().Float64Var(&topPFlag, "top-p", 0, "nucleus sampling probability (0-1)")
+	rootCmd.PersistentFlags().IntVar(&topKFlag, "top-k", 0, "sample from the k most likely tokens")
+	rootCmd.PersistentFlags().Float64Var(&minPFlag, "min-p", 0, "minimum token probability relative to the most likely (0-1)")
+	rootCmd.PersistentFlags().Int64Var(&seedFlag, "seed", 0, "random seed for reproducible output")
+	rootCmd.PersistentFlags().Float64Var(&repeatPenaltyFlag, "repeat-penalty", 0, "penalty for repeated tokens (1 = none)")
+	rootCmd.PersistentFlags().Float64Var(&frequencyPenaltyFlag, "frequency-penalty", 0, "frequency penalty (-2 to 2)")
+	rootCmd.PersistentFlags().Float64Var(&presencePenaltyFlag, "presence-penalty", 0, "presence penalty (-2 to 2)")
+	rootCmd.PersistentFlags().StringArrayVar(&logitBiasFlags, "logit-bias", nil, "token bias as token=bias (repeatable)")
 
 	// Pipe/prompt flags
 	rootCmd.PersistentFlags().StringVarP(&promptFlag, "prompt", "p", "", "inline prompt")
@@ -96,11 +127,13 @@ func init() {
 	rootCmd.AddCommand(backendsCmd)
 	rootCmd.AddCommand(doctorCmd)
 	rootCmd.AddCommand(serverCmd)
+	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(repoCmd)
 	rootCmd.AddCommand(registryCmd)
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(lockCmd)
 	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(usageCmd)
 	rootCmd.AddCommand(slashCmd)
 	rootCmd.AddCommand(modelsCmd)
 	rootCmd.AddCommand(completionCmd)
@@ -175,7 +208,7 @@ var backendsCmd = &cobra.Command{
 	Use:   "backends",
 	Short: "List available LLM backends",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 		fmt.Println("Available backends:")
 		fmt.Println()
 
@@ -194,6 +227,7 @@ var backendsCmd = &cobra.Command{
 		fmt.Println("  OPENAI_API_KEY     - OpenAI API key")
 		fmt.Println("  TOGETHER_API_KEY   - Together.ai API key")
 		fmt.Println("  GROQ_API_KEY       - Groq API key")
+		fmt.Println("  ANTHROPIC_API_KEY  - Anthropic (Claude) API key")
 
 		return nil
 	},
@@ -263,7 +297,7 @@ func runBuiltinCommand(name string, args []string) error {
 }
 
 func runBuiltinCommandWithCmd(cmd *cobra.Command, name string, args []string) error {
-	ctx := context.Background()
+	ctx := commandContext()
 	mode := DetectIOMode()
 
 	// Read stdin if piped
@@ -290,7 +324,7 @@ func runBuiltinCommandWithCmd(cmd *cobra.Command, name string, args []string) er
 	defer output.Close()
 
 	// Get the best available backend
-	activeBackend, err := getActiveBackend(ctx)
+	activeBackend, err := resolveBackend(ctx, commandHint(name, args, stdinContent))
 	if err != nil {
 		// If user explicitly specified a backend, fail immediately
 		if backendFlag != "" {
@@ -356,8 +390,6 @@ func runBuiltinCommandWithCmd(cmd *cobra.Command, name string, args []string) er
 }
 
 func preRun(cmd *cobra.Command, _ []string) error {
-	var err error
-
 	// Skip first-run check for certain commands
 	skipCommands := map[string]bool{
 		"help":       true,
@@ -377,6 +409,15 @@ func preRun(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
+	if err := applyFlags(cmd); err != nil {
+		return err
+	}
+	return initRuntime()
+}
+
+// applyFlags validates the flags of one invocation and derives the
+// settings that come from them
+func applyFlags(cmd *cobra.Command) error {
 	// Validate format flag if provided
 	if formatFlag != "" {
 		validFormats := []string{"auto", "markdown", "plain"}
@@ -392,6 +433,16 @@ func preRun(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
+	var err error
+	samplingOverrides, err = samplingFromFlags(cmd)
+	return err
+}
+
+// initRuntime loads the configuration and builds the backend and command
+// registries
+func initRuntime() error {
+	var err error
+
 	// Load configuration
 	cfg, err = config.Load()
 	if err != nil {
@@ -417,6 +468,7 @@ func preRun(cmd *cobra.Command, _ []string) error {
 	if contextSize > 0 {
 		llamaBackend.SetContextSize(contextSize)
 	}
+	llamacpp.SetMaxServers(cfg.Server.MaxModels)
 
 	_ = backendRegistry.Register(llamaBackend)
 
@@ -431,6 +483,10 @@ func preRun(cmd *cobra.Command, _ []string) error {
 	})
 	_ = backendRegistry.Register(ollamaBackend)
 
+	// Providers from backends.providers. They go before the presets below,
+	// so a provider named e.g. "openai" replaces the built-in one.
+	registerProviders(backendRegistry, cfg.Backends.Providers)
+
 	// 3. Groq (fast, free tier available)
 	if os.Getenv("GROQ_API_KEY") != "" {
 		groqBackend := openai.NewGroq(os.Getenv("GROQ_API_KEY"))
@@ -449,10 +505,20 @@ func preRun(cmd *cobra.Command, _ []string) error {
 		_ = backendRegistry.Register(openaiBackend)
 	}
 
-	// 5. Mock backend (fallback for testing)
+	// 5. Anthropic Claude (native Messages API)
+	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+		claudeBackend := anthropic.New(&anthropic.Config{
+			APIKey: os.Getenv("ANTHROPIC_API_KEY"),
+		})
+		_ = backendRegistry.Register(claudeBackend)
+	}
+
+	// 6. Mock backend (fallback for testing)
 	mockBackend := mock.New()
 	_ = backendRegistry.Register(mockBackend)
 
+	configureRetries(backendRegistry.List(), cfg.Backends)
+
 	// Apply configured default backend (if set)
 	if cfg.Backends.Default != "" {
 		if err := backendRegistry.SetDefault(cfg.Backends.Default); err != nil {
@@ -463,6 +529,21 @@ func preRun(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
+	// Fallback chain and routing rules
+	backendRegistry.SetFallback(cfg.Backends.Fallback)
+	for _, route := range cfg.Backends.Routes {
+		err := backendRegistry.AddRule(backend.Rule{
+			Category:  route.Category,
+			MinTokens: route.MinTokens,
+			MaxTokens: route.MaxTokens,
+			Backend:   route.Backend,
+			Model:     route.Model,
+		})
+		if err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring backend route: %v\n", err)
+		}
+	}
+
 	// Register built-in commands
 	if err := builtin.RegisterAll(cmdRegistry); err != nil {
 		return fmt.Errorf("register commands: %w", err)
@@ -480,8 +561,21 @@ func preRun(cmd *cobra.Command, _ []string) error {
 
 // getActiveBackend returns the best available backend
 func getActiveBackend(ctx context.Context) (backend.Backend, error) {
+	return resolveBackend(ctx, backend.Hint{})
+}
+
+// resolveBackend returns the backend for a request, applying configured
+// routing rules and the fallback chain unless --backend was given
+func resolveBackend(ctx context.Context, hint backend.Hint) (backend.Backend, error) {
 	// If user specified a backend, use it
 	if backendFlag != "" {
+		if player, ok, err := openReplay(backendFlag); ok {
+			if err != nil {
+				return nil, err
+			}
+			return wrapBackend(player, hint.Command)
+		}
+
 		b, ok := backendRegistry.Get(backendFlag)
 		if !ok {
 			// Get list of available backends
@@ -497,30 +591,136 @@ func getActiveBackend(ctx context.Context) (backend.Backend, error) {
 				setter.SetModel(modelFlag)
 			}
 		}
-		return b, nil
+		return wrapBackend(b, hint.Command)
 	}
 
 	// Try to find an available backend
-	b, err := backendRegistry.GetAvailable(ctx)
+	b, err := backendRegistry.Resolve(ctx, hint)
 	if err != nil {
 		// Fall back to mock
 		if mock, ok := backendRegistry.Get("mock"); ok {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Warning: %v, using mock backend\n", err)
+			}
 			return mock, nil
 		}
 		return nil, err
 	}
 
-	if modelFlag != "" {
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Using backend: %s\n", b.Name())
+	}
+
+	// --model wins over a model named by the routing rule
+	model := modelFlag
+	if model == "" {
+		model = backendRegistry.RouteModel(hint, b.Name())
+	}
+	if model != "" {
 		if setter, ok := b.(interface{ SetModel(string) }); ok {
-			setter.SetModel(modelFlag)
+			setter.SetModel(model)
 		}
 	}
 
-	return b, nil
+	return wrapBackend(b, hint.Command)
+}
+
+// wrapBackend adds usage tracking, the completion cache, --record and the
+// sampling flags. Usage goes inside the cache so cache hits cost nothing.
+// The sampling overrides go outside the cache so they are part of its key,
+// and outside the recorder so cassettes hold the requests as sent.
+func wrapBackend(b backend.Backend, command string) (backend.Backend, error) {
+	recorded, err := withRecording(b, withCompletionCache(withUsage(b, command)))
+	if err != nil {
+		return nil, err
+	}
+	return backend.WithSampling(recorded, samplingOverrides), nil
+}
+
+// samplingFromFlags collects the sampling flags. --seed is only applied
+// when given, since 0 is a valid seed.
+func samplingFromFlags(cmd *cobra.Command) (backend.Sampling, error) {
+	s := backend.Sampling{
+		TopP:             topPFlag,
+		TopK:             topKFlag,
+		MinP:             minPFlag,
+		RepeatPenalty:    repeatPenaltyFlag,
+		FrequencyPenalty: frequencyPenaltyFlag,
+		PresencePenalty:  presencePenaltyFlag,
+	}
+	if cmd.Flags().Changed("seed") {
+		seed := seedFlag
+		s.Seed = &seed
+	}
+
+	for _, entry := range logitBiasFlags {
+		token, value, ok := strings.Cut(entry, "=")
+		bias, err := strconv.ParseFloat(value, 64)
+		if !ok || token == "" || err != nil {
+			return backend.Sampling{}, fmt.Errorf("invalid --logit-bias %q: expected token=bias", entry)
+		}
+		if s.LogitBias == nil {
+			s.LogitBias = make(map[string]float64)
+		}
+		s.LogitBias[token] = bias
+	}
+
+	if err := s.Validate(); err != nil {
+		return backend.Sampling{}, fmt.Errorf("invalid sampling flags: %w", err)
+	}
+	return s, nil
+}
+
+// withCompletionCache wraps b in the completion cache unless it is
+// disabled in config, bypassed with --no-cache, or b is the mock or
+// replay backend
+func withCompletionCache(b backend.Backend) backend.Backend {
+	if noCacheFlag || cfg == nil || !cfg.Cache.Enabled || b.Type() == backend.TypeMock || b.Type() == backend.TypeReplay {
+		return b
+	}
+
+	store, err := openCompletionCache()
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Warning: completion cache unavailable: %v\n", err)
+		}
+		return b
+	}
+	return cache.Wrap(b, store)
+}
+
+// openCompletionCache opens the completion cache with the configured limits
+func openCompletionCache() (*cache.Store, error) {
+	if completionCache != nil {
+		return completionCache, nil
+	}
+
+	store, err := cache.Open(getDataDir(), cache.Options{
+		TTL:      cfg.Cache.TTL,
+		MaxBytes: int64(cfg.Cache.MaxSizeMB) * 1024 * 1024,
+	})
+	if err != nil {
+		return nil, err
+	}
+	completionCache = store
+	return store, nil
+}
+
+// commandHint describes a command invocation for backend routing. The
+// prompt size is a rough estimate since no tokenizer is chosen yet.
+func commandHint(name string, args []string, stdin string) backend.Hint {
+	hint := backend.Hint{
+		PromptTokens: (len(strings.Join(args, " ")) + len(stdin)) / 4,
+		Command:      name,
+	}
+	if c, ok := cmdRegistry.Get(name); ok {
+		hint.Category = string(c.Category())
+	}
+	return hint
 }
 
 func runRoot(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+	ctx := commandContext()
 	mode := DetectIOMode()
 
 	// Read stdin if piped
@@ -547,7 +747,9 @@ func runRoot(cmd *cobra.Command, args []string) error {
 	defer output.Close()
 
 	// Get the best available backend
-	activeBackend, err := getActiveBackend(ctx)
+	hint := commandHint("", append([]string{promptFlag}, args...), stdinContent)
+	hint.Command = "prompt"
+	activeBackend, err := resolveBackend(ctx, hint)
 	if err != nil {
 		// If user explicitly specified a backend, fail immediately
 		if backendFlag != "" {
@@ -649,6 +851,9 @@ func runPrompt(ctx context.Context, prompt, stdin string, mode *IOMode, output *
 		var contentBuffer strings.Builder
 		for chunk := range ch {
 			if chunk.Error != nil {
+				if ctx.Err() != nil {
+					break
+				}
 				return fmt.Errorf("stream error: %w", chunk.Error)
 			}
 			// Print chunk for real-time feedback
@@ -661,6 +866,14 @@ func runPrompt(ctx context.Context, prompt, stdin string, mode *IOMode, output *
 			}
 		}
 
+		// Interrupted: leave what was streamed as it is
+		if ctx.Err() != nil {
+			if contentBuffer.Len() > 0 {
+				fmt.Println()
+			}
+			return ctx.Err()
+		}
+
 		// Now apply markdown rendering to the complete response
 		// Clear the line and move cursor up to overwrite the streamed output
 		fullContent := contentBuffer.String()
@@ -784,14 +997,19 @@ func runREPL(execCtx *command.ExecContext) error {
 	// Simple REPL - for now just show help
 	helpCmd, _ := cmdRegistry.Get("help")
 	if helpCmd != nil {
-		_, _ = helpCmd.Execute(context.Background(), command.NewArgs(), execCtx)
+		_, _ = helpCmd.Execute(commandContext(), command.NewArgs(), execCtx)
 	}
 
 	return nil
 }
 
-// Execute runs the root command
+// Execute runs the root command. It returns ErrInterrupted when Ctrl-C
+// or SIGTERM stopped the command.
 func Execute() error {
+	ctx, stop := interruptContext()
+	defer stop()
+	runContext = ctx
+
 	// Intercept slash commands before cobra processes them
 	// Search all args for a slash command (not just position 1)
 	// BUT: Exclude paths that look like files (e.g., /tmp/file.py, /home/user/script.sh)
@@ -818,10 +1036,13 @@ func Execute() error {
 		// Pass everything except the executable and the slash command itself
 		// This includes flags before the slash command and args after it
 		allArgs := append(os.Args[1:slashIndex], os.Args[slashIndex+1:]...)
-		return runSlashCommand(slashCmd, allArgs)
+		if handled, err := forwardToDaemon(ctx, slashCmd, allArgs); handled {
+			return interrupted(ctx, err)
+		}
+		return interrupted(ctx, runSlashCommand(slashCmd, allArgs))
 	}
 
-	return rootCmd.Execute()
+	return interrupted(ctx, rootCmd.ExecuteContext(ctx))
 }
 
 // isLikelyFilePath determines if a string starting with / is a file path vs a slash command
@@ -863,7 +1084,13 @@ func runSlashCommand(cmd string, args []string) error {
 		return err
 	}
 
-	ctx := context.Background()
+	return execSlashCommand(cmdName, cmdArgs)
+}
+
+// execSlashCommand runs a slash command once flags are parsed and the
+// registries are set up
+func execSlashCommand(cmdName string, cmdArgs []string) error {
+	ctx := commandContext()
 	mode := DetectIOMode()
 
 	// Read stdin if piped
@@ -890,7 +1117,7 @@ func runSlashCommand(cmd string, args []string) error {
 	defer output.Close()
 
 	// Get the best available backend
-	activeBackend, err := getActiveBackend(ctx)
+	activeBackend, err := resolveBackend(ctx, commandHint(cmdName, cmdArgs, stdinContent))
 	if err != nil {
 		// If user explicitly specified a backend, fail immediately
 		if backendFlag != "" {
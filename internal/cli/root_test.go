@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSamplingTestCmd() *cobra.Command {
+	topPFlag, topKFlag, minPFlag, seedFlag = 0, 0, 0, 0
+	repeatPenaltyFlag, frequencyPenaltyFlag, presencePenaltyFlag = 0, 0, 0
+	logitBiasFlags = nil
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().Float64Var(&topPFlag, "top-p", 0, "")
+	cmd.Flags().IntVar(&topKFlag, "top-k", 0, "")
+	cmd.Flags().Int64Var(&seedFlag, "seed", 0, "")
+	cmd.Flags().StringArrayVar(&logitBiasFlags, "logit-bias", nil, "")
+	return cmd
+}
+
+func TestSamplingFromFlags(t *testing.T) {
+	cmd := newSamplingTestCmd()
+	require.NoError(t, cmd.ParseFlags([]string{"--top-p", "0.9", "--seed", "0", "--logit-bias", "50256=-100", "--logit-bias", "hi=2.5"}))
+
+	s, err := samplingFromFlags(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 0.9, s.TopP)
+	require.NotNil(t, s.Seed, "--seed 0 is an explicit seed")
+	assert.Equal(t, int64(0), *s.Seed)
+	assert.Equal(t, map[string]float64{"50256": -100, "hi": 2.5}, s.LogitBias)
+
+	cmd = newSamplingTestCmd()
+	require.NoError(t, cmd.ParseFlags(nil))
+	s, err = samplingFromFlags(cmd)
+	require.NoError(t, err)
+	assert.True(t, s.IsZero())
+}
+
+func TestSamplingFromFlags_Invalid(t *testing.T) {
+	cmd := newSamplingTestCmd()
+	require.NoError(t, cmd.ParseFlags([]string{"--logit-bias", "nobias"}))
+	_, err := samplingFromFlags(cmd)
+	assert.ErrorContains(t, err, "expected token=bias")
+
+	cmd = newSamplingTestCmd()
+	require.NoError(t, cmd.ParseFlags([]string{"--top-p", "1.5"}))
+	_, err = samplingFromFlags(cmd)
+	assert.ErrorContains(t, err, "top_p")
+}
@@ -1,7 +1,7 @@
 package cli
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,11 +13,14 @@ import (
 )
 
 var (
-	serverModelFlag   string
-	serverContextFlag int
-	serverGPUFlag     bool
-	serverCPUFlag     bool
-	serverTailFlag    int
+	serverModelFlag     string
+	serverContextFlag   int
+	serverGPUFlag       bool
+	serverCPUFlag       bool
+	serverTailFlag      int
+	serverSuperviseFlag bool
+	serverIdleFlag      time.Duration
+	serverJSONFlag      bool
 )
 
 var serverCmd = &cobra.Command{
@@ -45,7 +48,14 @@ Examples:
   scmd server start                    # Start with defaults
   scmd server start -m qwen2.5-3b      # Start with specific model
   scmd server start --cpu              # Start in CPU-only mode
-  scmd server start -c 2048            # Start with 2048 context size`,
+  scmd server start -c 2048            # Start with 2048 context size
+  scmd server start --supervise        # Keep it running in the foreground
+
+With --supervise, scmd stays in the foreground and keeps llama-server
+healthy: it restarts the server after crashes, rotates its log and stops
+it after the idle timeout (server.idle_timeout in config) to free memory.
+The next request starts it again. Run it in the background or as a
+service to keep a server on hand.`,
 	RunE: runServerStart,
 }
 
@@ -58,7 +68,13 @@ var serverStopCmd = &cobra.Command{
 var serverStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show llama-server status",
-	RunE:  runServerStatus,
+	Long: `Show whether llama-server is running, with its model, uptime, slot
+usage and, when supervised, restarts and idle timeout.
+
+Examples:
+  scmd server status
+  scmd server status --json`,
+	RunE: runServerStatus,
 }
 
 var serverRestartCmd = &cobra.Command{
@@ -91,17 +107,30 @@ func init() {
 	serverStartCmd.Flags().IntVarP(&serverContextFlag, "context", "c", 0, "context size (auto-detected if not specified)")
 	serverStartCmd.Flags().BoolVar(&serverGPUFlag, "gpu", false, "force GPU mode")
 	serverStartCmd.Flags().BoolVar(&serverCPUFlag, "cpu", false, "force CPU mode")
+	serverStartCmd.Flags().BoolVar(&serverSuperviseFlag, "supervise", false, "stay in the foreground, restarting the server after crashes and stopping it when idle")
+	serverStartCmd.Flags().DurationVar(&serverIdleFlag, "idle-timeout", 0, "with --supervise, stop the server after this long without requests (default from config; 0s never)")
+
+	// Flags for status command
+	serverStatusCmd.Flags().BoolVar(&serverJSONFlag, "json", false, "output status as JSON")
 
 	// Flags for logs command
 	serverLogsCmd.Flags().IntVar(&serverTailFlag, "tail", 100, "number of lines to show")
 }
 
 func runServerStart(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+	ctx := commandContext()
 	dataDir := getDataDir()
 
+	if st, ok := llamacpp.ReadSupervisorState(); ok {
+		fmt.Printf("✅ llama-server is supervised by PID %d (%s)\n", st.SupervisorPID, st.State)
+		return nil
+	}
+
 	// Check if server is already running
-	if isServerRunning() {
+	if llamacpp.IsServerRunning(8089) {
+		if serverSuperviseFlag {
+			return fmt.Errorf("llama-server is already running on port 8089; stop it first with: scmd server stop")
+		}
 		fmt.Println("✅ llama-server is already running on port 8089")
 		return nil
 	}
@@ -145,7 +174,6 @@ func runServerStart(cmd *cobra.Command, args []string) error {
 				modelSize = modelInfo.Size()
 			}
 			optimalConfig := llamacpp.CalculateOptimalConfig(resources, modelSize)
-			config.ContextSize = optimalConfig.ContextSize
 			config.GPULayers = optimalConfig.GPULayers
 
 			if config.GPULayers > 0 {
@@ -156,8 +184,18 @@ func runServerStart(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Native context length and thread count come from the model itself
+	if meta, err := llamacpp.ReadGGUFMetadata(modelPath); err == nil {
+		config.TuneForModel(meta)
+		fmt.Printf("  Model: %s %s, %s\n", meta.Architecture, meta.Parameters(), meta.Quantization())
+	}
+
 	fmt.Printf("  Context size: %d\n", config.ContextSize)
 	fmt.Println()
+
+	if serverSuperviseFlag {
+		return superviseServer(cmd, config, modelName)
+	}
 	fmt.Println("Starting server... (this may take a few seconds)")
 
 	// Start server
@@ -176,7 +214,46 @@ func runServerStart(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// superviseServer runs llama-server under a supervisor until interrupted
+func superviseServer(cmd *cobra.Command, config *llamacpp.ServerConfig, modelName string) error {
+	opts := llamacpp.DefaultSupervisorConfig()
+	if cfg != nil {
+		opts.HealthInterval = cfg.Server.HealthInterval
+		opts.IdleTimeout = cfg.Server.IdleTimeout
+		opts.MaxRestarts = cfg.Server.MaxRestarts
+		opts.LogMaxBytes = int64(cfg.Server.LogMaxSizeMB) << 20
+		opts.LogBackups = cfg.Server.LogBackups
+	}
+	if cmd.Flags().Changed("idle-timeout") {
+		opts.IdleTimeout = serverIdleFlag
+	}
+
+	ctx := commandContext()
+
+	fmt.Printf("Supervising llama-server (model %s, port %d)\n", modelName, config.Port)
+	if opts.IdleTimeout > 0 {
+		fmt.Printf("   Idle timeout: %s\n", opts.IdleTimeout)
+	}
+	fmt.Println("   Press Ctrl+C or run 'scmd server stop' to stop")
+	fmt.Println()
+
+	if err := llamacpp.NewSupervisor(config, opts).Run(ctx); err != nil {
+		return fmt.Errorf("supervise server: %w", err)
+	}
+	fmt.Println("✅ llama-server stopped")
+	return nil
+}
+
 func runServerStop(cmd *cobra.Command, args []string) error {
+	// A supervisor would restart a server stopped behind its back
+	if stopped, err := llamacpp.StopSupervisor(15 * time.Second); stopped {
+		if err != nil {
+			return err
+		}
+		fmt.Println("✅ llama-server and its supervisor stopped")
+		return nil
+	}
+
 	if !isServerRunning() {
 		fmt.Println("ℹ️  llama-server is not running")
 		return nil
@@ -198,31 +275,71 @@ func runServerStop(cmd *cobra.Command, args []string) error {
 }
 
 func runServerStatus(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ports := llamacpp.ServerPorts()
+	status := llamacpp.GetServerStatus(ports[0])
+	for _, port := range ports[1:] {
+		if other := llamacpp.GetServerStatus(port); other.Running {
+			status.Instances = append(status.Instances, other)
+		}
+	}
+
+	if serverJSONFlag {
+		data, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
 
 	fmt.Println("🔍 llama-server Status")
 	fmt.Println(strings.Repeat("─", 50))
 
-	if isServerRunning() {
+	if status.Running {
 		fmt.Println("Status: ✅ Running")
-		fmt.Println("Port:   8089")
-
-		// Try to read PID
-		pidPath := filepath.Join(dataDir, "llama-server.pid")
-		if pidData, err := os.ReadFile(pidPath); err == nil {
-			fmt.Printf("PID:    %s", string(pidData))
+		fmt.Printf("Port:   %d\n", status.Port)
+		if status.PID > 0 {
+			fmt.Printf("PID:    %d\n", status.PID)
 		}
-
-		// Check log file size
-		logPath := filepath.Join(dataDir, "logs", "llama-server.log")
-		if info, err := os.Stat(logPath); err == nil {
-			sizeKB := float64(info.Size()) / 1024
-			fmt.Printf("Logs:   %.1f KB\n", sizeKB)
+		if status.Model != "" {
+			fmt.Printf("Model:  %s\n", status.Model)
+		}
+		if status.UptimeSeconds > 0 {
+			fmt.Printf("Uptime: %s\n", time.Duration(status.UptimeSeconds)*time.Second)
+		}
+		if status.Slots != nil {
+			fmt.Printf("Slots:  %d of %d busy\n", status.Slots.Busy, status.Slots.Total)
 		}
+	} else if len(status.Instances) > 0 {
+		fmt.Println("Status: ✅ Running")
+	} else if status.Supervised {
+		fmt.Printf("Status: 💤 Stopped by supervisor (%s)\n", status.State)
+		fmt.Println("        Starts again on the next request")
 	} else {
 		fmt.Println("Status: ❌ Not running")
 		fmt.Println()
 		fmt.Println("Start with: scmd server start")
+		return nil
+	}
+
+	if status.Supervised {
+		fmt.Printf("Supervisor: PID %d, %d restart(s)", status.SupervisorPID, status.Restarts)
+		if status.IdleTimeout != "" {
+			fmt.Printf(", idle timeout %s", status.IdleTimeout)
+		}
+		fmt.Println()
+	}
+	if status.LogBytes > 0 {
+		fmt.Printf("Logs:   %.1f KB\n", float64(status.LogBytes)/1024)
+	}
+
+	// Servers for other resident models (server.max_models)
+	for _, other := range status.Instances {
+		fmt.Printf("Also:   %s on port %d", other.Model, other.Port)
+		if other.Slots != nil {
+			fmt.Printf(", %d of %d slots busy", other.Slots.Busy, other.Slots.Total)
+		}
+		fmt.Println()
 	}
 
 	return nil
@@ -231,6 +348,13 @@ func runServerStatus(cmd *cobra.Command, args []string) error {
 func runServerRestart(cmd *cobra.Command, args []string) error {
 	fmt.Println("Restarting llama-server...")
 
+	// The supervisor brings the server back with its own settings
+	if st, ok := llamacpp.ReadSupervisorState(); ok {
+		fmt.Printf("  Stopping server; supervisor (PID %d) will restart it\n", st.SupervisorPID)
+		llamacpp.StopServer()
+		return nil
+	}
+
 	// Stop if running
 	if isServerRunning() {
 		fmt.Println("  Stopping current server...")
@@ -278,6 +402,12 @@ func runServerLogs(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// isServerRunning reports whether any pooled llama-server is running
 func isServerRunning() bool {
-	return llamacpp.IsServerRunning(8089)
+	for _, port := range llamacpp.ServerPorts() {
+		if llamacpp.IsServerRunning(port) {
+			return true
+		}
+	}
+	return false
 }
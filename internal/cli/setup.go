@@ -2,7 +2,6 @@ package cli
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -339,7 +338,7 @@ func testBackend(modelName string) error {
 	llamaBackend := llamacpp.New(dataDir)
 
 	// Test if it's available
-	ctx := context.Background()
+	ctx := commandContext()
 
 	available, err := llamaBackend.IsAvailable(ctx)
 	if err != nil {
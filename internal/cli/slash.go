@@ -2,7 +2,6 @@ package cli
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"io"
 	"os"
@@ -40,7 +39,7 @@ var slashRunCmd = &cobra.Command{
   cat main.go | scmd slash run explain
   scmd slash run review --focus=security`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 
 		runner, err := getSlashRunner()
 		if err != nil {
@@ -262,7 +261,7 @@ var slashInteractiveCmd = &cobra.Command{
 	Short:   "Start interactive slash command mode",
 	Aliases: []string{"i", "repl"},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx := commandContext()
 
 		runner, err := getSlashRunner()
 		if err != nil {
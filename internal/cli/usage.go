@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/usage"
+)
+
+// Opened on first use by withUsage
+var usageStore *usage.Store
+
+// usageCmd reports logged token usage and spending
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and spending",
+	Long: `Show tokens used and their cost, as logged for every completion.
+
+Costs come from the usage.prices table in config; requests to backends
+without a price are counted but not costed.
+
+Examples:
+  scmd usage                      # last 30 days by day
+  scmd usage --by model           # group by model
+  scmd usage --since 2026-01-01 --by command`,
+	RunE: runUsage,
+}
+
+func init() {
+	usageCmd.Flags().String("since", "30d", "start of the report: a number of days (7d), hours (12h) or a date (YYYY-MM-DD)")
+	usageCmd.Flags().String("by", "day", "group by: day, command, backend, model")
+}
+
+func runUsage(cmd *cobra.Command, _ []string) error {
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	byFlag, _ := cmd.Flags().GetString("by")
+
+	since, err := parseSince(sinceFlag, time.Now())
+	if err != nil {
+		return err
+	}
+	groupBy, err := usage.ParseGroupBy(byFlag)
+	if err != nil {
+		return err
+	}
+
+	store, err := openUsageStore()
+	if err != nil {
+		return fmt.Errorf("open usage log: %w", err)
+	}
+	rows, err := store.Report(since, groupBy)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		fmt.Printf("No usage since %s.\n", since.Format("2006-01-02"))
+	} else {
+		var total usage.Row
+		unpriced := false
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tCOST\n", strings.ToUpper(byFlag))
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", r.Key, r.Requests, r.PromptTokens, r.CompletionTokens, formatCost(r))
+			total.Requests += r.Requests
+			total.PromptTokens += r.PromptTokens
+			total.CompletionTokens += r.CompletionTokens
+			total.Cost += r.Cost
+			total.Unpriced += r.Unpriced
+			unpriced = unpriced || r.Unpriced > 0
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%s\n", total.Requests, total.PromptTokens, total.CompletionTokens, formatCost(total))
+		w.Flush()
+
+		if unpriced {
+			fmt.Println("\n* includes requests to backends without a price in usage.prices")
+		}
+	}
+
+	return printBudget(store)
+}
+
+// formatCost shows a row's cost, marking rows with unpriced requests
+func formatCost(r usage.Row) string {
+	s := fmt.Sprintf("$%.4f", r.Cost)
+	if r.Unpriced > 0 {
+		s += "*"
+	}
+	return s
+}
+
+// printBudget shows spending against the configured budget
+func printBudget(store *usage.Store) error {
+	b := budgetFromConfig()
+	if b.IsZero() {
+		return nil
+	}
+
+	now := time.Now()
+	y, m, d := now.Date()
+	fmt.Printf("\nBudget (%s):\n", cfg.Usage.Budget.Action)
+	for _, p := range []struct {
+		label string
+		limit float64
+		start time.Time
+	}{
+		{"Today:", b.Daily, time.Date(y, m, d, 0, 0, 0, 0, now.Location())},
+		{"Month:", b.Monthly, time.Date(y, m, 1, 0, 0, 0, 0, now.Location())},
+	} {
+		if p.limit <= 0 {
+			continue
+		}
+		spent, err := store.Spent(p.start)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  %-8s $%.4f of $%.2f\n", p.label, spent, p.limit)
+	}
+	return nil
+}
+
+// parseSince reads --since: "Nd", a Go duration, or a local date
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: expected e.g. 7d, 12h or 2026-01-01", s)
+}
+
+// withUsage wraps b to log its usage under command and enforce the
+// budget, unless tracking is disabled or b is the mock or replay backend
+func withUsage(b backend.Backend, command string) backend.Backend {
+	if cfg == nil || !cfg.Usage.Enabled || b.Type() == backend.TypeMock || b.Type() == backend.TypeReplay {
+		return b
+	}
+
+	store, err := openUsageStore()
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Warning: usage log unavailable: %v\n", err)
+		}
+		return b
+	}
+	return usage.Wrap(b, store, usage.Options{
+		Command: command,
+		Prices:  pricesFromConfig(),
+		Budget:  budgetFromConfig(),
+	})
+}
+
+// openUsageStore opens the usage log
+func openUsageStore() (*usage.Store, error) {
+	if usageStore != nil {
+		return usageStore, nil
+	}
+
+	store, err := usage.Open(getDataDir())
+	if err != nil {
+		return nil, err
+	}
+	usageStore = store
+	return store, nil
+}
+
+// pricesFromConfig converts usage.prices to a price table
+func pricesFromConfig() usage.PriceTable {
+	table := make(usage.PriceTable, 0, len(cfg.Usage.Prices))
+	for _, p := range cfg.Usage.Prices {
+		table = append(table, usage.PriceEntry{
+			Backend: p.Backend,
+			Model:   p.Model,
+			Price:   usage.Price{Input: p.Input, Output: p.Output},
+		})
+	}
+	return table
+}
+
+// budgetFromConfig converts usage.budget, warning about an invalid action
+func budgetFromConfig() usage.Budget {
+	b := cfg.Usage.Budget
+	if err := b.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, using warn\n", err)
+	}
+	return usage.Budget{Daily: b.Daily, Monthly: b.Monthly, Refuse: b.Action == "refuse"}
+}
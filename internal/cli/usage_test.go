@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+
+	tests := map[string]time.Time{
+		"7d":         now.AddDate(0, 0, -7),
+		"12h":        now.Add(-12 * time.Hour),
+		"2026-01-01": time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local),
+	}
+	for in, want := range tests {
+		got, err := parseSince(in, now)
+		require.NoError(t, err, in)
+		assert.True(t, want.Equal(got), "%s: got %s", in, got)
+	}
+
+	for _, in := range []string{"", "week", "-3d", "2026-13-01"} {
+		_, err := parseSince(in, now)
+		assert.Error(t, err, in)
+	}
+}
@@ -93,6 +93,11 @@ func (c *ConfigCommand) showAllConfig(execCtx *command.ExecContext) (*command.Re
 	sb.WriteString(fmt.Sprintf("    directory: %s\n", cfg.Models.Directory))
 	sb.WriteString(fmt.Sprintf("    auto_download: %t\n", cfg.Models.AutoDownload))
 
+	sb.WriteString("\n  cache:\n")
+	sb.WriteString(fmt.Sprintf("    enabled: %t\n", cfg.Cache.Enabled))
+	sb.WriteString(fmt.Sprintf("    ttl: %s\n", cfg.Cache.TTL))
+	sb.WriteString(fmt.Sprintf("    max_size_mb: %d\n", cfg.Cache.MaxSizeMB))
+
 	sb.WriteString(fmt.Sprintf("\nConfig file: %s\n", config.ConfigPath()))
 
 	execCtx.UI.Write(sb.String())
@@ -127,6 +132,8 @@ func (c *ConfigCommand) setConfig(key, value string, execCtx *command.ExecContex
 		"ui.colors":                     "bool",
 		"ui.verbose":                    "bool",
 		"models.auto_download":          "bool",
+		"cache.enabled":                 "bool",
+		"cache.max_size_mb":             "int",
 	}
 
 	keyType, valid := validKeys[key]
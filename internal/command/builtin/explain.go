@@ -108,6 +108,7 @@ func (c *ExplainCommand) Execute(
 	// Check for template
 	templateName := args.GetOption("template")
 	var systemPrompt, prompt string
+	var sampling backend.Sampling
 
 	if templateName != "" {
 		// Load and execute template
@@ -128,7 +129,10 @@ func (c *ExplainCommand) Execute(
 		}
 
 		var userPrompt string
-		systemPrompt, userPrompt, err = mgr.Execute(templateName, templateData)
+		tpl, err := mgr.Load(templateName)
+		if err == nil {
+			systemPrompt, userPrompt, err = tpl.Execute(templateData)
+		}
 		if err != nil {
 			return command.NewErrorResult(
 				fmt.Sprintf("failed to execute template: %v", err),
@@ -136,6 +140,7 @@ func (c *ExplainCommand) Execute(
 			), nil
 		}
 		prompt = userPrompt
+		sampling = tpl.Sampling
 	} else {
 		// Build default prompt
 		prompt = buildExplainPrompt(content, subject)
@@ -161,6 +166,7 @@ Use examples where helpful. Format your response in markdown.`
 		MaxTokens:   2048,
 		Temperature: 0.3,
 		SystemPrompt: systemPrompt,
+		Sampling:     sampling,
 	}
 
 	resp, err := execCtx.Backend.Complete(ctx, req)
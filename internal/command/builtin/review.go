@@ -109,6 +109,7 @@ func (c *ReviewCommand) Execute(
 	// Check for template
 	templateName := args.GetOption("template")
 	var systemPrompt, prompt string
+	var sampling backend.Sampling
 
 	if templateName != "" {
 		// Load and execute template
@@ -129,7 +130,10 @@ func (c *ReviewCommand) Execute(
 		}
 
 		var userPrompt string
-		systemPrompt, userPrompt, err = mgr.Execute(templateName, templateData)
+		tpl, err := mgr.Load(templateName)
+		if err == nil {
+			systemPrompt, userPrompt, err = tpl.Execute(templateData)
+		}
 		if err != nil {
 			return command.NewErrorResult(
 				fmt.Sprintf("failed to execute template: %v", err),
@@ -137,6 +141,7 @@ func (c *ReviewCommand) Execute(
 			), nil
 		}
 		prompt = userPrompt
+		sampling = tpl.Sampling
 	} else {
 		// Build default prompt
 		prompt = buildReviewPrompt(content, subject, focus)
@@ -166,6 +171,7 @@ Format your response in markdown with sections for each category.`
 		MaxTokens:   4096,
 		Temperature: 0.3,
 		SystemPrompt: systemPrompt,
+		Sampling:     sampling,
 	}
 
 	resp, err := execCtx.Backend.Complete(ctx, req)
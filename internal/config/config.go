@@ -3,8 +3,10 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Config represents scmd configuration
@@ -13,13 +15,81 @@ type Config struct {
 	Backends       BackendsConfig `mapstructure:"backends"`
 	UI             UIConfig       `mapstructure:"ui"`
 	Models         ModelsConfig   `mapstructure:"models"`
+	Cache          CacheConfig    `mapstructure:"cache"`
+	Usage          UsageConfig    `mapstructure:"usage"`
+	Server         ServerConfig   `mapstructure:"server"`
 	SetupCompleted bool           `mapstructure:"setup_completed"`
 }
 
 // BackendsConfig for LLM backends
 type BackendsConfig struct {
-	Default string             `mapstructure:"default"`
-	Local   LocalBackendConfig `mapstructure:"local"`
+	Default  string             `mapstructure:"default"`
+	Fallback []string           `mapstructure:"fallback"` // tried in order when the default is unavailable
+	Routes   []RouteConfig      `mapstructure:"routes"`
+	Local    LocalBackendConfig `mapstructure:"local"`
+
+	// Providers are named OpenAI-compatible endpoints, each selectable
+	// with --backend <name>
+	Providers map[string]ProviderConfig `mapstructure:"providers"`
+
+	// Retry applies to all remote backends; RateLimits are keyed by
+	// backend name
+	Retry      RetryConfig                `mapstructure:"retry"`
+	RateLimits map[string]RateLimitConfig `mapstructure:"rate_limits"`
+}
+
+// RetryConfig controls retries of failed remote requests
+type RetryConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts" yaml:"max_attempts"` // 1 disables retries
+	BaseDelay   time.Duration `mapstructure:"base_delay" yaml:"base_delay"`
+	MaxDelay    time.Duration `mapstructure:"max_delay" yaml:"max_delay"`
+	Deadline    time.Duration `mapstructure:"deadline" yaml:"deadline"` // across all attempts; 0 = none
+}
+
+// RateLimitConfig is a client-side budget for one backend
+type RateLimitConfig struct {
+	RequestsPerMinute int `mapstructure:"requests_per_minute" yaml:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `mapstructure:"tokens_per_minute" yaml:"tokens_per_minute,omitempty"`
+}
+
+// RouteConfig picks a backend by command category or estimated prompt size
+type RouteConfig struct {
+	Category  string `mapstructure:"category" yaml:"category,omitempty"`
+	MinTokens int    `mapstructure:"min_tokens" yaml:"min_tokens,omitempty"`
+	MaxTokens int    `mapstructure:"max_tokens" yaml:"max_tokens,omitempty"`
+	Backend   string `mapstructure:"backend" yaml:"backend"`
+	Model     string `mapstructure:"model" yaml:"model,omitempty"` // llama.cpp model for matching requests
+}
+
+// ProviderConfig describes an OpenAI-compatible endpoint such as vLLM,
+// LM Studio, an internal gateway or Azure OpenAI
+type ProviderConfig struct {
+	BaseURL   string `mapstructure:"base_url" yaml:"base_url"`
+	Model     string `mapstructure:"model" yaml:"model,omitempty"`
+	APIKeyEnv string `mapstructure:"api_key_env" yaml:"api_key_env,omitempty"` // env var holding the key; none means no auth
+
+	// Headers are sent with every request. Values may reference
+	// environment variables as $VAR or ${VAR}.
+	Headers map[string]string `mapstructure:"headers" yaml:"headers,omitempty"`
+
+	Timeout     time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
+	MaxTokens   int           `mapstructure:"max_tokens" yaml:"max_tokens,omitempty"` // default when a request sets none
+	ToolCalling *bool         `mapstructure:"tool_calling" yaml:"tool_calling,omitempty"`
+}
+
+// Validate checks a provider entry
+func (p *ProviderConfig) Validate(name string) error {
+	u, err := url.Parse(p.BaseURL)
+	if p.BaseURL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("provider %s: base_url must be an http(s) URL, got %q", name, p.BaseURL)
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("provider %s: timeout must not be negative", name)
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("provider %s: max_tokens must not be negative", name)
+	}
+	return nil
 }
 
 // LocalBackendConfig for local llama.cpp
@@ -43,8 +113,59 @@ type UIConfig struct {
 
 // ModelsConfig for model management
 type ModelsConfig struct {
-	Directory    string `mapstructure:"directory"`
-	AutoDownload bool   `mapstructure:"auto_download"`
+	Directory      string `mapstructure:"directory"`
+	AutoDownload   bool   `mapstructure:"auto_download"`
+	HuggingFaceURL string `mapstructure:"huggingface_url"` // Hub for search and hf: pulls, e.g. a mirror
+}
+
+// CacheConfig for the completion cache
+type CacheConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	TTL       time.Duration `mapstructure:"ttl"`
+	MaxSizeMB int           `mapstructure:"max_size_mb"`
+}
+
+// ServerConfig for llama-server: the pool of resident models and the
+// supervisor (scmd server start --supervise)
+type ServerConfig struct {
+	MaxModels      int           `mapstructure:"max_models"` // llama-servers kept running at once, one model each
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+	IdleTimeout    time.Duration `mapstructure:"idle_timeout"` // 0 keeps the server running
+	MaxRestarts    int           `mapstructure:"max_restarts"` // consecutive crashes; 0 = unlimited
+	LogMaxSizeMB   int           `mapstructure:"log_max_size_mb"`
+	LogBackups     int           `mapstructure:"log_backups"`
+}
+
+// UsageConfig for token usage tracking and spending budgets
+type UsageConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Prices  []PriceConfig `mapstructure:"prices"`
+	Budget  BudgetConfig  `mapstructure:"budget"`
+}
+
+// PriceConfig prices a backend's model in USD per million tokens. An
+// empty or "*" model applies to every model of the backend.
+type PriceConfig struct {
+	Backend string  `mapstructure:"backend" yaml:"backend"`
+	Model   string  `mapstructure:"model" yaml:"model,omitempty"`
+	Input   float64 `mapstructure:"input" yaml:"input"`
+	Output  float64 `mapstructure:"output" yaml:"output"`
+}
+
+// BudgetConfig limits spending on priced backends, in USD
+type BudgetConfig struct {
+	Daily   float64 `mapstructure:"daily" yaml:"daily,omitempty"`
+	Monthly float64 `mapstructure:"monthly" yaml:"monthly,omitempty"`
+	Action  string  `mapstructure:"action" yaml:"action,omitempty"` // "warn" (default) or "refuse"
+}
+
+// Validate checks the budget action
+func (b *BudgetConfig) Validate() error {
+	switch b.Action {
+	case "", "warn", "refuse":
+		return nil
+	}
+	return fmt.Errorf("usage.budget.action %q: must be warn or refuse", b.Action)
 }
 
 // DataDir returns the scmd data directory
@@ -92,6 +213,8 @@ func (c *Config) GetBool(key string) bool {
 		return c.UI.Verbose
 	case "models.auto_download":
 		return c.Models.AutoDownload
+	case "cache.enabled":
+		return c.Cache.Enabled
 	case "setup_completed":
 		return c.SetupCompleted
 	default:
@@ -110,6 +233,8 @@ func (c *Config) GetInt(key string) int {
 		return c.Backends.Local.Threads
 	case "ui.word_wrap":
 		return c.UI.WordWrap
+	case "cache.max_size_mb":
+		return c.Cache.MaxSizeMB
 	default:
 		return 0
 	}
@@ -183,6 +308,18 @@ func (c *Config) Set(key string, value interface{}) error {
 			return nil
 		}
 		return fmt.Errorf("value must be a boolean")
+	case "cache.enabled":
+		if v, ok := value.(bool); ok {
+			c.Cache.Enabled = v
+			return nil
+		}
+		return fmt.Errorf("value must be a boolean")
+	case "cache.max_size_mb":
+		if v, ok := value.(int); ok {
+			c.Cache.MaxSizeMB = v
+			return nil
+		}
+		return fmt.Errorf("value must be an integer")
 	case "setup_completed":
 		if v, ok := value.(bool); ok {
 			c.SetupCompleted = v
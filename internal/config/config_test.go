@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -32,6 +33,7 @@ func TestDefault(t *testing.T) {
 	assert.True(t, cfg.UI.Colors)
 	assert.False(t, cfg.UI.Verbose)
 	assert.True(t, cfg.Models.AutoDownload)
+	assert.Equal(t, "https://huggingface.co", cfg.Models.HuggingFaceURL)
 }
 
 func TestConfig_GetString(t *testing.T) {
@@ -76,3 +78,159 @@ func TestEnsureDataDir(t *testing.T) {
 	_, err = os.Stat(DataDir())
 	assert.NoError(t, err)
 }
+
+func TestLoad_FallbackAndRoutes(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SCMD_DATA_DIR", dir)
+
+	yaml := `backends:
+  default: llamacpp
+  fallback: [ollama, groq]
+  routes:
+    - category: git
+      backend: groq
+    - min_tokens: 6000
+      backend: claude
+    - category: code
+      backend: llamacpp
+      model: qwen2.5-coder-7b
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0644))
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ollama", "groq"}, cfg.Backends.Fallback)
+	assert.Equal(t, []RouteConfig{
+		{Category: "git", Backend: "groq"},
+		{MinTokens: 6000, Backend: "claude"},
+		{Category: "code", Backend: "llamacpp", Model: "qwen2.5-coder-7b"},
+	}, cfg.Backends.Routes)
+
+	// Routes survive a save/load round trip
+	assert.NoError(t, Save(cfg))
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 6000, cfg.Backends.Routes[1].MinTokens)
+}
+
+func TestLoad_Providers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SCMD_DATA_DIR", dir)
+
+	yaml := `backends:
+  providers:
+    vllm:
+      base_url: http://gpu-box:8000/v1
+      model: Qwen/Qwen2.5-Coder-32B-Instruct
+      timeout: 10m
+      tool_calling: true
+    azure:
+      base_url: https://acme.openai.azure.com/openai/deployments/gpt-4o?api-version=2024-10-21
+      headers:
+        api-key: ${AZURE_OPENAI_KEY}
+      max_tokens: 4096
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0644))
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Backends.Providers, 2)
+
+	vllm := cfg.Backends.Providers["vllm"]
+	assert.Equal(t, "http://gpu-box:8000/v1", vllm.BaseURL)
+	assert.Equal(t, 10*time.Minute, vllm.Timeout)
+	assert.NotNil(t, vllm.ToolCalling)
+	assert.NoError(t, vllm.Validate("vllm"))
+
+	azure := cfg.Backends.Providers["azure"]
+	assert.Equal(t, "${AZURE_OPENAI_KEY}", azure.Headers["api-key"])
+	assert.Equal(t, 4096, azure.MaxTokens)
+
+	// Providers survive a save/load round trip
+	assert.NoError(t, Save(cfg))
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, cfg.Backends.Providers["vllm"].Timeout)
+	assert.Equal(t, 4096, cfg.Backends.Providers["azure"].MaxTokens)
+}
+
+func TestProviderConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&ProviderConfig{BaseURL: "http://localhost:1234/v1"}).Validate("lmstudio"))
+	assert.Error(t, (&ProviderConfig{}).Validate("x"))
+	assert.Error(t, (&ProviderConfig{BaseURL: "localhost:1234"}).Validate("x"))
+	assert.Error(t, (&ProviderConfig{BaseURL: "http://x", MaxTokens: -1}).Validate("x"))
+}
+
+func TestLoad_RetryAndRateLimits(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SCMD_DATA_DIR", dir)
+
+	yaml := `backends:
+  retry:
+    max_attempts: 6
+    deadline: 5m
+  rate_limits:
+    groq:
+      requests_per_minute: 30
+      tokens_per_minute: 6000
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0644))
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, cfg.Backends.Retry.MaxAttempts)
+	assert.Equal(t, 5*time.Minute, cfg.Backends.Retry.Deadline)
+	assert.Equal(t, 500*time.Millisecond, cfg.Backends.Retry.BaseDelay, "unset fields keep their defaults")
+	assert.Equal(t, RateLimitConfig{RequestsPerMinute: 30, TokensPerMinute: 6000}, cfg.Backends.RateLimits["groq"])
+}
+
+func TestLoad_Usage(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SCMD_DATA_DIR", dir)
+
+	yaml := `usage:
+  prices:
+    - backend: openai
+      model: gpt-4o-mini
+      input: 0.15
+      output: 0.6
+    - backend: claude
+      input: 3
+      output: 15
+  budget:
+    daily: 2.5
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0644))
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.True(t, cfg.Usage.Enabled)
+	assert.Equal(t, []PriceConfig{
+		{Backend: "openai", Model: "gpt-4o-mini", Input: 0.15, Output: 0.6},
+		{Backend: "claude", Input: 3, Output: 15},
+	}, cfg.Usage.Prices)
+	assert.Equal(t, BudgetConfig{Daily: 2.5, Action: "warn"}, cfg.Usage.Budget)
+
+	assert.NoError(t, cfg.Usage.Budget.Validate())
+	assert.Error(t, (&BudgetConfig{Action: "block"}).Validate())
+}
+
+func TestLoad_Server(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SCMD_DATA_DIR", dir)
+
+	yaml := `server:
+  max_models: 3
+  idle_timeout: 10m
+  max_restarts: 0
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0644))
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cfg.Server.MaxModels)
+	assert.Equal(t, 10*time.Minute, cfg.Server.IdleTimeout)
+	assert.Equal(t, 0, cfg.Server.MaxRestarts)
+	assert.Equal(t, 10*time.Second, cfg.Server.HealthInterval, "unset fields keep their defaults")
+	assert.Equal(t, 10, cfg.Server.LogMaxSizeMB)
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"path/filepath"
+	"time"
 )
 
 // Default returns default configuration
@@ -16,6 +17,12 @@ func Default() *Config {
 				GPULayers:     0,
 				Threads:       0,
 			},
+			Retry: RetryConfig{
+				MaxAttempts: 4,
+				BaseDelay:   500 * time.Millisecond,
+				MaxDelay:    30 * time.Second,
+				Deadline:    2 * time.Minute,
+			},
 		},
 		UI: UIConfig{
 			Streaming: true,
@@ -26,8 +33,26 @@ func Default() *Config {
 			WordWrap:  80,
 		},
 		Models: ModelsConfig{
-			Directory:    filepath.Join(DataDir(), "models"),
-			AutoDownload: true,
+			Directory:      filepath.Join(DataDir(), "models"),
+			AutoDownload:   true,
+			HuggingFaceURL: "https://huggingface.co",
+		},
+		Cache: CacheConfig{
+			Enabled:   true,
+			TTL:       7 * 24 * time.Hour,
+			MaxSizeMB: 100,
+		},
+		Server: ServerConfig{
+			MaxModels:      2,
+			HealthInterval: 10 * time.Second,
+			IdleTimeout:    30 * time.Minute,
+			MaxRestarts:    5,
+			LogMaxSizeMB:   10,
+			LogBackups:     3,
+		},
+		Usage: UsageConfig{
+			Enabled: true,
+			Budget:  BudgetConfig{Action: "warn"},
 		},
 	}
 }
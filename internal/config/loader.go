@@ -19,11 +19,27 @@ func Load() (*Config, error) {
 	v.SetDefault("backends.local.context_length", defaults.Backends.Local.ContextLength)
 	v.SetDefault("backends.local.gpu_layers", defaults.Backends.Local.GPULayers)
 	v.SetDefault("backends.local.threads", defaults.Backends.Local.Threads)
+	v.SetDefault("backends.retry.max_attempts", defaults.Backends.Retry.MaxAttempts)
+	v.SetDefault("backends.retry.base_delay", defaults.Backends.Retry.BaseDelay)
+	v.SetDefault("backends.retry.max_delay", defaults.Backends.Retry.MaxDelay)
+	v.SetDefault("backends.retry.deadline", defaults.Backends.Retry.Deadline)
 	v.SetDefault("ui.streaming", defaults.UI.Streaming)
 	v.SetDefault("ui.colors", defaults.UI.Colors)
 	v.SetDefault("ui.verbose", defaults.UI.Verbose)
 	v.SetDefault("models.directory", defaults.Models.Directory)
 	v.SetDefault("models.auto_download", defaults.Models.AutoDownload)
+	v.SetDefault("models.huggingface_url", defaults.Models.HuggingFaceURL)
+	v.SetDefault("cache.enabled", defaults.Cache.Enabled)
+	v.SetDefault("cache.ttl", defaults.Cache.TTL)
+	v.SetDefault("cache.max_size_mb", defaults.Cache.MaxSizeMB)
+	v.SetDefault("usage.enabled", defaults.Usage.Enabled)
+	v.SetDefault("server.max_models", defaults.Server.MaxModels)
+	v.SetDefault("server.health_interval", defaults.Server.HealthInterval)
+	v.SetDefault("server.idle_timeout", defaults.Server.IdleTimeout)
+	v.SetDefault("server.max_restarts", defaults.Server.MaxRestarts)
+	v.SetDefault("server.log_max_size_mb", defaults.Server.LogMaxSizeMB)
+	v.SetDefault("server.log_backups", defaults.Server.LogBackups)
+	v.SetDefault("usage.budget.action", defaults.Usage.Budget.Action)
 
 	// Config file
 	v.SetConfigName("config")
@@ -62,6 +78,7 @@ func Save(cfg *Config) error {
 	v.Set("backends", cfg.Backends)
 	v.Set("ui", cfg.UI)
 	v.Set("models", cfg.Models)
+	v.Set("cache", cfg.Cache)
 
 	return v.WriteConfigAs(filepath.Join(dir, "config.yaml"))
 }
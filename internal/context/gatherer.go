@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -20,9 +21,13 @@ type ContextSpec struct {
 	MaxTokens int      // Max context tokens
 }
 
+// TokenCounter counts the tokens in a piece of text
+type TokenCounter func(text string) int
+
 // Gatherer collects context for commands
 type Gatherer struct {
-	workDir string
+	workDir     string
+	countTokens TokenCounter
 }
 
 // NewGatherer creates a new context gatherer
@@ -31,16 +36,32 @@ func NewGatherer(workDir string) *Gatherer {
 		workDir, _ = os.Getwd()
 	}
 	return &Gatherer{
-		workDir: workDir,
+		workDir:     workDir,
+		countTokens: estimateTokens,
 	}
 }
 
+// SetTokenCounter replaces the ~4 characters per token heuristic, usually
+// with the active backend's EstimateTokens
+func (g *Gatherer) SetTokenCounter(counter TokenCounter) {
+	if counter != nil {
+		g.countTokens = counter
+	}
+}
+
+// estimateTokens is the default heuristic: ~4 chars per token
+func estimateTokens(text string) int {
+	return len(text) / 4
+}
+
 // Context represents gathered context
 type Context struct {
 	Files       map[string]string // filename -> content
 	GitInfo     *GitInfo
 	Environment map[string]string
-	TotalTokens int // Approximate token count
+	TotalTokens int // Token count of Format's output sections
+
+	fileTokens map[string]int // Per-file token counts, used for truncation
 }
 
 // GitInfo contains git repository context
@@ -193,13 +214,16 @@ func (g *Gatherer) gatherEnv(envVars []string, result *Context) {
 	}
 }
 
-// estimateTokens provides a rough token count estimate
+// estimateTokens counts the tokens of the gathered context
 func (g *Gatherer) estimateTokens(ctx *Context) int {
 	tokens := 0
 
-	// Files: ~4 chars per token
-	for _, content := range ctx.Files {
-		tokens += len(content) / 4
+	// Files are counted individually so truncation knows what each frees
+	ctx.fileTokens = make(map[string]int, len(ctx.Files))
+	for path, content := range ctx.Files {
+		n := g.countTokens(content)
+		ctx.fileTokens[path] = n
+		tokens += n
 	}
 
 	// Git info: rough estimate
@@ -210,7 +234,7 @@ func (g *Gatherer) estimateTokens(ctx *Context) int {
 
 	// Environment variables
 	for k, v := range ctx.Environment {
-		tokens += (len(k) + len(v)) / 4
+		tokens += g.countTokens(k + v)
 	}
 
 	return tokens
@@ -220,35 +244,31 @@ func (g *Gatherer) estimateTokens(ctx *Context) int {
 func (g *Gatherer) truncateContext(ctx *Context, maxTokens int) {
 	currentTokens := ctx.TotalTokens
 
-	// Strategy: remove files first (largest), starting with largest files
+	// Strategy: remove files first, starting with the largest
 	if currentTokens > maxTokens && len(ctx.Files) > 0 {
 		type fileSize struct {
-			path string
-			size int
+			path   string
+			tokens int
 		}
 
 		var files []fileSize
-		for path, content := range ctx.Files {
-			files = append(files, fileSize{path, len(content)})
+		for path := range ctx.Files {
+			files = append(files, fileSize{path, ctx.fileTokens[path]})
 		}
 
-		// Sort by size (largest first)
-		for i := 0; i < len(files); i++ {
-			for j := i + 1; j < len(files); j++ {
-				if files[j].size > files[i].size {
-					files[i], files[j] = files[j], files[i]
-				}
-			}
-		}
+		// Sort by token count (largest first)
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].tokens > files[j].tokens
+		})
 
 		// Remove files until we're under limit
 		for _, f := range files {
 			if currentTokens <= maxTokens {
 				break
 			}
-			tokensFreed := f.size / 4
 			delete(ctx.Files, f.path)
-			currentTokens -= tokensFreed
+			delete(ctx.fileTokens, f.path)
+			currentTokens -= f.tokens
 		}
 	}
 
@@ -0,0 +1,44 @@
+package context
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatherer_TokenCounter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one two three"), 0644))
+
+	g := NewGatherer(dir)
+	g.SetTokenCounter(func(text string) int {
+		return len(strings.Fields(text))
+	})
+
+	result, err := g.Gather(context.Background(), &ContextSpec{Files: []string{"*.txt"}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.TotalTokens)
+}
+
+func TestGatherer_TruncateByTokens(t *testing.T) {
+	dir := t.TempDir()
+	// big.txt has more characters but fewer tokens than small.txt
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "big.txt"), []byte(strings.Repeat("x", 400)), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "small.txt"), []byte(strings.Repeat("a ", 50)), 0644))
+
+	g := NewGatherer(dir)
+	g.SetTokenCounter(func(text string) int {
+		return len(strings.Fields(text))
+	})
+
+	result, err := g.Gather(context.Background(), &ContextSpec{Files: []string{"*.txt"}, MaxTokens: 10})
+	require.NoError(t, err)
+	assert.Contains(t, result.Files, "big.txt")
+	assert.NotContains(t, result.Files, "small.txt")
+	assert.Equal(t, 1, result.TotalTokens)
+}
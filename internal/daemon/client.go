@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// dialTimeout bounds connecting to the socket. A daemon answers at once,
+// so anything slower means none is running.
+const dialTimeout = time.Second
+
+// Client sends requests to the daemon listening on a socket
+type Client struct {
+	path string
+}
+
+// NewClient creates a client for the socket at path
+func NewClient(path string) *Client {
+	return &Client{path: path}
+}
+
+// Status returns the daemon's status. An error means no daemon answers.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	f, err := c.call(ctx, &Request{Op: OpStatus}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.Status == nil {
+		return nil, fmt.Errorf("daemon sent no status")
+	}
+	return f.Status, nil
+}
+
+// Stop asks the daemon to shut down
+func (c *Client) Stop(ctx context.Context) error {
+	_, err := c.call(ctx, &Request{Op: OpStop}, nil)
+	return err
+}
+
+// Run runs req on the daemon, copying its output to stdout and stderr as
+// it arrives, and returns the command's error. Cancelling ctx hangs up,
+// which cancels the command in the daemon.
+func (c *Client) Run(ctx context.Context, req *Request, stdout, stderr io.Writer) error {
+	req.Op = OpRun
+	f, err := c.call(ctx, req, func(f *Frame) {
+		if f.Stdout != "" {
+			io.WriteString(stdout, f.Stdout)
+		}
+		if f.Stderr != "" {
+			io.WriteString(stderr, f.Stderr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if f.Error != "" {
+		return errors.New(f.Error)
+	}
+	return nil
+}
+
+// call sends req and reads frames, passing output frames to onFrame,
+// until the last one, which it returns
+func (c *Client) call(ctx context.Context, req *Request, onFrame func(*Frame)) (*Frame, error) {
+	var d net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	conn, err := d.DialContext(dialCtx, "unix", c.path)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("connect to daemon: %w", err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var f Frame
+		if err := dec.Decode(&f); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("read daemon response: %w", err)
+		}
+		if f.Done {
+			return &f, nil
+		}
+		if onFrame != nil {
+			onFrame(&f)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves handler on a socket in a short temp directory, since
+// socket paths are limited to about 100 bytes
+func startServer(t *testing.T, handler Handler) (*Client, <-chan error) {
+	dir, err := os.MkdirTemp("", "scmd-daemon")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := SocketPath(dir)
+
+	l, err := Listen(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(handler, "1.0-test").Serve(ctx, l)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return NewClient(path), done
+}
+
+func TestClient_Run(t *testing.T) {
+	client, _ := startServer(t, func(ctx context.Context, req *Request, stdout, stderr io.Writer) error {
+		fmt.Fprintf(stdout, "args=%s stdin=%s tty=%v\n", strings.Join(req.Args, ","), req.Stdin, req.StdoutTTY)
+		fmt.Fprintln(stderr, "progress")
+		if req.Args[0] == "/fail" {
+			return errors.New("command failed")
+		}
+		return nil
+	})
+
+	var stdout, stderr bytes.Buffer
+	err := client.Run(context.Background(), &Request{Args: []string{"/e", "main.go"}, Stdin: "code", StdoutTTY: true}, &stdout, &stderr)
+	require.NoError(t, err)
+	assert.Equal(t, "args=/e,main.go stdin=code tty=true\n", stdout.String())
+	assert.Equal(t, "progress\n", stderr.String())
+
+	err = client.Run(context.Background(), &Request{Args: []string{"/fail"}}, io.Discard, io.Discard)
+	assert.EqualError(t, err, "command failed")
+
+	st, err := client.Status(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), st.PID)
+	assert.Equal(t, "1.0-test", st.Version)
+	assert.Equal(t, int64(2), st.Requests)
+}
+
+func TestClient_RunCancel(t *testing.T) {
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	client, _ := startServer(t, func(ctx context.Context, req *Request, stdout, stderr io.Writer) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- client.Run(ctx, &Request{Args: []string{"/slow"}}, io.Discard, io.Discard) }()
+
+	<-started
+	cancel()
+	assert.ErrorIs(t, <-errc, context.Canceled)
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hanging up did not cancel the command")
+	}
+}
+
+func TestClient_Stop(t *testing.T) {
+	client, done := startServer(t, nil)
+
+	require.NoError(t, client.Stop(context.Background()))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not stop")
+	}
+
+	_, err := client.Status(context.Background())
+	assert.Error(t, err, "no daemon answers after stop")
+}
+
+func TestListen(t *testing.T) {
+	client, _ := startServer(t, nil)
+
+	// A running daemon keeps its socket
+	_, err := Listen(client.path)
+	assert.ErrorContains(t, err, "already running")
+
+	// A stale socket file is replaced
+	dir, err := os.MkdirTemp("", "scmd-daemon")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stale := filepath.Join(dir, SocketName)
+	require.NoError(t, os.WriteFile(stale, nil, 0600))
+
+	l, err := Listen(stale)
+	require.NoError(t, err)
+	l.Close()
+}
@@ -0,0 +1,61 @@
+// Package daemon serves scmd commands from a long-running process over a
+// Unix socket, so an invocation skips loading config, registries and
+// backends before it can start.
+//
+// The protocol is JSON lines. A client connects, sends one Request and
+// reads Frames until one has Done set. Hanging up cancels the request.
+package daemon
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// SocketName is the daemon's socket in the scmd data directory
+const SocketName = "daemon.sock"
+
+// SocketPath returns the socket path in dataDir
+func SocketPath(dataDir string) string {
+	return filepath.Join(dataDir, SocketName)
+}
+
+// Request operations
+const (
+	OpRun    = "run"    // run a command, streaming its output
+	OpStatus = "status" // report the daemon's Status
+	OpStop   = "stop"   // shut the daemon down
+)
+
+// Request is the message a client sends after connecting
+type Request struct {
+	Op   string   `json:"op"`
+	Args []string `json:"args,omitempty"` // the command and its arguments
+	Dir  string   `json:"dir,omitempty"`  // working directory of the client
+
+	// Piped input, read by the client
+	Stdin  string `json:"stdin,omitempty"`
+	PipeIn bool   `json:"pipe_in,omitempty"`
+
+	// Whether the client's output goes to a terminal, which decides
+	// streaming, colors and progress output
+	StdoutTTY bool `json:"stdout_tty,omitempty"`
+	StderrTTY bool `json:"stderr_tty,omitempty"`
+}
+
+// Frame is one line of a response. Output arrives in Stdout and Stderr
+// frames; the last frame has Done set and the command's Error, if any.
+type Frame struct {
+	Stdout string  `json:"stdout,omitempty"`
+	Stderr string  `json:"stderr,omitempty"`
+	Done   bool    `json:"done,omitempty"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+// Status describes a running daemon
+type Status struct {
+	PID      int       `json:"pid"`
+	Version  string    `json:"version"`
+	Started  time.Time `json:"started"`
+	Requests int64     `json:"requests"` // commands served
+}
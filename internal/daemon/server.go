@@ -0,0 +1,190 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Handler runs the command in req, writing its output to stdout and
+// stderr. ctx is cancelled when the client hangs up.
+type Handler func(ctx context.Context, req *Request, stdout, stderr io.Writer) error
+
+// Server answers requests on a Unix socket
+type Server struct {
+	handler Handler
+	version string
+	started time.Time
+
+	// Commands run one at a time since they share process-wide state
+	// such as os.Stdout
+	runMu    sync.Mutex
+	requests atomic.Int64
+
+	stop     chan struct{} // closed by an OpStop request
+	stopOnce sync.Once
+}
+
+// NewServer creates a server running commands with handler. version is
+// reported in Status so clients can tell an outdated daemon.
+func NewServer(handler Handler, version string) *Server {
+	return &Server{
+		handler: handler,
+		version: version,
+		started: time.Now(),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Listen creates the socket at path, readable by the current user only.
+// A socket left behind by a daemon that did not shut down is replaced;
+// one a daemon still answers on is an error.
+func Listen(path string) (net.Listener, error) {
+	if _, err := NewClient(path).Status(context.Background()); err == nil {
+		return nil, fmt.Errorf("a daemon is already running on %s", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create socket directory: %w", err)
+	}
+	_ = os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("restrict socket: %w", err)
+	}
+	return l, nil
+}
+
+// Serve answers connections on l until ctx is cancelled or a client sends
+// OpStop. It closes l, which removes the socket, and waits for running
+// commands, whose contexts are cancelled.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.stop:
+		}
+		l.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-s.stop:
+				return nil
+			default:
+				return fmt.Errorf("accept: %w", err)
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// Status returns the server's status
+func (s *Server) Status() *Status {
+	return &Status{
+		PID:      os.Getpid(),
+		Version:  s.version,
+		Started:  s.started,
+		Requests: s.requests.Load(),
+	}
+}
+
+// serveConn answers the request on conn
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		return
+	}
+	out := &frameWriter{enc: json.NewEncoder(conn)}
+
+	switch req.Op {
+	case OpStatus:
+		out.send(Frame{Done: true, Status: s.Status()})
+
+	case OpStop:
+		out.send(Frame{Done: true})
+		s.stopOnce.Do(func() { close(s.stop) })
+
+	case OpRun:
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// The client sends nothing more; it hangs up to cancel
+		go func() {
+			_, _ = io.Copy(io.Discard, io.MultiReader(dec.Buffered(), conn))
+			cancel()
+		}()
+
+		s.runMu.Lock()
+		err := s.handler(ctx, &req, streamWriter{out, false}, streamWriter{out, true})
+		s.runMu.Unlock()
+		s.requests.Add(1)
+
+		done := Frame{Done: true}
+		if err != nil {
+			done.Error = err.Error()
+		}
+		out.send(done)
+
+	default:
+		out.send(Frame{Done: true, Error: fmt.Sprintf("unknown operation %q", req.Op)})
+	}
+}
+
+// frameWriter sends frames from several goroutines
+type frameWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *frameWriter) send(f Frame) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(f)
+}
+
+// streamWriter sends what is written to it as Stdout or Stderr frames
+type streamWriter struct {
+	out    *frameWriter
+	stderr bool
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	f := Frame{Stdout: string(p)}
+	if w.stderr {
+		f = Frame{Stderr: string(p)}
+	}
+	if err := w.out.send(f); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -188,6 +189,7 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 	// Gather automatic context if specified
 	if c.spec.Context != nil {
 		gatherer := contextpkg.NewGatherer("") // Use current working directory
+		gatherer.SetTokenCounter(execCtx.Backend.EstimateTokens)
 
 		// Convert repos.ContextSpec to contextpkg.ContextSpec
 		contextSpec := &contextpkg.ContextSpec{
@@ -212,9 +214,11 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 		}
 	}
 
-	// Use tool calling if backend supports it
+	// Use tool calling if backend supports it. Commands that declare JSON
+	// output skip tools so the response can be constrained and validated.
+	format := c.responseFormat()
 	var output string
-	if execCtx.Backend.SupportsToolCalling() {
+	if format == nil && execCtx.Backend.SupportsToolCalling() {
 		// Create tool registry with confirmation UI
 		var confirmUI tools.ConfirmUI
 		if execCtx.UI != nil {
@@ -234,21 +238,17 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 	} else {
 		// Fall back to basic completion if no tool calling
 		req := &backend.CompletionRequest{
-			Prompt:       prompt,
-			SystemPrompt: system,
-			MaxTokens:    2048,
-			Temperature:  0.7,
+			Prompt:         prompt,
+			SystemPrompt:   system,
+			MaxTokens:      2048,
+			Temperature:    0.7,
+			ResponseFormat: format,
 		}
 
 		// Apply model preferences
-		if c.spec.Model.MaxTokens > 0 {
-			req.MaxTokens = c.spec.Model.MaxTokens
-		}
-		if c.spec.Model.Temperature > 0 {
-			req.Temperature = c.spec.Model.Temperature
-		}
+		c.spec.Model.apply(req)
 
-		resp, err := execCtx.Backend.Complete(ctx, req)
+		resp, err := backend.CompleteStructured(ctx, execCtx.Backend, req, structuredRetries)
 		if err != nil {
 			return &command.Result{
 				Success: false,
@@ -274,6 +274,51 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 	}, nil
 }
 
+// structuredRetries is how many times a JSON response that fails
+// validation is retried
+const structuredRetries = 2
+
+// jsonSchemaTypes are the type names an outputs.schema hint may use
+var jsonSchemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true,
+	"boolean": true, "array": true, "object": true,
+}
+
+// responseFormat maps the spec's outputs section to a ResponseFormat.
+// Schema hints that name a JSON type become typed properties; any other
+// hint is kept as the property description. Every property is required.
+func (c *PluginCommand) responseFormat() *backend.ResponseFormat {
+	out := c.spec.Outputs
+	if out == nil || out.Format != "json" {
+		return nil
+	}
+	if len(out.Schema) == 0 {
+		return &backend.ResponseFormat{Type: backend.ResponseJSON}
+	}
+
+	properties := make(map[string]interface{}, len(out.Schema))
+	required := make([]string, 0, len(out.Schema))
+	for name, hint := range out.Schema {
+		if jsonSchemaTypes[hint] {
+			properties[name] = map[string]interface{}{"type": hint}
+		} else {
+			properties[name] = map[string]interface{}{"description": hint}
+		}
+		required = append(required, name)
+	}
+	sort.Strings(required)
+
+	return &backend.ResponseFormat{
+		Type: backend.ResponseJSONSchema,
+		Name: strings.ReplaceAll(c.spec.Name, "-", "_"),
+		Schema: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		},
+	}
+}
+
 // buildTemplateContext creates the context for template execution
 func (c *PluginCommand) buildTemplateContext(args *command.Args) map[string]interface{} {
 	ctx := make(map[string]interface{})
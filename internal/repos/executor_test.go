@@ -368,3 +368,52 @@ func (m *MockBackendWithCapture) Complete(ctx context.Context, req *backend.Comp
 	m.LastRequest = req
 	return &backend.CompletionResponse{Content: "Mock response"}, nil
 }
+
+func TestPluginCommand_ResponseFormat(t *testing.T) {
+	cmd := NewPluginCommand(&CommandSpec{Name: "review-json"})
+	assert.Nil(t, cmd.responseFormat())
+
+	cmd = NewPluginCommand(&CommandSpec{Name: "review-json", Outputs: &OutputSpec{Format: "json"}})
+	assert.Equal(t, &backend.ResponseFormat{Type: backend.ResponseJSON}, cmd.responseFormat())
+
+	cmd = NewPluginCommand(&CommandSpec{
+		Name: "review-json",
+		Outputs: &OutputSpec{
+			Format: "json",
+			Schema: map[string]string{"score": "integer", "summary": "one line summary"},
+		},
+	})
+	format := cmd.responseFormat()
+	require.NotNil(t, format)
+	assert.Equal(t, backend.ResponseJSONSchema, format.Type)
+	assert.Equal(t, "review_json", format.Name)
+	assert.Equal(t, []string{"score", "summary"}, format.Schema["required"])
+
+	props := format.Schema["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, props["score"])
+	assert.Equal(t, map[string]interface{}{"description": "one line summary"}, props["summary"])
+}
+
+func TestPluginCommand_Execute_JSONOutput(t *testing.T) {
+	spec := &CommandSpec{
+		Name:    "classify",
+		Prompt:  PromptSpec{Template: "Classify this"},
+		Outputs: &OutputSpec{Format: "json", Schema: map[string]string{"label": "string"}},
+	}
+	cmd := NewPluginCommand(spec)
+
+	mockBackend := mock.New()
+	mockBackend.SetResponse("```json\n{\"label\": \"bug\"}\n```")
+
+	result, err := cmd.Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: mockBackend})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, `{"label": "bug"}`, result.Output)
+
+	// A response that never matches fails after the retries
+	mockBackend.SetResponse("not json")
+	result, err = cmd.Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: mockBackend})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "structured output failed")
+}
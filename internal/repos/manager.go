@@ -14,6 +14,7 @@ import (
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/scmd/scmd/internal/backend"
 	"github.com/scmd/scmd/internal/validation"
 )
 
@@ -99,6 +100,7 @@ type InlineTemplate struct {
 	SystemPrompt       string            `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`
 	UserPromptTemplate string            `yaml:"user_prompt_template" json:"user_prompt_template"`
 	Variables          []TemplateVariable `yaml:"variables,omitempty" json:"variables,omitempty"`
+	Sampling           backend.Sampling   `yaml:"sampling,omitempty" json:"sampling,omitempty"`
 }
 
 // TemplateVariable defines a variable for inline templates
@@ -201,6 +203,23 @@ type ModelSpec struct {
 	MinContext  int     `yaml:"min_context,omitempty"`
 	Temperature float64 `yaml:"temperature,omitempty"`
 	MaxTokens   int     `yaml:"max_tokens,omitempty"`
+
+	// Sampling controls such as top_p, seed and penalties, inline in model:
+	backend.Sampling `yaml:",inline"`
+}
+
+// apply sets the spec's model preferences on req, overriding its defaults
+func (m ModelSpec) apply(req *backend.CompletionRequest) {
+	if m.MaxTokens > 0 {
+		req.MaxTokens = m.MaxTokens
+	}
+	if m.Temperature > 0 {
+		req.Temperature = m.Temperature
+	}
+	if m.Preferred != "" {
+		req.Model = m.Preferred
+	}
+	req.Sampling = req.Sampling.Merge(m.Sampling)
 }
 
 // Manager manages repositories
@@ -576,6 +595,10 @@ func toLower(s string) string {
 
 // InstallCommand saves a command spec to local storage
 func (m *Manager) InstallCommand(spec *CommandSpec, installDir string) error {
+	if err := spec.Model.Validate(); err != nil {
+		return fmt.Errorf("invalid model settings: %w", err)
+	}
+
 	// Validate template reference if present
 	if spec.Template != nil {
 		if err := m.validateTemplateRef(spec.Template); err != nil {
@@ -10,6 +10,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/backend"
 )
 
 func TestNewManager(t *testing.T) {
@@ -409,3 +412,42 @@ prompt:
 	assert.Equal(t, "docker", manifest.Commands[1].Category)
 	assert.Equal(t, "commands/docker/compose.yaml", manifest.Commands[1].File)
 }
+
+func TestModelSpec_Sampling(t *testing.T) {
+	var spec CommandSpec
+	err := yaml.Unmarshal([]byte(`
+name: gen
+model:
+  preferred: qwen2.5-coder-7b
+  temperature: 0.2
+  top_p: 0.9
+  top_k: 40
+  seed: 0
+  repeat_penalty: 1.1
+  logit_bias:
+    "50256": -100
+`), &spec)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.9, spec.Model.TopP)
+	assert.Equal(t, 40, spec.Model.TopK)
+	require.NotNil(t, spec.Model.Seed)
+	assert.Equal(t, int64(0), *spec.Model.Seed)
+	assert.Equal(t, map[string]float64{"50256": -100}, spec.Model.LogitBias)
+
+	req := &backend.CompletionRequest{Temperature: 0.7, Sampling: backend.Sampling{TopP: 0.5, MinP: 0.05}}
+	spec.Model.apply(req)
+	assert.Equal(t, 0.2, req.Temperature)
+	assert.Equal(t, 0.9, req.TopP)
+	assert.Equal(t, 0.05, req.MinP, "controls the spec leaves unset are kept")
+	assert.Equal(t, 1.1, req.RepeatPenalty)
+	assert.Equal(t, "qwen2.5-coder-7b", req.Model)
+}
+
+func TestManager_InstallCommand_InvalidSampling(t *testing.T) {
+	m := NewManager(t.TempDir())
+	spec := &CommandSpec{Name: "bad", Model: ModelSpec{Sampling: backend.Sampling{TopP: 2}}}
+
+	err := m.InstallCommand(spec, t.TempDir())
+	assert.ErrorContains(t, err, "top_p")
+}
@@ -79,13 +79,9 @@ func (te *TemplateExecutor) ExecuteTemplateCommand(
 		Temperature:  0.7,
 	}
 
-	// Apply model preferences from command spec
-	if spec.Model.MaxTokens > 0 {
-		req.MaxTokens = spec.Model.MaxTokens
-	}
-	if spec.Model.Temperature > 0 {
-		req.Temperature = spec.Model.Temperature
-	}
+	// Template sampling defaults, then model preferences from the command spec
+	req.Sampling = te.templateSampling(spec.Template)
+	spec.Model.apply(req)
 
 	// Execute completion
 	resp, err := execCtx.Backend.Complete(ctx, req)
@@ -102,6 +98,17 @@ func (te *TemplateExecutor) ExecuteTemplateCommand(
 	}, nil
 }
 
+// templateSampling returns the sampling defaults of the referenced template
+func (te *TemplateExecutor) templateSampling(templateRef *TemplateRef) backend.Sampling {
+	if templateRef.Inline != nil {
+		return templateRef.Inline.Sampling
+	}
+	if tpl, err := te.templateManager.Load(templateRef.Name); err == nil {
+		return tpl.Sampling
+	}
+	return backend.Sampling{}
+}
+
 // executeTemplate resolves and executes a template reference
 func (te *TemplateExecutor) executeTemplate(
 	templateRef *TemplateRef,
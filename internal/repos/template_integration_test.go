@@ -17,6 +17,7 @@ import (
 type MockBackend struct {
 	response string
 	err      error
+	lastReq  *backend.CompletionRequest
 }
 
 func (m *MockBackend) Name() string {
@@ -40,6 +41,7 @@ func (m *MockBackend) Shutdown(ctx context.Context) error {
 }
 
 func (m *MockBackend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	m.lastReq = req
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -215,6 +217,45 @@ Focus on:
 	}
 }
 
+func TestInlineTemplateSampling(t *testing.T) {
+	executor, err := NewTemplateExecutor(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create template executor: %v", err)
+	}
+
+	seed := int64(42)
+	spec := &CommandSpec{
+		Name: "test-gen",
+		Template: &TemplateRef{
+			Inline: &InlineTemplate{
+				UserPromptTemplate: "Generate a command",
+				Sampling:           backend.Sampling{TopP: 0.8, TopK: 20},
+			},
+		},
+		// The command spec overrides the template's defaults
+		Model: ModelSpec{Sampling: backend.Sampling{TopK: 10, Seed: &seed}},
+	}
+
+	mockBackend := &MockBackend{response: "ls"}
+	execCtx := &command.ExecContext{Backend: mockBackend}
+
+	result, err := executor.ExecuteTemplateCommand(context.Background(), spec, command.NewArgs(), execCtx)
+	if err != nil || !result.Success {
+		t.Fatalf("ExecuteTemplateCommand() failed: %v %v", err, result)
+	}
+
+	req := mockBackend.lastReq
+	if req.TopP != 0.8 {
+		t.Errorf("Expected top_p 0.8 from the template, got %v", req.TopP)
+	}
+	if req.TopK != 10 {
+		t.Errorf("Expected top_k 10 from the command spec, got %v", req.TopK)
+	}
+	if req.Seed == nil || *req.Seed != 42 {
+		t.Errorf("Expected seed 42, got %v", req.Seed)
+	}
+}
+
 func TestNamedTemplateExecution(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "scmd-template-test-*")
@@ -7,22 +7,25 @@ import (
 	"text/template"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/backend"
 )
 
 // Template defines a prompt template
 type Template struct {
-	Name                string       `yaml:"name"`
-	Version            string       `yaml:"version"`
-	Author             string       `yaml:"author"`
-	Description        string       `yaml:"description"`
-	Tags               []string     `yaml:"tags"`
-	CompatibleCommands []string     `yaml:"compatible_commands"`
-	SystemPrompt       string       `yaml:"system_prompt"`
-	UserPromptTemplate string       `yaml:"user_prompt_template"`
-	Variables          []Variable   `yaml:"variables"`
-	Output             OutputConfig `yaml:"output"`
-	RecommendedModels  []string     `yaml:"recommended_models"`
-	Examples           []Example    `yaml:"examples"`
+	Name               string           `yaml:"name"`
+	Version            string           `yaml:"version"`
+	Author             string           `yaml:"author"`
+	Description        string           `yaml:"description"`
+	Tags               []string         `yaml:"tags"`
+	CompatibleCommands []string         `yaml:"compatible_commands"`
+	SystemPrompt       string           `yaml:"system_prompt"`
+	UserPromptTemplate string           `yaml:"user_prompt_template"`
+	Variables          []Variable       `yaml:"variables"`
+	Output             OutputConfig     `yaml:"output"`
+	RecommendedModels  []string         `yaml:"recommended_models"`
+	Sampling           backend.Sampling `yaml:"sampling,omitempty"` // Defaults for top_p, seed, penalties, ...
+	Examples           []Example        `yaml:"examples"`
 }
 
 // Variable defines a template variable
@@ -134,4 +137,4 @@ func (t *Template) IsCompatibleWith(command string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
@@ -32,26 +32,19 @@ func (e *Executor) ExecuteWithTools(
 ) (string, error) {
 	if !e.backend.SupportsToolCalling() {
 		// Fall back to regular completion
-		resp, err := e.backend.Complete(ctx, &backend.CompletionRequest{
-			Prompt:       prompt,
-			SystemPrompt: systemPrompt,
-		})
-		if err != nil {
-			return "", err
-		}
-		return resp.Content, nil
+		return e.complete(ctx, prompt, systemPrompt)
 	}
 
 	// Build tool request
 	tools := e.registry.ToBackendTools()
 	var conversationHistory []string
-	currentPrompt := prompt
+	messages := []backend.Message{{Role: backend.RoleUser, Content: prompt}}
 
 	for round := 0; round < e.maxRounds; round++ {
 		// Call LLM with tools
 		toolReq := &backend.ToolRequest{
 			CompletionRequest: backend.CompletionRequest{
-				Prompt:       currentPrompt,
+				Messages:     messages,
 				SystemPrompt: systemPrompt,
 				MaxTokens:    2048,
 				Temperature:  0.7,
@@ -61,6 +54,11 @@ func (e *Executor) ExecuteWithTools(
 
 		resp, err := e.backend.CompleteWithTools(ctx, toolReq)
 		if err != nil {
+			// Some OpenAI-compatible servers reject the tools field for
+			// models without tool support; answer without tools instead
+			if round == 0 && ctx.Err() == nil {
+				return e.complete(ctx, prompt, systemPrompt)
+			}
 			return "", fmt.Errorf("tool calling failed: %w", err)
 		}
 
@@ -72,21 +70,31 @@ func (e *Executor) ExecuteWithTools(
 			return e.formatFinalResponse(conversationHistory), nil
 		}
 
-		// Execute tool calls
-		toolResults := make([]string, len(resp.ToolCalls))
-		for i, toolCall := range resp.ToolCalls {
+		messages = append(messages, backend.Message{
+			Role:      backend.RoleAssistant,
+			Content:   resp.Content,
+			ToolCalls: resp.ToolCalls,
+		})
+
+		// Execute tool calls and feed each result back as a tool turn
+		for _, toolCall := range resp.ToolCalls {
+			var output string
 			result, err := e.registry.Execute(ctx, toolCall.Name, toolCall.Parameters)
 			if err != nil {
-				toolResults[i] = fmt.Sprintf("Error executing %s: %v", toolCall.Name, err)
+				output = fmt.Sprintf("Error executing %s: %v", toolCall.Name, err)
 			} else if !result.Success {
-				toolResults[i] = fmt.Sprintf("%s failed: %s", toolCall.Name, result.Error)
+				output = fmt.Sprintf("%s failed: %s", toolCall.Name, result.Error)
 			} else {
-				toolResults[i] = fmt.Sprintf("%s result:\n%s", toolCall.Name, result.Output)
+				output = result.Output
 			}
-		}
 
-		// Build next prompt with tool results
-		currentPrompt = e.buildToolResultPrompt(resp.Content, toolResults)
+			messages = append(messages, backend.Message{
+				Role:       backend.RoleTool,
+				Content:    output,
+				ToolCallID: toolCall.ID,
+				ToolName:   toolCall.Name,
+			})
+		}
 
 		// If all tools succeeded and we have a final answer, return it
 		if e.hasFinalAnswer(resp.Content) {
@@ -98,23 +106,16 @@ func (e *Executor) ExecuteWithTools(
 	return e.formatFinalResponse(conversationHistory), nil
 }
 
-// buildToolResultPrompt creates a prompt with tool execution results
-func (e *Executor) buildToolResultPrompt(llmResponse string, toolResults []string) string {
-	var parts []string
-
-	if llmResponse != "" {
-		parts = append(parts, "Previous response:")
-		parts = append(parts, llmResponse)
-	}
-
-	parts = append(parts, "\nTool execution results:")
-	for _, result := range toolResults {
-		parts = append(parts, result)
+// complete runs a plain completion without tools
+func (e *Executor) complete(ctx context.Context, prompt, systemPrompt string) (string, error) {
+	resp, err := e.backend.Complete(ctx, &backend.CompletionRequest{
+		Prompt:       prompt,
+		SystemPrompt: systemPrompt,
+	})
+	if err != nil {
+		return "", err
 	}
-
-	parts = append(parts, "\nBased on these results, provide your final answer.")
-
-	return strings.Join(parts, "\n")
+	return resp.Content, nil
 }
 
 // hasFinalAnswer checks if the LLM response contains a final answer
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+)
+
+// rejectingBackend advertises tool support but fails every tool request,
+// like an OpenAI-compatible server whose model has no tool template
+type rejectingBackend struct {
+	*mock.Backend
+}
+
+func (b *rejectingBackend) SupportsToolCalling() bool { return true }
+
+func (b *rejectingBackend) CompleteWithTools(_ context.Context, _ *backend.ToolRequest) (*backend.ToolResponse, error) {
+	return nil, errors.New(`status 400: "tools" is not supported for this model`)
+}
+
+func TestExecutor_FallsBackWhenToolsRejected(t *testing.T) {
+	b := &rejectingBackend{Backend: mock.New()}
+	b.SetResponse("plain answer")
+
+	e := NewExecutor(NewRegistry(nil), b)
+	out, err := e.ExecuteWithTools(context.Background(), "question", "")
+	require.NoError(t, err)
+	assert.Equal(t, "plain answer", out)
+}
+
+func TestExecutor_NoToolSupport(t *testing.T) {
+	b := mock.New()
+	b.SetResponse("plain answer")
+
+	e := NewExecutor(NewRegistry(nil), b)
+	out, err := e.ExecuteWithTools(context.Background(), "question", "")
+	require.NoError(t, err)
+	assert.Equal(t, "plain answer", out)
+}